@@ -6,8 +6,21 @@ create table if not exists users (
     firstname varchar(35) null,
     lastname varchar(35) null,
     photourl varchar(2083) null,
+    role varchar(35) not null default 'member',
+    deactivated boolean not null default false,
+    suspendeduntil datetime null,
+    title varchar(100) not null default '',
+    bio varchar(500) not null default '',
+    timezone varchar(64) not null default '',
+    pronouns varchar(30) not null default '',
+    statusemoji varchar(32) not null default '',
+    statustext varchar(100) not null default '',
+    statusexpiresat datetime null,
+    updatedat datetime(6) not null default current_timestamp(6) on update current_timestamp(6),
     unique(email),       
-    unique(username)   
+    unique(username),
+    index (lastname, firstname, id),
+    index (updatedat)
 );
 
 create table if not exists userslogin (
@@ -46,6 +59,34 @@ create table if not exists channel_users (
 
 insert into channel_users (id, channelid, usersid) values (1, 1, 1);
 
+create table if not exists invites (
+    id int not null auto_increment primary key,
+    code varchar(64) not null,
+    creatorid int not null,
+    maxuses int not null,
+    uses int not null default 0,
+    createdat datetime not null,
+    expiresat datetime not null,
+    unique(code),
+    foreign key(creatorid) references users(id)
+);
+
+create table if not exists invites_channels (
+    id int not null auto_increment primary key,
+    inviteid int not null,
+    channelid int not null,
+    foreign key(inviteid) references invites(id),
+    foreign key(channelid) references channel(id),
+    unique key (inviteid, channelid)
+);
+
+create table if not exists preferences (
+    userid int not null primary key,
+    doc json not null,
+    updatedat datetime not null,
+    foreign key(userid) references users(id)
+);
+
 create table if not exists messages (
     id int not null auto_increment primary key,
     channelid int not null,
@@ -76,3 +117,30 @@ create table if not exists starred_messages(
     unique key (userid, messageid)
 );
 
+
+create table if not exists blocks (
+    id int not null auto_increment primary key,
+    userid int not null,
+    blockedid int not null,
+    mute boolean not null default false,
+    createdat datetime not null,
+    foreign key(userid) references users(id),
+    foreign key(blockedid) references users(id),
+    unique key (userid, blockedid)
+);
+
+create table if not exists audit_log (
+    id int not null auto_increment primary key,
+    createdat datetime not null,
+    actorid int null,
+    action varchar(64) not null,
+    targetid int null,
+    ip varchar(64) not null,
+    forwardedfor varchar(512) not null default '',
+    useragent varchar(512) not null,
+    outcome varchar(16) not null,
+    detail varchar(255) not null,
+    index (actorid, id),
+    index (targetid, id),
+    index (action, id)
+);
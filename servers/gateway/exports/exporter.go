@@ -0,0 +1,375 @@
+package exports
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/preferences"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+//Statuses of an export job
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+//MinInterval is how long a user must wait after starting an export
+//before they can start another
+const MinInterval = time.Hour
+
+//PendingTimeout is how long a job may be pending before it is considered
+//abandoned, such as when the gateway building it went away
+const PendingTimeout = time.Hour
+
+//ErrJobNotFound is returned when the export job can't be found
+var ErrJobNotFound = errors.New("export not found")
+
+//ErrTooSoon is returned when a user starts an export while another is
+//pending, or within MinInterval of starting the last one
+var ErrTooSoon = errors.New("an export was started too recently")
+
+//Job is an export of one user's data
+type Job struct {
+	ID          string     `json:"id"`
+	UserID      int64      `json:"userID"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	//DownloadURL is set by the handler when the export is ready
+	DownloadURL string `json:"downloadURL,omitempty"`
+}
+
+//Exporter gathers users' data into zip archives of JSON files in the directory.
+//Jobs are kept in the directory too, so gateway instances sharing the
+//directory can all report on and serve every export.
+type Exporter struct {
+	dir        string
+	source     Source
+	userStore  users.Store
+	prefsStore preferences.Store
+	//Done is optional, and is called when a job is ready or has failed
+	Done func(job *Job)
+	//mx keeps two exports from being started for a user at once
+	mx sync.Mutex
+}
+
+//NewExporter constructs a new Exporter writing to the directory,
+//which is created if it doesn't exist. prefsStore may be nil.
+func NewExporter(dir string, source Source, userStore users.Store, prefsStore preferences.Store) (*Exporter, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating export directory: %v", err)
+	}
+	return &Exporter{
+		dir:        dir,
+		source:     source,
+		userStore:  userStore,
+		prefsStore: prefsStore,
+	}, nil
+}
+
+//newJobID returns a new random job ID
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+//path returns the path of the job's file with the extension, or an
+//error if the ID isn't one the Exporter could have generated
+func (e *Exporter) path(id string, ext string) (string, error) {
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		return "", ErrJobNotFound
+	}
+	return filepath.Join(e.dir, id+ext), nil
+}
+
+//save writes the job's metadata to the directory
+func (e *Exporter) save(job *Job) error {
+	path, err := e.path(job.ID, ".json")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("encoding export job: %v", err)
+	}
+	//write then rename so readers never see a partial file
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("writing export job: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+//Start starts exporting the user's data in the background, and returns
+//the pending job. It returns ErrTooSoon if the user already has a pending
+//job, or started one less than MinInterval ago.
+func (e *Exporter) Start(userID int64) (*Job, error) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	jobs, err := e.jobs()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		if job.UserID != userID {
+			continue
+		}
+		if now.Sub(job.CreatedAt) < MinInterval || (job.Status == StatusPending && !abandoned(job, now)) {
+			return nil, ErrTooSoon
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, fmt.Errorf("generating export ID: %v", err)
+	}
+	job := &Job{
+		ID:        id,
+		UserID:    userID,
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+	if err := e.save(job); err != nil {
+		return nil, err
+	}
+	//the job is built from a copy, since the caller may still be reading the one returned
+	running := *job
+	go e.run(&running)
+	return job, nil
+}
+
+//Get returns the job with the given ID
+func (e *Exporter) Get(id string) (*Job, error) {
+	path, err := e.path(id, ".json")
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading export job: %v", err)
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("decoding export job: %v", err)
+	}
+	return job, nil
+}
+
+//Open opens the archive of a ready job
+func (e *Exporter) Open(id string) (*os.File, error) {
+	path, err := e.path(id, ".zip")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrJobNotFound
+	}
+	return f, err
+}
+
+//jobs returns every job in the directory
+func (e *Exporter) jobs() ([]*Job, error) {
+	paths, err := filepath.Glob(filepath.Join(e.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing export jobs: %v", err)
+	}
+	jobs := []*Job{}
+	for _, path := range paths {
+		job, err := e.Get(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+//abandoned returns true if the job has been pending longer than PendingTimeout
+func abandoned(job *Job, now time.Time) bool {
+	return job.Status == StatusPending && now.Sub(job.CreatedAt) > PendingTimeout
+}
+
+//Purge deletes every job created before the time, and its archive.
+//Jobs that were abandoned while pending are marked as failed, along
+//with deleting the partial archive, so they are purged in turn.
+func (e *Exporter) Purge(before time.Time) error {
+	jobs, err := e.jobs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		zipPath, _ := e.path(job.ID, ".zip")
+		if abandoned(job, now) {
+			os.Remove(zipPath + ".tmp")
+			job.Status = StatusFailed
+			job.CompletedAt = &now
+			if err := e.save(job); err != nil {
+				log.Printf("error saving abandoned export job %s: %v", job.ID, err)
+			}
+			continue
+		}
+		if job.Status == StatusPending || !job.CreatedAt.Before(before) {
+			continue
+		}
+		jobPath, _ := e.path(job.ID, ".json")
+		os.Remove(zipPath)
+		os.Remove(jobPath)
+	}
+	return nil
+}
+
+//run builds the job's archive and marks the job as ready or failed
+func (e *Exporter) run(job *Job) {
+	job.Status = StatusReady
+	if err := e.build(job); err != nil {
+		log.Printf("error exporting data for user %d: %v", job.UserID, err)
+		job.Status = StatusFailed
+	}
+	now := time.Now()
+	job.CompletedAt = &now
+	if err := e.save(job); err != nil {
+		log.Printf("error saving export job %s: %v", job.ID, err)
+		return
+	}
+	if e.Done != nil {
+		e.Done(job)
+	}
+}
+
+//build writes the job's archive
+func (e *Exporter) build(job *Job) error {
+	path, err := e.path(job.ID, ".zip")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("creating archive: %v", err)
+	}
+	defer os.Remove(path + ".tmp")
+	defer f.Close()
+
+	archive := zip.NewWriter(f)
+	if err := e.write(archive, job.UserID); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("finishing archive: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing archive: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+//write gathers the user's data and writes it to the archive
+func (e *Exporter) write(archive *zip.Writer, userID int64) error {
+	user, err := e.userStore.GetByID(userID)
+	if err != nil {
+		return fmt.Errorf("getting user: %v", err)
+	}
+	if err := writeJSON(archive, "user.json", user); err != nil {
+		return err
+	}
+	if e.prefsStore != nil {
+		prefs, err := e.prefsStore.Get(userID)
+		if err != nil {
+			return fmt.Errorf("getting preferences: %v", err)
+		}
+		if err := writeJSON(archive, "preferences.json", prefs); err != nil {
+			return err
+		}
+	}
+
+	logins, err := e.source.Logins(userID)
+	if err != nil {
+		return fmt.Errorf("getting logins: %v", err)
+	}
+	messages, err := e.source.Messages(userID)
+	if err != nil {
+		return fmt.Errorf("getting messages: %v", err)
+	}
+	reactions, err := e.source.Reactions(userID)
+	if err != nil {
+		return fmt.Errorf("getting reactions: %v", err)
+	}
+	starred, err := e.source.StarredMessageIDs(userID)
+	if err != nil {
+		return fmt.Errorf("getting starred messages: %v", err)
+	}
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"logins.json", logins},
+		{"messages.json", messages},
+		{"reactions.json", reactions},
+		{"starred.json", starred},
+	}
+	for _, file := range files {
+		if err := writeJSON(archive, file.name, file.value); err != nil {
+			return err
+		}
+	}
+	return writeAvatar(archive, user.PhotoURL)
+}
+
+//writeJSON writes the value to the archive as an indented JSON file
+func writeJSON(archive *zip.Writer, name string, value interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("adding %s: %v", name, err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("writing %s: %v", name, err)
+	}
+	return nil
+}
+
+//writeAvatar copies the user's uploaded avatar into the archive. Avatars
+//that are URLs rather than uploaded files are already in user.json.
+func writeAvatar(archive *zip.Writer, photoURL string) error {
+	if len(photoURL) == 0 || strings.Contains(photoURL, "://") {
+		return nil
+	}
+	avatar, err := os.Open(photoURL)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening avatar: %v", err)
+	}
+	defer avatar.Close()
+	w, err := archive.Create("avatar" + filepath.Ext(photoURL))
+	if err != nil {
+		return fmt.Errorf("adding avatar: %v", err)
+	}
+	if _, err := io.Copy(w, avatar); err != nil {
+		return fmt.Errorf("writing avatar: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package exports
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+//fakeSource is a Source returning fixed data
+type fakeSource struct{}
+
+func (fakeSource) Logins(userID int64) ([]*Login, error) {
+	return []*Login{{LoginTime: time.Now(), IPAddr: "127.0.0.1"}}, nil
+}
+
+func (fakeSource) Messages(userID int64) ([]*Message, error) {
+	return []*Message{{ID: 1, ChannelID: 1, Body: "hello", CreatedAt: time.Now()}}, nil
+}
+
+func (fakeSource) Reactions(userID int64) ([]*Reaction, error) {
+	return []*Reaction{}, nil
+}
+
+func (fakeSource) StarredMessageIDs(userID int64) ([]int64, error) {
+	return []int64{1}, nil
+}
+
+func TestExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exports")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	userStore := users.NewMockStore(false, &users.User{ID: 1, UserName: "gopher"})
+	exporter, err := NewExporter(dir, fakeSource{}, userStore, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := make(chan *Job, 1)
+	exporter.Done = func(job *Job) { done <- job }
+
+	job, err := exporter.Start(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Status != StatusPending {
+		t.Errorf("expected new job to be %s but got %s", StatusPending, job.Status)
+	}
+
+	select {
+	case finished := <-done:
+		if finished.Status != StatusReady || finished.CompletedAt == nil {
+			t.Fatalf("expected job to be ready, got %+v", finished)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for export")
+	}
+
+	saved, err := exporter.Get(job.ID)
+	if err != nil {
+		t.Fatalf("unexpected error getting job: %v", err)
+	}
+	if saved.Status != StatusReady || saved.UserID != 1 {
+		t.Errorf("expected saved job to be ready, got %+v", saved)
+	}
+
+	f, err := exporter.Open(job.ID)
+	if err != nil {
+		t.Fatalf("unexpected error opening archive: %v", err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	archive, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	names := []string{}
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	expected := []string{"logins.json", "messages.json", "reactions.json", "starred.json", "user.json"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected archive files %v but got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("expected archive files %v but got %v", expected, names)
+			break
+		}
+	}
+
+	if _, err := exporter.Get("../../etc/passwd"); err != ErrJobNotFound {
+		t.Errorf("expected invalid job ID to not be found, got %v", err)
+	}
+
+	if err := exporter.Purge(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("unexpected error purging: %v", err)
+	}
+	if _, err := exporter.Get(job.ID); err != ErrJobNotFound {
+		t.Errorf("expected purged job to be gone, got %v", err)
+	}
+}
+
+func TestExporterLimits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exports")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	userStore := users.NewMockStore(false, &users.User{ID: 1, UserName: "gopher"})
+	exporter, err := NewExporter(dir, fakeSource{}, userStore, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	done := make(chan *Job, 2)
+	exporter.Done = func(job *Job) { done <- job }
+
+	if _, err := exporter.Start(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := exporter.Start(1); err != ErrTooSoon {
+		t.Errorf("expected a second export to be too soon, got %v", err)
+	}
+	<-done
+	if _, err := exporter.Start(1); err != ErrTooSoon {
+		t.Errorf("expected an export within the interval to be too soon, got %v", err)
+	}
+	if _, err := exporter.Start(2); err != nil {
+		t.Errorf("expected other users to be able to export, got %v", err)
+	}
+	<-done
+
+	//a job left pending by a gateway that went away
+	id, _ := newJobID()
+	stale := &Job{ID: id, UserID: 3, Status: StatusPending, CreatedAt: time.Now().Add(-2 * PendingTimeout)}
+	if err := exporter.save(stale); err != nil {
+		t.Fatalf("unexpected error saving job: %v", err)
+	}
+	if err := exporter.Purge(time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("unexpected error purging: %v", err)
+	}
+	saved, err := exporter.Get(id)
+	if err != nil || saved.Status != StatusFailed || saved.CompletedAt == nil {
+		t.Errorf("expected the abandoned job to be failed, got %+v, %v", saved, err)
+	}
+	if err := exporter.Purge(time.Now()); err != nil {
+		t.Fatalf("unexpected error purging: %v", err)
+	}
+	if _, err := exporter.Get(id); err != ErrJobNotFound {
+		t.Errorf("expected the failed job to be purged, got %v", err)
+	}
+}
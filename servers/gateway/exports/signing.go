@@ -0,0 +1,36 @@
+package exports
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+)
+
+//ErrInvalidSignature is returned when a download link's signature doesn't match
+var ErrInvalidSignature = errors.New("invalid download signature")
+
+//ErrLinkExpired is returned when a download link has expired
+var ErrLinkExpired = errors.New("download link has expired")
+
+//Sign returns the signature of a download link for the job that expires at the time
+func Sign(signingKey string, jobID string, expires time.Time) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(jobID + ":" + strconv.FormatInt(expires.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//Verify returns an error if the signature doesn't match the job
+//and expiry time, or if the link has expired at time `now`
+func Verify(signingKey string, jobID string, expires time.Time, signature string, now time.Time) error {
+	expected := Sign(signingKey, jobID, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if !now.Before(expires) {
+		return ErrLinkExpired
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package exports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	signature := Sign("key", "job", expires)
+
+	cases := []struct {
+		name          string
+		key           string
+		jobID         string
+		expires       time.Time
+		signature     string
+		now           time.Time
+		expectedError error
+	}{
+		{"Valid Link", "key", "job", expires, signature, now, nil},
+		{"Different Job", "key", "other", expires, signature, now, ErrInvalidSignature},
+		{"Different Key", "other", "job", expires, signature, now, ErrInvalidSignature},
+		{"Extended Expiry", "key", "job", expires.Add(time.Hour), signature, now, ErrInvalidSignature},
+		{"Expired Link", "key", "job", expires, signature, expires, ErrLinkExpired},
+		{"Missing Signature", "key", "job", expires, "", now, ErrInvalidSignature},
+	}
+
+	for _, c := range cases {
+		if err := Verify(c.key, c.jobID, c.expires, c.signature, c.now); err != c.expectedError {
+			t.Errorf("case %s: expected error %v but got %v", c.name, c.expectedError, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package exports
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+//Message is a message the user posted
+type Message struct {
+	ID        int64      `json:"id"`
+	ChannelID int64      `json:"channelID"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+}
+
+//Reaction is a reaction the user added to a message
+type Reaction struct {
+	MessageID int64  `json:"messageID"`
+	Reaction  string `json:"reaction"`
+}
+
+//Login is one sign-in to the user's account
+type Login struct {
+	LoginTime time.Time `json:"loginTime"`
+	IPAddr    string    `json:"ipAddr"`
+}
+
+//Source provides the user's data held outside the users and preferences stores
+type Source interface {
+	//Logins returns the user's sign-in history
+	Logins(userID int64) ([]*Login, error)
+	//Messages returns the messages the user posted
+	Messages(userID int64) ([]*Message, error)
+	//Reactions returns the reactions the user added
+	Reactions(userID int64) ([]*Reaction, error)
+	//StarredMessageIDs returns the IDs of messages the user starred
+	StarredMessageIDs(userID int64) ([]int64, error)
+}
+
+//MySQLSource reads the user's data from the shared MySQL schema
+type MySQLSource struct {
+	db *sql.DB
+}
+
+//NewMySQLSource constructs a new MySQLSource.
+func NewMySQLSource(db *sql.DB) *MySQLSource {
+	return &MySQLSource{
+		db: db,
+	}
+}
+
+//query runs the query for the user and calls scan for each row
+func (s *MySQLSource) query(query string, userID int64, scan func(rows *sql.Rows) error) error {
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return fmt.Errorf("Error querying: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("Error scanning: %v", err)
+		}
+	}
+	return rows.Err()
+}
+
+//Logins returns the user's sign-in history
+func (s *MySQLSource) Logins(userID int64) ([]*Login, error) {
+	logins := []*Login{}
+	err := s.query("select logintime, ipaddr from userslogin where userid = ? order by logintime", userID,
+		func(rows *sql.Rows) error {
+			login := &Login{}
+			logins = append(logins, login)
+			return rows.Scan(&login.LoginTime, &login.IPAddr)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return logins, nil
+}
+
+//Messages returns the messages the user posted
+func (s *MySQLSource) Messages(userID int64) ([]*Message, error) {
+	messages := []*Message{}
+	err := s.query("select id, channelid, body, createdat, editedat from messages where creatorid = ? order by id", userID,
+		func(rows *sql.Rows) error {
+			message := &Message{}
+			messages = append(messages, message)
+			return rows.Scan(&message.ID, &message.ChannelID, &message.Body, &message.CreatedAt, &message.EditedAt)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+//Reactions returns the reactions the user added
+func (s *MySQLSource) Reactions(userID int64) ([]*Reaction, error) {
+	reactions := []*Reaction{}
+	err := s.query("select messageid, reaction from messages_reactions where userid = ? order by id", userID,
+		func(rows *sql.Rows) error {
+			reaction := &Reaction{}
+			reactions = append(reactions, reaction)
+			return rows.Scan(&reaction.MessageID, &reaction.Reaction)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+//StarredMessageIDs returns the IDs of messages the user starred
+func (s *MySQLSource) StarredMessageIDs(userID int64) ([]int64, error) {
+	ids := []int64{}
+	err := s.query("select messageid from starred_messages where userid = ? order by id", userID,
+		func(rows *sql.Rows) error {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/audit"
+)
+
+//AuditSink records security-relevant actions in an append-only audit log
+type AuditSink interface {
+	//Record appends the entry to the log, setting its ID
+	Record(entry *audit.Entry) error
+	//Query returns a page of entries matching the query, newest first
+	Query(q *audit.Query) (*audit.Page, error)
+}
+
+//recordAudit records an action taken through the request in the audit log if the
+//Context has an AuditSink. Errors are logged so auditing never fails a request.
+func (ctx *Context) recordAudit(r *http.Request, action string, actorID int64, targetID int64, outcome string, detail string) {
+	if ctx.Audit == nil {
+		return
+	}
+	entry := &audit.Entry{
+		CreatedAt:    time.Now(),
+		ActorID:      actorID,
+		Action:       action,
+		TargetID:     targetID,
+		IP:           ctx.clientIP(r),
+		ForwardedFor: r.Header.Get(HeaderForwardedFor),
+		UserAgent:    r.UserAgent(),
+		Outcome:      outcome,
+		Detail:       detail,
+	}
+	if err := ctx.Audit.Record(entry); err != nil {
+		log.Printf("error recording %s audit entry: %v", action, err)
+	}
+}
+
+//clientIP returns the address of the client that made the request. The
+//X-Forwarded-For header is only believed when the request came from one of
+//the TrustedProxies, and then the client is the last address in it that
+//isn't also a trusted proxy.
+func (ctx *Context) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	forwarded := r.Header.Get(HeaderForwardedFor)
+	if !ctx.isTrustedProxy(ip) || len(forwarded) == 0 {
+		return ip
+	}
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = strings.TrimSpace(hops[i])
+		if !ctx.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+//isTrustedProxy returns true if the address is one of the TrustedProxies
+func (ctx *Context) isTrustedProxy(ip string) bool {
+	for _, proxy := range ctx.TrustedProxies {
+		if proxy == ip {
+			return true
+		}
+	}
+	return false
+}
+
+//AuditHandler handles requests for the audit log. Only admins may read it,
+//so it should be wrapped with PolicyAdmin.
+func (ctx *Context) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil || !stateStruct.User.IsAdmin() {
+		WriteError(w, r, errActionNotAllowed)
+		return
+	}
+	if ctx.Audit == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Audit log is not available"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		q, err := parseAuditQuery(r)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		page, err := ctx.Audit.Query(q)
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("querying audit log: %v", err)))
+			return
+		}
+		respond(w, page, http.StatusOK, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//parseAuditQuery builds an audit.Query from the query string parameters
+//actor, target, action, outcome, since, until, cursor and limit
+func parseAuditQuery(r *http.Request) (*audit.Query, error) {
+	params := r.URL.Query()
+	q := &audit.Query{
+		Action:  params.Get("action"),
+		Outcome: params.Get("outcome"),
+	}
+	ints := []struct {
+		name  string
+		value *int64
+	}{
+		{"actor", &q.ActorID},
+		{"target", &q.TargetID},
+		{"cursor", &q.Before},
+	}
+	for _, param := range ints {
+		if s := params.Get(param.name); s != "" {
+			value, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || value <= 0 {
+				return nil, NewHTTPError(http.StatusBadRequest, CodeInvalidQuery,
+					fmt.Sprintf("'%s' must be a positive number", param.name))
+			}
+			*param.value = value
+		}
+	}
+	times := []struct {
+		name  string
+		value **time.Time
+	}{
+		{"since", &q.Since},
+		{"until", &q.Until},
+	}
+	for _, param := range times {
+		if s := params.Get(param.name); s != "" {
+			value, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, NewHTTPError(http.StatusBadRequest, CodeInvalidQuery,
+					fmt.Sprintf("'%s' must be an RFC 3339 time", param.name))
+			}
+			*param.value = &value
+		}
+	}
+	if s := params.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > audit.MaxLimit {
+			return nil, NewHTTPError(http.StatusBadRequest, CodeInvalidQuery,
+				fmt.Sprintf("'limit' must be between 1 and %d", audit.MaxLimit))
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
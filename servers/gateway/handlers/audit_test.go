@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/audit"
+)
+
+func TestParseAuditQuery(t *testing.T) {
+	cases := []struct {
+		name        string
+		query       string
+		expectError bool
+		check       func(q *audit.Query) bool
+	}{
+		{
+			"No Filters",
+			"",
+			false,
+			func(q *audit.Query) bool { return *q == audit.Query{} },
+		},
+		{
+			"All Filters",
+			"?actor=1&target=2&action=sign-in&outcome=failure&since=2018-05-01T00:00:00Z&cursor=10&limit=5",
+			false,
+			func(q *audit.Query) bool {
+				return q.ActorID == 1 && q.TargetID == 2 && q.Action == audit.ActionSignIn &&
+					q.Outcome == audit.OutcomeFailure && q.Since != nil && q.Until == nil &&
+					q.Before == 10 && q.Limit == 5
+			},
+		},
+		{
+			"Invalid Actor",
+			"?actor=me",
+			true,
+			nil,
+		},
+		{
+			"Invalid Time",
+			"?until=yesterday",
+			true,
+			nil,
+		},
+		{
+			"Limit Too Large",
+			"?limit=1000",
+			true,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		q, err := parseAuditQuery(httptest.NewRequest("GET", "/v1/audit"+c.query, nil))
+		if c.expectError {
+			if err == nil {
+				t.Errorf("case %s: expected error but got nothing", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if !c.check(q) {
+			t.Errorf("case %s: unexpected query %+v", c.name, q)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expectedIP string
+	}{
+		{
+			"No Proxy",
+			"1.2.3.4:5000",
+			"",
+			"1.2.3.4",
+		},
+		{
+			"Forged Header Ignored",
+			"1.2.3.4:5000",
+			"9.9.9.9",
+			"1.2.3.4",
+		},
+		{
+			"Trusted Proxy",
+			"10.0.0.1:5000",
+			"1.2.3.4",
+			"1.2.3.4",
+		},
+		{
+			"Forged Hop Before Trusted Proxies",
+			"10.0.0.1:5000",
+			"9.9.9.9, 1.2.3.4, 10.0.0.2",
+			"1.2.3.4",
+		},
+	}
+
+	ctx := &Context{TrustedProxies: []string{"10.0.0.1", "10.0.0.2"}}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/v1/users/me", nil)
+		req.RemoteAddr = c.remoteAddr
+		if len(c.forwarded) > 0 {
+			req.Header.Set(HeaderForwardedFor, c.forwarded)
+		}
+		if ip := ctx.clientIP(req); ip != c.expectedIP {
+			t.Errorf("case %s: expected %s but got %s", c.name, c.expectedIP, ip)
+		}
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"net/smtp"
 	"os"
@@ -16,6 +17,8 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/audit"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
 	"github.com/nbutton23/zxcvbn-go"
@@ -33,80 +36,185 @@ type resetInfo struct {
 	PasswordConf string `json:"passwordConf"`
 }
 
+//errInvalidCredentials is returned when the email or password is wrong
+var errInvalidCredentials = NewHTTPError(http.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials")
+
 //UsersHandler handles requests for the "users" resource
 func (ctx *Context) UsersHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
 		newUser := &users.NewUser{}
-		code, err := decodeReq(w, r, newUser)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error with provided data: %v", err), code)
+		if err := decodeReq(r, newUser); err != nil {
+			WriteError(w, r, err)
 			return
 		}
 
 		strength := zxcvbn.PasswordStrength(newUser.Password, nil)
 
 		if strength.Score <= 2 {
-			http.Error(w, "Password is not strong enough", http.StatusBadRequest)
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeWeakPassword, "Password is not strong enough"))
+			return
+		}
+
+		invite, err := ctx.checkRegistration(newUser)
+		if err != nil {
+			WriteError(w, r, err)
 			return
 		}
 
+		if err := newUser.Validate(); err != nil {
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidUser, err.Error()))
+			return
+		}
 		user, err := newUser.ToUser()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Invalid user: %v", err), http.StatusBadRequest)
+			WriteError(w, r, internalError(err))
 			return
 		}
 
+		if invite != nil {
+			if invite, err = ctx.InviteStore.Redeem(invite.Code, time.Now()); err != nil {
+				WriteError(w, r, inviteError(err))
+				return
+			}
+		}
+
 		inserted, err := ctx.UserStore.Insert(user)
 
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error inserting user: %v", err), http.StatusInternalServerError)
+			if invite != nil {
+				if err := ctx.InviteStore.Release(invite.Code); err != nil {
+					log.Printf("error releasing invite %s: %v", invite.Code, err)
+				}
+			}
+			WriteError(w, r, internalError(fmt.Errorf("inserting user: %v", err)))
 			return
 		}
-		ctx.Trie.AddConvertedUsers(inserted.FirstName, inserted.LastName, inserted.UserName, inserted.ID)
-		stateStruct := &SessionState{
-			BeginTime: time.Now(),
-			User:      inserted,
+		if invite != nil && len(invite.ChannelIDs) > 0 {
+			if err := ctx.InviteStore.JoinChannels(inserted.ID, invite.ChannelIDs); err != nil {
+				log.Printf("error joining invited channels for user %d: %v", inserted.ID, err)
+			}
 		}
-		_, err = sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, stateStruct, w)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error beginning session: %v", err), http.StatusInternalServerError)
+		ctx.Index.Upsert(inserted.ID, inserted.SearchFields())
+		ctx.publishUserEvent(EventUserCreated, inserted)
+		if err = ctx.beginUserSession(inserted, w); err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("beginning session: %v", err)))
 			return
 		}
 
 		respond(w, inserted, http.StatusCreated, ContentTypeJSON)
 
 	case http.MethodGet:
-		stateStruct := &SessionState{}
-		_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, stateStruct)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error getting session state: %v", err), http.StatusInternalServerError)
-			return
-		}
-		queries := r.URL.Query().Get("q")
-		if len(queries) < 1 {
-			http.Error(w, "Missing 'q' query string parameter", http.StatusBadRequest)
+		stateStruct := GetSessionState(r)
+		if stateStruct == nil {
+			WriteError(w, r, errUnauthenticated)
 			return
 		}
-		userIDs := ctx.Trie.Find(queries, 20)
-		users, err := ctx.UserStore.GetSearchUsers(userIDs)
-		if users == nil || err != nil {
-			http.Error(w, fmt.Sprintf("Error getting users based on search: %v", err), http.StatusBadRequest)
+		params := r.URL.Query()
+		switch {
+		case len(params.Get("ids")) > 0:
+			ids, err := parseIDList(params.Get("ids"))
+			if err != nil {
+				WriteError(w, r, err)
+				return
+			}
+			users, err := ctx.UserStore.GetByIDs(ids)
+			if err != nil {
+				WriteError(w, r, internalError(fmt.Errorf("getting users: %v", err)))
+				return
+			}
+			respond(w, users, http.StatusOK, ContentTypeJSON)
+
+		case len(strings.TrimSpace(params.Get("q"))) > 0:
+			terms := indexes.Analyze(params.Get("q"))
+			if len(terms) == 0 {
+				respond(w, []*users.User{}, http.StatusOK, ContentTypeJSON)
+				return
+			}
+			blocked := ctx.Blocks.BlockedIDs(stateStruct.User.ID)
+			//every term has to start one of the user's names or their username
+			userIDs := ctx.Index.FindAll(terms, MaxSearchCandidates, blocked)
+			if len(terms) == 1 && len(userIDs) < MaxSearchResults {
+				//fall back to typo-tolerant matches, which rank after every prefix match
+				fuzzyIDs := ctx.Index.FindFuzzy(terms[0], indexes.MaxEdits(terms[0]), MaxSearchCandidates, blocked)
+				userIDs = append(userIDs, fuzzyIDs...)
+			}
+			found, err := ctx.UserStore.GetSearchUsers(userIDs)
+			if err != nil {
+				WriteError(w, r, internalError(fmt.Errorf("getting users based on search: %v", err)))
+				return
+			}
+			boosts := ctx.SearchBoost.Boosts(stateStruct.User.ID, found)
+			users.SortByRelevanceWithBoosts(found, params.Get("q"), boosts)
+			if len(found) > MaxSearchResults {
+				found = found[:MaxSearchResults]
+			}
+			respond(w, found, http.StatusOK, ContentTypeJSON)
+
+		default:
+			ctx.directory(w, r, stateStruct)
 		}
-		respond(w, users, http.StatusOK, ContentTypeJSON)
 	default:
-		http.Error(w, "invalid request", http.StatusMethodNotAllowed)
+		WriteError(w, r, errMethodNotAllowed)
 		return
 	}
 }
 
+//MaxSearchResults is the most users returned by a search
+const MaxSearchResults = 20
+
+//MaxSearchCandidates is the most users found in the trie for a search, which
+//are then ranked by relevance. More are found than returned so that the most
+//relevant users aren't missed when the trie finds them late.
+const MaxSearchCandidates = 100
+
+//directory responds with a page of the user directory selected by the query
+//string parameters sort, order, status, role, cursor and limit. Only admins
+//may list deactivated users.
+func (ctx *Context) directory(w http.ResponseWriter, r *http.Request, stateStruct *SessionState) {
+	params := r.URL.Query()
+	q := &users.DirectoryQuery{
+		Sort:   params.Get("sort"),
+		Status: params.Get("status"),
+		Role:   params.Get("role"),
+		Cursor: params.Get("cursor"),
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Descending = true
+	default:
+		WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidQuery, "order must be asc or desc"))
+		return
+	}
+	if limit := params.Get("limit"); len(limit) > 0 {
+		var err error
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidQuery, "limit must be a number"))
+			return
+		}
+	}
+	if err := q.Validate(); err != nil {
+		WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidQuery, err.Error()))
+		return
+	}
+	if q.Status != users.FilterActive && !stateStruct.User.IsAdmin() {
+		WriteError(w, r, errActionNotAllowed)
+		return
+	}
+	page, err := ctx.UserStore.GetDirectory(q)
+	if err != nil {
+		WriteError(w, r, internalError(fmt.Errorf("getting directory: %v", err)))
+		return
+	}
+	respond(w, page, http.StatusOK, ContentTypeJSON)
+}
+
 //SpecificUserHandler handles requests for a specific user
 func (ctx *Context) SpecificUserHandler(w http.ResponseWriter, r *http.Request) {
-
-	stateStruct := &SessionState{}
-	_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, stateStruct)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error getting session state: %v", err), http.StatusUnauthorized)
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
 		return
 	}
 
@@ -115,7 +223,7 @@ func (ctx *Context) SpecificUserHandler(w http.ResponseWriter, r *http.Request)
 	// passedID := vars["id"]
 	reqID, err := parseID(passedID, stateStruct)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error converting user ID: %v", err), http.StatusInternalServerError)
+		WriteError(w, r, errInvalidUserID)
 		return
 	}
 
@@ -123,33 +231,38 @@ func (ctx *Context) SpecificUserHandler(w http.ResponseWriter, r *http.Request)
 	case http.MethodGet:
 		user, err := ctx.UserStore.GetByID(reqID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error finding user: %v", err), http.StatusNotFound)
+			WriteError(w, r, userStoreError(err))
 			return
 		}
 		respond(w, user, http.StatusOK, ContentTypeJSON)
 
 	case http.MethodPatch:
 		if reqID != stateStruct.User.ID {
-			http.Error(w, "Action not allowed", http.StatusForbidden)
+			WriteError(w, r, errActionNotAllowed)
 			return
 		}
 		updates := &users.Updates{}
-		code, err := decodeReq(w, r, updates)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error with provided data: %v", err), code)
+		if err := decodeReq(r, updates); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if err := updates.Validate(time.Now()); err != nil {
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidUser, err.Error()))
 			return
 		}
 		updatedUser, err := ctx.UserStore.Update(reqID, updates)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error updating user: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, userStoreError(err))
 			return
 		}
-		ctx.Trie.RemoveConvertedUsers(stateStruct.User.FirstName, stateStruct.User.LastName, stateStruct.User.ID)
-		ctx.Trie.AddConvertedUsers(updatedUser.FirstName, updatedUser.LastName, updatedUser.UserName, updatedUser.ID)
+		ctx.Index.Upsert(updatedUser.ID, updatedUser.SearchFields())
+		ctx.publishUserEvent(EventUserUpdated, updatedUser)
+		ctx.recordAudit(r, audit.ActionProfileUpdated, stateStruct.User.ID, reqID, audit.OutcomeSuccess, "")
+		ctx.publishEvent(&userEvent{Type: EventUserUpdated, User: updatedUser})
 		respond(w, updatedUser, http.StatusOK, ContentTypeJSON)
 
 	default:
-		http.Error(w, "invalid request", http.StatusMethodNotAllowed)
+		WriteError(w, r, errMethodNotAllowed)
 		return
 	}
 
@@ -161,67 +274,73 @@ func (ctx *Context) SessionsHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
 		credentials := &users.Credentials{}
-		code, err := decodeReq(w, r, credentials)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error with provided data: %v", err), code)
+		if err := decodeReq(r, credentials); err != nil {
+			WriteError(w, r, err)
 			return
 		}
 		findUser, err := ctx.UserStore.GetByEmail(credentials.Email)
 
 		if err != nil {
 			bcrypt.CompareHashAndPassword([]byte("password"), []byte("wastetime"))
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			ctx.recordAudit(r, audit.ActionSignIn, 0, 0, audit.OutcomeFailure, "unknown email")
+			WriteError(w, r, errInvalidCredentials)
 			return
 		}
 
-		ipaddr := getClientKey(r)
+		ipaddr := ctx.clientIP(r)
 		currFails, err := ctx.SessionStore.Increment(ipaddr, 0)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("error saving failed attempts1 : %v", err), http.StatusInternalServerError)
+			WriteError(w, r, internalError(fmt.Errorf("getting failed attempts: %v", err)))
 			return
 		}
 		if currFails >= 5 {
 			ctx.SessionStore.Increment(ipaddr, 1)
+			ctx.recordAudit(r, audit.ActionSignIn, 0, findUser.ID, audit.OutcomeFailure, "too many failed attempts")
 			currTimeLeft, _ := ctx.SessionStore.TimeLeft(ipaddr)
-			w.Header().Add(HeaderRetryAfter, HeaderRetryAfter)
-			http.Error(w, fmt.Sprintf("Too many failed attempts. Try again in %s minutes", currTimeLeft), http.StatusTooManyRequests)
+			if minutes, err := strconv.ParseFloat(currTimeLeft, 64); err == nil {
+				w.Header().Add(HeaderRetryAfter, strconv.Itoa(int(math.Ceil(minutes*60))))
+			}
+			WriteError(w, r, NewHTTPError(http.StatusTooManyRequests, CodeTooManyAttempts,
+				fmt.Sprintf("Too many failed attempts. Try again in %s minutes", currTimeLeft)))
 			return
 		}
 
 		if err = findUser.Authenticate(credentials.Password); err != nil {
 			if _, err := ctx.SessionStore.Increment(ipaddr, 1); err != nil {
-				http.Error(w, fmt.Sprintf("error saving failed attempts2 : %v", err), http.StatusInternalServerError)
+				WriteError(w, r, internalError(fmt.Errorf("saving failed attempts: %v", err)))
 				return
 			}
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			ctx.recordAudit(r, audit.ActionSignIn, 0, findUser.ID, audit.OutcomeFailure, "wrong password")
+			WriteError(w, r, errInvalidCredentials)
+			return
+		}
+		if err = findUser.CheckActive(time.Now()); err != nil {
+			ctx.recordAudit(r, audit.ActionSignIn, 0, findUser.ID, audit.OutcomeFailure, err.Error())
+			WriteError(w, r, inactiveUserError(err))
 			return
 		}
 		// add to userslogin
 		login := &users.Login{
 			Userid:    findUser.ID,
 			LoginTime: time.Now(),
-			IPAddr:    getClientKey(r),
+			IPAddr:    ctx.clientIP(r),
 		}
 
 		_, err = ctx.UserStore.InsertLogin(login)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error inserting login: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, internalError(fmt.Errorf("inserting login: %v", err)))
 			return
 		}
-		stateStruct := &SessionState{
-			BeginTime: time.Now(),
-			User:      findUser,
-		}
-		_, err = sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, stateStruct, w)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error beginning session: %v", err), http.StatusInternalServerError)
+		if err = ctx.beginUserSession(findUser, w); err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("beginning session: %v", err)))
 			return
 		}
+		ctx.recordAudit(r, audit.ActionSignIn, findUser.ID, findUser.ID, audit.OutcomeSuccess, "")
 
 		respond(w, findUser, http.StatusCreated, ContentTypeJSON)
 
 	default:
-		http.Error(w, "invalid request", http.StatusMethodNotAllowed)
+		WriteError(w, r, errMethodNotAllowed)
 		return
 	}
 }
@@ -232,86 +351,89 @@ func (ctx *Context) SpecificSessionHandler(w http.ResponseWriter, r *http.Reques
 	case http.MethodDelete:
 		segment := path.Base(r.URL.Path)
 		if segment != "mine" {
-			http.Error(w, "Forbidden User", http.StatusForbidden)
+			WriteError(w, r, errActionNotAllowed)
 			return
 		}
 		_, err := sessions.EndSession(r, ctx.SigningKey, ctx.SessionStore)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error ending session: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, errUnauthenticated)
 			return
 		}
+		if stateStruct := GetSessionState(r); stateStruct != nil {
+			ctx.recordAudit(r, audit.ActionSignOut, stateStruct.User.ID, stateStruct.User.ID, audit.OutcomeSuccess, "")
+		}
 		respond(w, "Signed Out", http.StatusOK, ContentTypeText)
 
 	default:
-		http.Error(w, "invalid request", http.StatusMethodNotAllowed)
+		WriteError(w, r, errMethodNotAllowed)
 		return
 	}
 }
 
 //AvatarHandler handles requests related to changing profile pictures
 func (ctx *Context) AvatarHandler(w http.ResponseWriter, r *http.Request) {
-	stateStruct := &SessionState{}
-	_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, stateStruct)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error getting session state: %v", err), http.StatusInternalServerError)
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
 		return
 	}
 	vars := mux.Vars(r)
 	passedID := vars["id"]
 	reqID, err := parseID(passedID, stateStruct)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error converting user ID: %v", err), http.StatusInternalServerError)
+		WriteError(w, r, errInvalidUserID)
 		return
 	}
 
 	switch r.Method {
 	case http.MethodPut:
 		if reqID != stateStruct.User.ID {
-			http.Error(w, "Action not allowed", http.StatusForbidden)
+			WriteError(w, r, errActionNotAllowed)
 			return
 		}
 		r.ParseMultipartForm(32 << 20)
 		file, handler, err := r.FormFile("avatar")
-		defer file.Close()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error getting image: %v", err), http.StatusForbidden)
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidAvatar, "Missing 'avatar' image file"))
 			return
 		}
+		defer file.Close()
 
 		fileType := strings.Split(handler.Filename, ".")
 		strID := strconv.FormatInt(reqID, 10)
 		// filePath := fmt.Sprintf("/v1/users/%s/avatar", strID)
 		fileName := strID + "." + fileType[len(fileType)-1]
 		f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE, 0666)
-		defer f.Close()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error uploading photo: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, internalError(fmt.Errorf("opening avatar file: %v", err)))
 			return
 		}
+		defer f.Close()
 
 		io.Copy(f, file)
 		if _, err = ctx.UserStore.UpdatePhoto(reqID, fileName); err != nil {
-			http.Error(w, fmt.Sprintf("Error updating photo: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, userStoreError(err))
 			return
 		}
+		ctx.recordAudit(r, audit.ActionAvatarUploaded, stateStruct.User.ID, reqID, audit.OutcomeSuccess, "")
 
 		respond(w, "Image successfully uploaded", http.StatusOK, ContentTypeText)
 
 	case http.MethodGet:
 		user, err := ctx.UserStore.GetByID(reqID)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error getting user: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, userStoreError(err))
 			return
 		}
 		fileName := user.PhotoURL
 		if _, err := os.Stat(fileName); os.IsNotExist(err) {
-			http.Error(w, fmt.Sprintf("Could not find photo: %v", err), http.StatusNotFound)
+			WriteError(w, r, NewHTTPError(http.StatusNotFound, CodeAvatarNotFound, "User has no uploaded avatar"))
 			return
 		}
 		http.ServeFile(w, r, fileName)
 
 	default:
-		http.Error(w, "invalid request", http.StatusMethodNotAllowed)
+		WriteError(w, r, errMethodNotAllowed)
 		return
 	}
 
@@ -322,21 +444,20 @@ func (ctx *Context) ResetHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
 		resetStruct := &users.PassReset{}
-		code, err := decodeReq(w, r, resetStruct)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error with provided data: %v", err), code)
+		if err := decodeReq(r, resetStruct); err != nil {
+			WriteError(w, r, err)
 			return
 		}
 		email := resetStruct.Email
 		user, err := ctx.UserStore.GetByEmail(email)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error getting user: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, userStoreError(err))
 			return
 		}
 
 		randomID := make([]byte, 32)
 		if _, err := rand.Read(randomID); err != nil {
-			http.Error(w, "Error generating random ID: %v", http.StatusInternalServerError)
+			WriteError(w, r, internalError(fmt.Errorf("generating random ID: %v", err)))
 			return
 		}
 		resetPass := base64.URLEncoding.EncodeToString(randomID)
@@ -354,17 +475,18 @@ func (ctx *Context) ResetHandler(w http.ResponseWriter, r *http.Request) {
 			[]byte("This is the password: "+resetPass),
 		)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error sending reset password: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, internalError(fmt.Errorf("sending reset password: %v", err)))
 			return
 		}
 
 		if err = ctx.SessionStore.SavePass(user.Email, resetPass); err != nil {
-			http.Error(w, fmt.Sprintf("Error saving reset password: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, internalError(fmt.Errorf("saving reset password: %v", err)))
 			return
 		}
+		ctx.recordAudit(r, audit.ActionPasswordResetRequested, 0, user.ID, audit.OutcomeSuccess, "")
 		respond(w, "Password reset sent", http.StatusOK, ContentTypeText)
 	default:
-		http.Error(w, "invalid request", http.StatusMethodNotAllowed)
+		WriteError(w, r, errMethodNotAllowed)
 		return
 
 	}
@@ -378,59 +500,75 @@ func (ctx *Context) CompleteResetHandler(w http.ResponseWriter, r *http.Request)
 		email := vars["email"]
 		resetPass, err := ctx.SessionStore.GetReset(email)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error reset password expired: %v", err), http.StatusBadRequest)
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidResetCode, "Reset password has expired"))
 			return
 		}
 		completeReset := &resetInfo{}
-		code, err := decodeReq(w, r, completeReset)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error with provided data: %v", err), code)
+		if err := decodeReq(r, completeReset); err != nil {
+			WriteError(w, r, err)
 			return
 		}
 		if completeReset.Password != completeReset.PasswordConf {
-			http.Error(w, "Passwords don't match", http.StatusBadRequest)
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodePasswordMismatch, "Passwords don't match"))
 			return
 		}
 		if resetPass != completeReset.ResetPass {
-			http.Error(w, "Reset password is wrong", http.StatusBadRequest)
+			ctx.recordAudit(r, audit.ActionPasswordReset, 0, 0, audit.OutcomeFailure, "wrong reset password")
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidResetCode, "Reset password is wrong"))
 			return
 		}
 
 		user, err := ctx.UserStore.GetByEmail(email)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error getting user: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, userStoreError(err))
 			return
 		}
 
 		if err = user.SetPassword(completeReset.Password); err != nil {
-			http.Error(w, fmt.Sprintf("Error setting password hash: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, internalError(err))
 			return
 		}
 
 		_, err = ctx.UserStore.UpdatePassword(user.ID, user.PassHash)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error updating password: %v", err), http.StatusInternalServerError)
+			WriteError(w, r, userStoreError(err))
 			return
 		}
+		ctx.recordAudit(r, audit.ActionPasswordReset, 0, user.ID, audit.OutcomeSuccess, "")
 		respond(w, "New password updated to account", http.StatusOK, ContentTypeText)
 	default:
-		http.Error(w, "invalid request", http.StatusMethodNotAllowed)
+		WriteError(w, r, errMethodNotAllowed)
 		return
 
 	}
 
 }
 
+//beginUserSession begins a new session for the user and records it
+//so that it can be ended if the user is deactivated or suspended
+func (ctx *Context) beginUserSession(user *users.User, w http.ResponseWriter) error {
+	stateStruct := &SessionState{
+		BeginTime: time.Now(),
+		User:      user,
+	}
+	sid, err := sessions.BeginSession(ctx.SigningKey, ctx.SessionStore, stateStruct, w)
+	if err != nil {
+		return err
+	}
+	return ctx.SessionStore.SaveUserSession(user.ID, sid)
+}
+
 //decodeReq checks the header type and decodes the body from the request and
-//populates it to the interface returns http.StatusBadRequest if there is an error
-func decodeReq(w http.ResponseWriter, r *http.Request, value interface{}) (int, error) {
+//populates it to the interface, returning an *HTTPError if there is an error
+func decodeReq(r *http.Request, value interface{}) error {
 	if !strings.HasPrefix(r.Header.Get(HeaderContentType), ContentTypeJSON) {
-		return http.StatusUnsupportedMediaType, errors.New("Invalid media type")
+		return NewHTTPError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+			"Request body must be "+ContentTypeJSON)
 	}
 	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
-		return http.StatusBadRequest, fmt.Errorf("error decoding JSON: %v", err)
+		return NewHTTPError(http.StatusBadRequest, CodeInvalidJSON, fmt.Sprintf("Error decoding JSON: %v", err))
 	}
-	return http.StatusOK, nil
+	return nil
 }
 
 //parseID checks the UserID and converts the string to int if necessary
@@ -447,13 +585,3 @@ func parseID(passedID string, stateStruct *SessionState) (int64, error) {
 		return reqID, nil
 	}
 }
-
-func getClientKey(r *http.Request) string {
-	var ipaddr string
-	if r.Header.Get(HeaderForwardedFor) != "" {
-		ipaddr = strings.Split(r.Header.Get(HeaderForwardedFor), ",")[0]
-	} else {
-		ipaddr = r.RemoteAddr
-	}
-	return ipaddr
-}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
 
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
 	"github.com/gorilla/mux"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
 
@@ -116,7 +119,7 @@ func TestUsersHandler(t *testing.T) {
 				"firstName":"Competent",
 				"lastName": "Gopher"}`,
 			http.StatusUnsupportedMediaType,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{},
 			http.MethodPost,
 			ContentTypeHTML,
@@ -131,7 +134,7 @@ func TestUsersHandler(t *testing.T) {
 				"firstName":"Competent",
 				"lastName": "Gopher"}`,
 			http.StatusBadRequest,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{},
 			http.MethodPost,
 			ContentTypeJSON,
@@ -146,7 +149,7 @@ func TestUsersHandler(t *testing.T) {
 				"firstName":"Competent",
 				"lastName": "Gopher"}`,
 			http.StatusBadRequest,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{},
 			http.MethodPost,
 			ContentTypeJSON,
@@ -161,7 +164,7 @@ func TestUsersHandler(t *testing.T) {
 				"firstName":"Competent",
 				"lastName": "Gopher"}`,
 			http.StatusInternalServerError,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: true,
 			},
@@ -178,7 +181,7 @@ func TestUsersHandler(t *testing.T) {
 				"firstName":"Competent",
 				"lastName": "Gopher"}`,
 			http.StatusInternalServerError,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{},
 			http.MethodPost,
 			ContentTypeJSON,
@@ -193,7 +196,7 @@ func TestUsersHandler(t *testing.T) {
 				"firstName":"Competent",
 				"lastName": "Gopher"}`,
 			http.StatusMethodNotAllowed,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{},
 			http.MethodPatch,
 			ContentTypeJSON,
@@ -287,8 +290,8 @@ func TestSpecificUserHandler(t *testing.T) {
 		{
 			"Invalid user can't get session state",
 			"",
-			http.StatusInternalServerError,
-			ContentTypeText,
+			http.StatusUnauthorized,
+			ContentTypeProblemJSON,
 			// contentTypeJSON,
 			&users.MockStore{
 				TriggerError: false,
@@ -303,8 +306,8 @@ func TestSpecificUserHandler(t *testing.T) {
 		{
 			"Invalid user can't get user",
 			"",
-			http.StatusNotFound,
-			ContentTypeText,
+			http.StatusInternalServerError,
+			ContentTypeProblemJSON,
 			// contentTypeJSON,
 			&users.MockStore{
 				TriggerError: true,
@@ -321,7 +324,7 @@ func TestSpecificUserHandler(t *testing.T) {
 			`{	"firstName":"Incompetent",
 				"lastName": "Whale"}`,
 			http.StatusForbidden,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			// contentTypeJSON,
 			&users.MockStore{
 				TriggerError: false,
@@ -338,7 +341,7 @@ func TestSpecificUserHandler(t *testing.T) {
 			`{	"firstName":"Incompetent",
 				"lastName": "Whale"}`,
 			http.StatusInternalServerError,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			// contentTypeJSON,
 			&users.MockStore{
 				TriggerError: true,
@@ -355,7 +358,23 @@ func TestSpecificUserHandler(t *testing.T) {
 			`{	isdajoisaj
 				"lastName": "Whale"}`,
 			http.StatusBadRequest,
-			ContentTypeText,
+			ContentTypeProblemJSON,
+			// contentTypeJSON,
+			&users.MockStore{
+				TriggerError: false,
+				Result:       createTestUser("normal"),
+			},
+			http.MethodPatch,
+			ContentTypeJSON,
+			"test key",
+			"me",
+			getSessionID("test key"),
+		},
+		{
+			"Invalid profile update",
+			`{	"timeZone":"Mars/Olympus_Mons"}`,
+			http.StatusBadRequest,
+			ContentTypeProblemJSON,
 			// contentTypeJSON,
 			&users.MockStore{
 				TriggerError: false,
@@ -372,7 +391,7 @@ func TestSpecificUserHandler(t *testing.T) {
 			`{	"firstName":"Incompetent",
 				"lastName": "Whale"}`,
 			http.StatusMethodNotAllowed,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			// contentTypeJSON,
 			&users.MockStore{
 				TriggerError: false,
@@ -412,7 +431,7 @@ func TestSpecificUserHandler(t *testing.T) {
 		notifier := NewNotifier()
 		ctx := NewContext(c.signingKey, sessionStore, c.userStore, trie, notifier)
 
-		ctx.SpecificUserHandler(respRec, req)
+		ctx.Authenticated(http.HandlerFunc(ctx.SpecificUserHandler)).ServeHTTP(respRec, req)
 
 		resp := respRec.Result()
 		if resp.StatusCode != c.expectedStatusCode {
@@ -473,7 +492,7 @@ func TestSessionsHandler(t *testing.T) {
 				"password":"test1234"
 			}`,
 			http.StatusUnsupportedMediaType,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -488,7 +507,7 @@ func TestSessionsHandler(t *testing.T) {
 				"password":"test1234"
 			}`,
 			http.StatusBadRequest,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -504,7 +523,7 @@ func TestSessionsHandler(t *testing.T) {
 				"password":"test1234"
 			}`,
 			http.StatusUnauthorized,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: true,
 			},
@@ -519,7 +538,7 @@ func TestSessionsHandler(t *testing.T) {
 				"password":"test123456"
 			}`,
 			http.StatusUnauthorized,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -535,7 +554,7 @@ func TestSessionsHandler(t *testing.T) {
 				"password":"test1234"
 			}`,
 			http.StatusInternalServerError,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -551,7 +570,7 @@ func TestSessionsHandler(t *testing.T) {
 				"password":"test1234"
 			}`,
 			http.StatusMethodNotAllowed,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -608,6 +627,38 @@ func TestSessionsHandler(t *testing.T) {
 	}
 }
 
+func TestSessionsHandlerClientIP(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("error starting redis server: %v", err)
+	}
+	defer server.Close()
+	//the MemStore doesn't count failed attempts
+	sessionStore := sessions.NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr()}), time.Hour)
+	userStore := &users.MockStore{Result: createTestUser("new")}
+	ctx := NewContext("test key", sessionStore, userStore, indexes.NewTrie(), NewNotifier())
+
+	//a forged X-Forwarded-For header doesn't give the client more attempts
+	for i := 0; i < 6; i++ {
+		body := bytes.NewBufferString(`{"email": "test1@uw.edu", "password": "wrongpassword"}`)
+		req := httptest.NewRequest(http.MethodPost, userURL, body)
+		req.RemoteAddr = "203.0.113.7:4321"
+		req.Header.Set(HeaderContentType, ContentTypeJSON)
+		req.Header.Set(HeaderForwardedFor, fmt.Sprintf("198.51.100.%d", i))
+		respRec := httptest.NewRecorder()
+
+		ctx.SessionsHandler(respRec, req)
+		expected := http.StatusUnauthorized
+		if i == 5 {
+			expected = http.StatusTooManyRequests
+		}
+		if respRec.Code != expected {
+			t.Errorf("attempt %d: incorrect status code: expected %d but got %d: %s",
+				i+1, expected, respRec.Code, respRec.Body.String())
+		}
+	}
+}
+
 func TestSpecificSessionHandler(t *testing.T) {
 	cases := []struct {
 		name string
@@ -637,7 +688,7 @@ func TestSpecificSessionHandler(t *testing.T) {
 		{
 			"Non authenticated user",
 			http.StatusForbidden,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -649,8 +700,8 @@ func TestSpecificSessionHandler(t *testing.T) {
 		},
 		{
 			"Error ending session",
-			http.StatusInternalServerError,
-			ContentTypeText,
+			http.StatusUnauthorized,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -663,7 +714,7 @@ func TestSpecificSessionHandler(t *testing.T) {
 		{
 			"Invalid method",
 			http.StatusMethodNotAllowed,
-			ContentTypeText,
+			ContentTypeProblemJSON,
 			&users.MockStore{
 				TriggerError: false,
 				Result:       createTestUser("new"),
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/blocks"
+	"github.com/patrickmn/go-cache"
+)
+
+//BlockListTTL is how long a user's block list is cached. Blocks made through
+//another gateway instance take at most this long to be applied here.
+const BlockListTTL = time.Minute
+
+//BlockList caches users' block lists so they can be checked for every event
+//and search. A nil *BlockList is valid and never blocks anyone.
+type BlockList struct {
+	store   blocks.Store
+	entries *cache.Cache
+}
+
+//NewBlockList constructs a new BlockList
+func NewBlockList(store blocks.Store) *BlockList {
+	return &BlockList{
+		store:   store,
+		entries: cache.New(BlockListTTL, 2*BlockListTTL),
+	}
+}
+
+//get returns the user's blocks from the cache, or from the store on a miss
+func (bl *BlockList) get(userID int64) ([]*blocks.Block, error) {
+	key := strconv.FormatInt(userID, 10)
+	if cached, found := bl.entries.Get(key); found {
+		return cached.([]*blocks.Block), nil
+	}
+	userBlocks, err := bl.store.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	bl.entries.Set(key, userBlocks, cache.DefaultExpiration)
+	return userBlocks, nil
+}
+
+//BlockedIDs returns the IDs of users the user has blocked, not including muted users.
+//Errors are logged and treated as an empty block list.
+func (bl *BlockList) BlockedIDs(userID int64) []int64 {
+	ids := []int64{}
+	if bl == nil {
+		return ids
+	}
+	userBlocks, err := bl.get(userID)
+	if err != nil {
+		log.Printf("error getting blocks for user %d: %v", userID, err)
+		return ids
+	}
+	for _, block := range userBlocks {
+		if !block.Mute {
+			ids = append(ids, block.BlockedID)
+		}
+	}
+	return ids
+}
+
+//Filtered returns true if the recipient has blocked or muted the sender,
+//so events from the sender shouldn't be delivered to the recipient
+func (bl *BlockList) Filtered(recipientID int64, senderID int64) bool {
+	if bl == nil || recipientID == senderID {
+		return false
+	}
+	userBlocks, err := bl.get(recipientID)
+	if err != nil {
+		log.Printf("error getting blocks for user %d: %v", recipientID, err)
+		return false
+	}
+	for _, block := range userBlocks {
+		if block.BlockedID == senderID {
+			return true
+		}
+	}
+	return false
+}
+
+//Invalidate drops the user's cached block list after it changes
+func (bl *BlockList) Invalidate(userID int64) {
+	if bl == nil {
+		return
+	}
+	bl.entries.Delete(strconv.FormatInt(userID, 10))
+}
+
+//BlocksHandler handles requests for the current user's blocked and muted users
+func (ctx *Context) BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	if ctx.Blocks == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Blocking is not available"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		userBlocks, err := ctx.Blocks.get(stateStruct.User.ID)
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("getting blocks: %v", err)))
+			return
+		}
+		respond(w, userBlocks, http.StatusOK, ContentTypeJSON)
+
+	case http.MethodPost:
+		newBlock := &blocks.NewBlock{}
+		if err := decodeReq(r, newBlock); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		block, err := newBlock.ToBlock(stateStruct.User.ID, time.Now())
+		if err != nil {
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidBlock, err.Error()))
+			return
+		}
+		if _, err := ctx.UserStore.GetByID(block.BlockedID); err != nil {
+			WriteError(w, r, userStoreError(err))
+			return
+		}
+		inserted, err := ctx.Blocks.store.Insert(block)
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("inserting block: %v", err)))
+			return
+		}
+		ctx.Blocks.Invalidate(stateStruct.User.ID)
+		respond(w, inserted, http.StatusCreated, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//SpecificBlockHandler handles requests to unblock or unmute a specific user
+func (ctx *Context) SpecificBlockHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	if ctx.Blocks == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Blocking is not available"))
+		return
+	}
+	blockedID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		WriteError(w, r, errInvalidUserID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		if err := ctx.Blocks.store.Delete(stateStruct.User.ID, blockedID); err != nil {
+			if err == blocks.ErrBlockNotFound {
+				WriteError(w, r, NewHTTPError(http.StatusNotFound, CodeBlockNotFound, "User is not blocked"))
+				return
+			}
+			WriteError(w, r, internalError(fmt.Errorf("deleting block: %v", err)))
+			return
+		}
+		ctx.Blocks.Invalidate(stateStruct.User.ID)
+		respond(w, "User unblocked", http.StatusOK, ContentTypeText)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/blocks"
+)
+
+//fakeBlockStore is a blocks.Store holding blocks in memory
+type fakeBlockStore struct {
+	blocks []*blocks.Block
+}
+
+func (s *fakeBlockStore) Insert(block *blocks.Block) (*blocks.Block, error) {
+	s.blocks = append(s.blocks, block)
+	return block, nil
+}
+
+func (s *fakeBlockStore) GetAll(userID int64) ([]*blocks.Block, error) {
+	userBlocks := []*blocks.Block{}
+	for _, block := range s.blocks {
+		if block.UserID == userID {
+			userBlocks = append(userBlocks, block)
+		}
+	}
+	return userBlocks, nil
+}
+
+func (s *fakeBlockStore) Delete(userID int64, blockedID int64) error {
+	for i, block := range s.blocks {
+		if block.UserID == userID && block.BlockedID == blockedID {
+			s.blocks = append(s.blocks[:i], s.blocks[i+1:]...)
+			return nil
+		}
+	}
+	return blocks.ErrBlockNotFound
+}
+
+func TestBlockList(t *testing.T) {
+	store := &fakeBlockStore{blocks: []*blocks.Block{
+		{UserID: 1, BlockedID: 2},
+		{UserID: 1, BlockedID: 3, Mute: true},
+	}}
+	blockList := NewBlockList(store)
+
+	if ids := blockList.BlockedIDs(1); !reflect.DeepEqual(ids, []int64{2}) {
+		t.Errorf("expected only blocked users to be hidden from search, got %v", ids)
+	}
+
+	cases := []struct {
+		name        string
+		recipientID int64
+		senderID    int64
+		expected    bool
+	}{
+		{"Blocked Sender", 1, 2, true},
+		{"Muted Sender", 1, 3, true},
+		{"Other Sender", 1, 4, false},
+		{"Blocker Still Visible To Blocked", 2, 1, false},
+		{"Own Events", 1, 1, false},
+	}
+	for _, c := range cases {
+		if got := blockList.Filtered(c.recipientID, c.senderID); got != c.expected {
+			t.Errorf("case %s: expected %t but got %t", c.name, c.expected, got)
+		}
+	}
+
+	store.Delete(1, 2)
+	if !blockList.Filtered(1, 2) {
+		t.Errorf("expected cached block list to be used until invalidated")
+	}
+	blockList.Invalidate(1)
+	if blockList.Filtered(1, 2) {
+		t.Errorf("expected unblocked user to be delivered after invalidating")
+	}
+
+	var nilList *BlockList
+	if nilList.Filtered(1, 2) || len(nilList.BlockedIDs(1)) != 0 {
+		t.Errorf("expected nil block list to block nobody")
+	}
+}
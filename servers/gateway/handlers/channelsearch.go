@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/channels"
+	"github.com/streadway/amqp"
+)
+
+//Channel event types published by the messaging service
+const (
+	EventChannelNew    = "channel-new"
+	EventChannelUpdate = "channel-update"
+	EventChannelDelete = "channel-delete"
+)
+
+//ChannelEventsExchange is the fanout exchange the messaging service publishes
+//channel events to, so that every gateway gets a copy to keep its channel
+//search index current
+const ChannelEventsExchange = "channel-events"
+
+//maxChannelSearchPages bounds how many pages of MaxSearchCandidates channels
+//a search reads while looking for channels the user can see
+const maxChannelSearchPages = 5
+
+//channelEvent is a channel event published by the messaging service.
+//Only the fields channel search needs are decoded.
+type channelEvent struct {
+	Channel *struct {
+		ID          int64  `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"channel"`
+	ChannelID int64 `json:"channelID"`
+}
+
+//ChannelIndexer keeps a channel search index current with
+//the channel events published to ChannelEventsExchange
+type ChannelIndexer struct {
+	index indexes.Index
+}
+
+//NewChannelIndexer constructs a new ChannelIndexer
+func NewChannelIndexer(index indexes.Index) *ChannelIndexer {
+	return &ChannelIndexer{index: index}
+}
+
+//ObserveEvent indexes new and updated channels, and removes deleted channels
+func (ci *ChannelIndexer) ObserveEvent(eventType string, body []byte) {
+	switch eventType {
+	case EventChannelNew, EventChannelUpdate, EventChannelDelete:
+	default:
+		return
+	}
+	event := &channelEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		log.Printf("error decoding %s event: %v", eventType, err)
+		return
+	}
+	switch {
+	case eventType == EventChannelDelete:
+		ci.index.Delete(event.ChannelID)
+	case event.Channel != nil:
+		c := &channels.Channel{ID: event.Channel.ID, Name: event.Channel.Name, Description: event.Channel.Description}
+		ci.index.Upsert(c.ID, c.SearchFields())
+	}
+}
+
+//ConsumeChannelEvents declares the channel events exchange and a queue bound to
+//it for this gateway alone, and returns the events delivered to the queue.
+//The queue is deleted when the gateway disconnects.
+func ConsumeChannelEvents(channel *amqp.Channel) (<-chan amqp.Delivery, error) {
+	if err := channel.ExchangeDeclare(ChannelEventsExchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("declaring channel events exchange: %v", err)
+	}
+	q, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("declaring channel events queue: %v", err)
+	}
+	if err := channel.QueueBind(q.Name, "", ChannelEventsExchange, false, nil); err != nil {
+		return nil, fmt.Errorf("binding channel events queue: %v", err)
+	}
+	return channel.Consume(q.Name, "", true, true, false, false, nil)
+}
+
+//SyncEvents indexes the channel events delivered to this gateway.
+//Events that are lost are caught up by the periodic reconciliation.
+func (ci *ChannelIndexer) SyncEvents(events <-chan amqp.Delivery) {
+	for event := range events {
+		info := &messageInfo{}
+		if err := json.Unmarshal(event.Body, info); err != nil {
+			log.Printf("error decoding channel event: %v", err)
+			continue
+		}
+		ci.ObserveEvent(info.MessageType, event.Body)
+	}
+}
+
+//ChannelSearchHandler handles requests for the "/v1/search/channels" resource,
+//responding to GET with the channels whose names or descriptions start with every
+//term of the q query string parameter. Private channels are only found by their members.
+func (ctx *Context) ChannelSearchHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	if ctx.ChannelStore == nil || ctx.ChannelIndex == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Channel search is not available"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query().Get("q")
+	if len(strings.TrimSpace(q)) == 0 {
+		WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeMissingQuery, "Missing 'q' query string parameter"))
+		return
+	}
+	terms := indexes.Analyze(q)
+	if len(terms) == 0 {
+		respond(w, []*channels.Channel{}, http.StatusOK, ContentTypeJSON)
+		return
+	}
+	//private channels the user can't see are filtered out a page of
+	//candidates at a time, until enough visible channels are found
+	found := []*channels.Channel{}
+	var seen []int64
+	for page := 0; page < maxChannelSearchPages && len(found) < MaxSearchResults; page++ {
+		ids := ctx.ChannelIndex.FindAll(terms, MaxSearchCandidates, seen)
+		visible, err := ctx.ChannelStore.GetVisible(ids, stateStruct.User.ID)
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("getting channels based on search: %v", err)))
+			return
+		}
+		found = append(found, visible...)
+		if len(ids) < MaxSearchCandidates {
+			break
+		}
+		seen = append(seen, ids...)
+	}
+	channels.SortByRelevance(found, q)
+	if len(found) > MaxSearchResults {
+		found = found[:MaxSearchResults]
+	}
+	respond(w, found, http.StatusOK, ContentTypeJSON)
+}
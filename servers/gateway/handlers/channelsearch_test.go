@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/channels"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
+	"github.com/streadway/amqp"
+)
+
+//fakeChannelStore is a channels.Store holding channels in memory,
+//where only the private channels in `member` are visible
+type fakeChannelStore struct {
+	channels map[int64]*channels.Channel
+	member   map[int64]bool
+	err      error
+}
+
+func (s *fakeChannelStore) LoadChannels(index indexes.Index) error {
+	for _, c := range s.channels {
+		index.Upsert(c.ID, c.SearchFields())
+	}
+	return s.err
+}
+
+func (s *fakeChannelStore) GetVisible(found []int64, userID int64) ([]*channels.Channel, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	visible := []*channels.Channel{}
+	for _, id := range found {
+		if c, ok := s.channels[id]; ok && (!c.Private || s.member[id]) {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
+
+func TestChannelIndexer(t *testing.T) {
+	index := indexes.NewTrie()
+	indexer := NewChannelIndexer(index)
+
+	indexer.ObserveEvent(EventChannelNew, []byte(`{"type":"channel-new","channel":{"id":1,"name":"general","description":"Talk about anything"}}`))
+	if found := index.Find("gen", 5); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("expected the new channel to be found but got %v", found)
+	}
+
+	indexer.ObserveEvent(EventChannelUpdate, []byte(`{"type":"channel-update","channel":{"id":1,"name":"gophers","description":"Talk about anything"}}`))
+	if found := index.Find("gen", 5); found != nil {
+		t.Errorf("expected the old name to be removed but found %v", found)
+	}
+	if found := index.Find("goph", 5); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("expected the renamed channel to be found but got %v", found)
+	}
+
+	//other events and bad json are ignored
+	indexer.ObserveEvent("message-new", []byte(`{"type":"message-new","message":{"id":5}}`))
+	indexer.ObserveEvent(EventChannelNew, []byte(`not json`))
+
+	indexer.ObserveEvent(EventChannelDelete, []byte(`{"type":"channel-delete","channelID":1}`))
+	if index.Len() != 0 {
+		t.Errorf("expected the deleted channel to be removed but the index has %d entries", index.Len())
+	}
+}
+
+func TestChannelIndexerSyncEvents(t *testing.T) {
+	index := indexes.NewTrie()
+	events := make(chan amqp.Delivery, 3)
+	events <- amqp.Delivery{Body: []byte(`{"type":"channel-new","channel":{"id":1,"name":"general"}}`)}
+	events <- amqp.Delivery{Body: []byte(`not json`)}
+	events <- amqp.Delivery{Body: []byte(`{"type":"channel-new","channel":{"id":2,"name":"gophers"}}`)}
+	close(events)
+
+	//bad events are skipped, and syncing returns once the channel closes
+	NewChannelIndexer(index).SyncEvents(events)
+	if found := index.FindAll([]string{"g"}, 5, nil); !reflect.DeepEqual(found, []int64{1, 2}) {
+		t.Errorf("expected both channels to be indexed but got %v", found)
+	}
+}
+
+func TestChannelSearchHandler(t *testing.T) {
+	store := &fakeChannelStore{
+		channels: map[int64]*channels.Channel{
+			1: {ID: 1, Name: "Random", Description: "General nonsense"},
+			2: {ID: 2, Name: "General"},
+			3: {ID: 3, Name: "General Secrets", Private: true},
+			4: {ID: 4, Name: "General Members", Private: true},
+		},
+		member: map[int64]bool{4: true},
+	}
+	index := indexes.NewTrie()
+	store.LoadChannels(index)
+
+	cases := []struct {
+		name               string
+		method             string
+		query              string
+		store              channels.Store
+		authenticated      bool
+		expectedStatusCode int
+		expectedIDs        []int64
+	}{
+		{
+			"Visible Channels By Relevance",
+			http.MethodGet,
+			"?q=general",
+			store,
+			true,
+			http.StatusOK,
+			[]int64{2, 4, 1},
+		},
+		{
+			"No Matches",
+			http.MethodGet,
+			"?q=gophers",
+			store,
+			true,
+			http.StatusOK,
+			[]int64{},
+		},
+		{
+			"Missing Query",
+			http.MethodGet,
+			"?q=%20",
+			store,
+			true,
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"Not Authenticated",
+			http.MethodGet,
+			"?q=general",
+			store,
+			false,
+			http.StatusUnauthorized,
+			nil,
+		},
+		{
+			"Wrong Method",
+			http.MethodPost,
+			"?q=general",
+			store,
+			true,
+			http.StatusMethodNotAllowed,
+			nil,
+		},
+		{
+			"Store Error",
+			http.MethodGet,
+			"?q=general",
+			&fakeChannelStore{err: errors.New("query failed")},
+			true,
+			http.StatusInternalServerError,
+			nil,
+		},
+		{
+			"Not Available",
+			http.MethodGet,
+			"?q=general",
+			nil,
+			true,
+			http.StatusServiceUnavailable,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/v1/search/channels"+c.query, nil)
+		if c.authenticated {
+			req = WithSessionState(req, &SessionState{BeginTime: time.Now(), User: &users.User{ID: 7}})
+		}
+		respRec := httptest.NewRecorder()
+
+		ctx := NewContext("test key", sessions.NewMemStore(time.Hour, time.Minute), &users.MockStore{}, indexes.NewTrie(), NewNotifier())
+		if c.store != nil {
+			ctx.ChannelStore = c.store
+			ctx.ChannelIndex = index
+		}
+
+		ctx.ChannelSearchHandler(respRec, req)
+		resp := respRec.Result()
+		if resp.StatusCode != c.expectedStatusCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d: %s",
+				c.name, c.expectedStatusCode, resp.StatusCode, respRec.Body.String())
+			continue
+		}
+		if c.expectedIDs == nil {
+			continue
+		}
+		found := []*channels.Channel{}
+		if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+			t.Errorf("case %s: error decoding response: %v", c.name, err)
+			continue
+		}
+		ids := []int64{}
+		for _, ch := range found {
+			ids = append(ids, ch.ID)
+		}
+		if !reflect.DeepEqual(ids, c.expectedIDs) {
+			t.Errorf("case %s: expected channels %v but got %v", c.name, c.expectedIDs, ids)
+		}
+	}
+}
+
+func TestChannelSearchHandlerPrivateCandidates(t *testing.T) {
+	//more private channels are found before the visible one than fit in a page of candidates
+	store := &fakeChannelStore{channels: map[int64]*channels.Channel{}}
+	for id := int64(1); id <= MaxSearchCandidates+20; id++ {
+		store.channels[id] = &channels.Channel{ID: id, Name: "General Secrets", Private: true}
+	}
+	store.channels[500] = &channels.Channel{ID: 500, Name: "General"}
+	index := indexes.NewTrie()
+	store.LoadChannels(index)
+
+	ctx := NewContext("test key", sessions.NewMemStore(time.Hour, time.Minute), &users.MockStore{}, indexes.NewTrie(), NewNotifier())
+	ctx.ChannelStore = store
+	ctx.ChannelIndex = index
+	req := httptest.NewRequest(http.MethodGet, "/v1/search/channels?q=general", nil)
+	req = WithSessionState(req, &SessionState{BeginTime: time.Now(), User: &users.User{ID: 7}})
+	respRec := httptest.NewRecorder()
+
+	ctx.ChannelSearchHandler(respRec, req)
+	if respRec.Code != http.StatusOK {
+		t.Fatalf("incorrect status code: expected %d but got %d: %s", http.StatusOK, respRec.Code, respRec.Body.String())
+	}
+	found := []*channels.Channel{}
+	if err := json.NewDecoder(respRec.Body).Decode(&found); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != 500 {
+		t.Errorf("expected the visible channel to be found but got %v", found)
+	}
+}
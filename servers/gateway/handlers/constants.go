@@ -33,6 +33,15 @@ const HeaderContentType = "Content-Type"
 // ContentTypeJSON is a constant
 const ContentTypeJSON = "application/json"
 
+// ContentTypeProblemJSON is a constant for RFC 7807 problem details
+const ContentTypeProblemJSON = "application/problem+json"
+
+// ContentTypeMergePatchJSON is a constant for RFC 7396 JSON merge patches
+const ContentTypeMergePatchJSON = "application/merge-patch+json"
+
+// ContentTypeZip is a constant
+const ContentTypeZip = "application/zip"
+
 // ContentTypeHTML is a constant
 const ContentTypeHTML = "text/html"
 
@@ -42,11 +51,14 @@ const ContentTypeText = "text/plain"
 // HeaderRetryAfter is a constant
 const HeaderRetryAfter = "Retry-After"
 
+// HeaderLocation is a constant
+const HeaderLocation = "Location"
+
+// HeaderContentDisposition is a constant
+const HeaderContentDisposition = "Content-Disposition"
+
 // HeaderForwardedFor is a constant
 const HeaderForwardedFor = "X-Forwarded-For"
 
 //HeaderUser is a constant
 const HeaderUser = "X-User"
-
-//MaxConnRetries is a constant for number of rabbit mq connection retries
-const MaxConnRetries = 5
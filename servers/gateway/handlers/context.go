@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"github.com/info344-s18/challenges-ask710/servers/gateway/exports"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/channels"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/invites"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/preferences"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/presence"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
 )
 
@@ -19,17 +24,45 @@ type Context struct {
 	SigningKey   string
 	SessionStore sessions.Store
 	UserStore    users.Store
-	Trie         *indexes.Trie
+	Index        indexes.Index
 	Notifier     *Notifier
+	//InviteStore is optional, invites are unavailable when it is nil
+	InviteStore invites.Store
+	//Registration is optional, anyone may sign up when it is nil
+	Registration *RegistrationPolicy
+	//PresenceStore is optional, presence is unavailable when it is nil
+	PresenceStore presence.Store
+	//PreferencesStore is optional, preferences are unavailable when it is nil
+	PreferencesStore preferences.Store
+	//Blocks is optional, nobody is blocked when it is nil
+	Blocks *BlockList
+	//Audit is optional, actions aren't audited when it is nil
+	Audit AuditSink
+	//TrustedProxies are the addresses allowed to say who the client is with
+	//X-Forwarded-For, which is ignored from every other address
+	TrustedProxies []string
+	//Exporter is optional, data export is unavailable when it is nil
+	Exporter *exports.Exporter
+	//Events is optional, events aren't published when it is nil
+	Events EventPublisher
+	//UserEvents is optional, other gateways aren't told about changed users when it is nil
+	UserEvents EventPublisher
+	//SearchBoost is optional, search results aren't boosted when it is nil
+	SearchBoost *SearchBooster
+	//ChannelStore and ChannelIndex are optional, channel search is unavailable when either is nil
+	ChannelStore channels.Store
+	ChannelIndex indexes.Index
+	//MQ is optional, the message queue health is unavailable when it is nil
+	MQ *MQSupervisor
 }
 
 //NewContext constructs a new Context
-func NewContext(signingKey string, sessionStore sessions.Store, userStore users.Store, trie *indexes.Trie, notifier *Notifier) *Context {
+func NewContext(signingKey string, sessionStore sessions.Store, userStore users.Store, index indexes.Index, notifier *Notifier) *Context {
 	return &Context{
 		SigningKey:   signingKey,
 		SessionStore: sessionStore,
 		UserStore:    userStore,
-		Trie:         trie,
+		Index:        index,
 		Notifier:     notifier,
 	}
 }
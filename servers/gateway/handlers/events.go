@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/streadway/amqp"
+)
+
+//EventUserUpdated is the type of event sent when a user's profile changes
+const EventUserUpdated = "user-updated"
+
+//userEvent is sent to WebSocket clients when a user changes
+type userEvent struct {
+	Type string      `json:"type"`
+	User *users.User `json:"user"`
+}
+
+//EventPublisher publishes events to be delivered to WebSocket clients
+type EventPublisher interface {
+	Publish(event interface{}) error
+}
+
+//MQPublisher is an EventPublisher that publishes events as
+//JSON to a RabbitMQ queue or exchange
+type MQPublisher struct {
+	channel  *amqp.Channel
+	exchange string
+	queue    string
+	//an amqp.Channel must not be used to publish from several goroutines at once
+	mx sync.Mutex
+}
+
+//NewMQPublisher constructs a new MQPublisher that publishes
+//to the queue, such as the one the Notifier consumes
+func NewMQPublisher(channel *amqp.Channel, queue string) *MQPublisher {
+	return &MQPublisher{
+		channel: channel,
+		queue:   queue,
+	}
+}
+
+//NewMQExchangePublisher constructs a new MQPublisher that publishes to
+//the exchange, which delivers the events to every queue bound to it
+func NewMQExchangePublisher(channel *amqp.Channel, exchange string) *MQPublisher {
+	return &MQPublisher{
+		channel:  channel,
+		exchange: exchange,
+	}
+}
+
+//SetChannel makes the publisher publish on the channel, such as
+//the channel of a new connection after the old one was lost
+func (p *MQPublisher) SetChannel(channel *amqp.Channel) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.channel = channel
+}
+
+//Publish encodes the event as JSON and publishes it to the queue
+//as a persistent message, so it outlives a restart of the broker
+func (p *MQPublisher) Publish(event interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding event: %v", err)
+	}
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.channel == nil {
+		return fmt.Errorf("publishing event: not connected to the message queue")
+	}
+	err = p.channel.Publish(p.exchange, p.queue, false, false, amqp.Publishing{
+		ContentType:  ContentTypeJSON,
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+	if err != nil {
+		return fmt.Errorf("publishing event: %v", err)
+	}
+	return nil
+}
+
+//publishEvent publishes the event if the Context has an EventPublisher,
+//errors are logged as the request that caused the event has already succeeded
+func (ctx *Context) publishEvent(event interface{}) {
+	if ctx.Events == nil {
+		return
+	}
+	if err := ctx.Events.Publish(event); err != nil {
+		log.Printf("error publishing event: %v", err)
+	}
+}
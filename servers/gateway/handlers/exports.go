@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/exports"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/audit"
+)
+
+//Types of event sent when an export finishes
+const (
+	EventExportReady  = "export-ready"
+	EventExportFailed = "export-failed"
+)
+
+//ExportLinkTTL is how long a signed export download link works for
+const ExportLinkTTL = 24 * time.Hour
+
+//ExportRetention is how long exports are kept before they are purged
+const ExportRetention = 7 * 24 * time.Hour
+
+//exportEvent is sent to the user's WebSocket clients when their export finishes
+type exportEvent struct {
+	Type    string       `json:"type"`
+	Export  *exports.Job `json:"export"`
+	UserIDs []int64      `json:"userIDs"`
+}
+
+//exportDownloadURL returns a signed link to download the job's archive
+//that expires ExportLinkTTL after `now`
+func (ctx *Context) exportDownloadURL(job *exports.Job, now time.Time) string {
+	expires := now.Add(ExportLinkTTL)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", exports.Sign(ctx.SigningKey, job.ID, expires))
+	return "/v1/exports/" + job.ID + "/download?" + query.Encode()
+}
+
+//ExportDone notifies the user over WebSocket that their export has finished.
+//It should be set as the Exporter's Done function.
+func (ctx *Context) ExportDone(job *exports.Job) {
+	eventType := EventExportFailed
+	if job.Status == exports.StatusReady {
+		eventType = EventExportReady
+		job.DownloadURL = ctx.exportDownloadURL(job, time.Now())
+	}
+	ctx.publishEvent(&exportEvent{Type: eventType, Export: job, UserIDs: []int64{job.UserID}})
+}
+
+//ExportsHandler handles requests to export the current user's data.
+//The export is built in the background, and the user is sent an
+//export-ready event with a download link when it is finished.
+func (ctx *Context) ExportsHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	if ctx.Exporter == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Data export is not available"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		job, err := ctx.Exporter.Start(stateStruct.User.ID)
+		if err == exports.ErrTooSoon {
+			WriteError(w, r, NewHTTPError(http.StatusTooManyRequests, CodeExportTooSoon,
+				"An export was started too recently, please wait for it to finish or try again later"))
+			return
+		}
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("starting export: %v", err)))
+			return
+		}
+		ctx.recordAudit(r, audit.ActionDataExported, stateStruct.User.ID, stateStruct.User.ID, audit.OutcomeSuccess, "")
+		w.Header().Set(HeaderLocation, "/v1/users/me/exports/"+job.ID)
+		respond(w, job, http.StatusAccepted, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//SpecificExportHandler handles requests for the status of one of the current user's exports
+func (ctx *Context) SpecificExportHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	if ctx.Exporter == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Data export is not available"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		job, err := ctx.Exporter.Get(mux.Vars(r)["id"])
+		if err == nil && job.UserID != stateStruct.User.ID {
+			err = exports.ErrJobNotFound
+		}
+		if err != nil {
+			WriteError(w, r, exportError(err))
+			return
+		}
+		if job.Status == exports.StatusReady {
+			job.DownloadURL = ctx.exportDownloadURL(job, time.Now())
+		}
+		respond(w, job, http.StatusOK, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//ExportDownloadHandler serves an export's archive to anyone with a valid signed link,
+//so it can be downloaded by a browser without the session's Authorization header
+func (ctx *Context) ExportDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if ctx.Exporter == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Data export is not available"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		id := mux.Vars(r)["id"]
+		expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+		if err != nil {
+			WriteError(w, r, NewHTTPError(http.StatusForbidden, CodeInvalidDownloadLink, "Download link is invalid"))
+			return
+		}
+		err = exports.Verify(ctx.SigningKey, id, time.Unix(expires, 0), r.URL.Query().Get("signature"), time.Now())
+		switch err {
+		case nil:
+		case exports.ErrLinkExpired:
+			WriteError(w, r, NewHTTPError(http.StatusGone, CodeInvalidDownloadLink, "Download link has expired"))
+			return
+		default:
+			WriteError(w, r, NewHTTPError(http.StatusForbidden, CodeInvalidDownloadLink, "Download link is invalid"))
+			return
+		}
+
+		archive, err := ctx.Exporter.Open(id)
+		if err != nil {
+			WriteError(w, r, exportError(err))
+			return
+		}
+		defer archive.Close()
+		info, err := archive.Stat()
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("reading export: %v", err)))
+			return
+		}
+		w.Header().Set(HeaderContentDisposition, `attachment; filename="export-`+id+`.zip"`)
+		w.Header().Set(HeaderContentType, ContentTypeZip)
+		http.ServeContent(w, r, "", info.ModTime(), archive)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//exportError converts an error from the Exporter into an HTTPError
+func exportError(err error) *HTTPError {
+	if err == exports.ErrJobNotFound {
+		return NewHTTPError(http.StatusNotFound, CodeExportNotFound, "Export not found")
+	}
+	return internalError(err)
+}
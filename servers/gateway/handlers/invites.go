@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/invites"
+)
+
+//InvitesHandler handles requests for the "invites" resource.
+//Only admins may create and list invites, so it should be wrapped with PolicyAdmin.
+func (ctx *Context) InvitesHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil || !stateStruct.User.IsAdmin() {
+		WriteError(w, r, errActionNotAllowed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		newInvite := &invites.NewInvite{}
+		if err := decodeReq(r, newInvite); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		invite, err := newInvite.ToInvite(stateStruct.User.ID, time.Now())
+		if err != nil {
+			WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidInvite, err.Error()))
+			return
+		}
+		inserted, err := ctx.InviteStore.Insert(invite)
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("inserting invite: %v", err)))
+			return
+		}
+		respond(w, inserted, http.StatusCreated, ContentTypeJSON)
+
+	case http.MethodGet:
+		allInvites, err := ctx.InviteStore.GetAll()
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("getting invites: %v", err)))
+			return
+		}
+		respond(w, allInvites, http.StatusOK, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//SpecificInviteHandler handles requests for a specific invite code
+func (ctx *Context) SpecificInviteHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil || !stateStruct.User.IsAdmin() {
+		WriteError(w, r, errActionNotAllowed)
+		return
+	}
+	code := mux.Vars(r)["code"]
+
+	switch r.Method {
+	case http.MethodGet:
+		invite, err := ctx.InviteStore.GetByCode(code)
+		if err != nil {
+			WriteError(w, r, inviteStoreError(err))
+			return
+		}
+		respond(w, invite, http.StatusOK, ContentTypeJSON)
+
+	case http.MethodDelete:
+		if err := ctx.InviteStore.Delete(code); err != nil {
+			WriteError(w, r, inviteStoreError(err))
+			return
+		}
+		respond(w, "Invite deleted", http.StatusOK, ContentTypeText)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//inviteStoreError converts an error from the invite store into an HTTPError
+func inviteStoreError(err error) *HTTPError {
+	if err == invites.ErrInviteNotFound {
+		return NewHTTPError(http.StatusNotFound, CodeInviteNotFound, "Invite not found")
+	}
+	return internalError(err)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
+)
+
+//Policy decides who may make requests to a route
+type Policy int
+
+const (
+	//PolicyPublic lets anyone make requests. The session state is still
+	//added to the request context when the request has a valid session.
+	PolicyPublic Policy = iota
+	//PolicyAuthenticated requires a valid session
+	PolicyAuthenticated
+	//PolicyAdmin requires a valid session belonging to an admin
+	PolicyAdmin
+)
+
+//contextKey is the type of keys for values this package adds to a request context
+type contextKey int
+
+//sessionStateKey is the request context key for the current SessionState
+const sessionStateKey contextKey = 0
+
+//AuthHandler is a middleware handler that resolves the session once,
+//adds the SessionState to the request context and enforces a Policy
+type AuthHandler struct {
+	ctx     *Context
+	policy  Policy
+	handler http.Handler
+}
+
+//NewAuthHandler constructs a new AuthHandler middleware handler
+func NewAuthHandler(ctx *Context, policy Policy, handler http.Handler) *AuthHandler {
+	return &AuthHandler{ctx, policy, handler}
+}
+
+//Public wraps the handler so it may be requested by anyone
+func (ctx *Context) Public(handler http.Handler) *AuthHandler {
+	return NewAuthHandler(ctx, PolicyPublic, handler)
+}
+
+//Authenticated wraps the handler so it may only be requested with a valid session
+func (ctx *Context) Authenticated(handler http.Handler) *AuthHandler {
+	return NewAuthHandler(ctx, PolicyAuthenticated, handler)
+}
+
+//Admin wraps the handler so it may only be requested by admins
+func (ctx *Context) Admin(handler http.Handler) *AuthHandler {
+	return NewAuthHandler(ctx, PolicyAdmin, handler)
+}
+
+//ServeHTTP implements the http.Handler interface for the AuthHandler
+func (ah *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stateStruct := &SessionState{}
+	_, err := sessions.GetState(r, ah.ctx.SigningKey, ah.ctx.SessionStore, stateStruct)
+	if err != nil || stateStruct.User == nil {
+		if ah.policy != PolicyPublic {
+			WriteError(w, r, errUnauthenticated)
+			return
+		}
+		ah.handler.ServeHTTP(w, r)
+		return
+	}
+	if ah.policy == PolicyAdmin && !stateStruct.User.IsAdmin() {
+		WriteError(w, r, errActionNotAllowed)
+		return
+	}
+	ah.handler.ServeHTTP(w, WithSessionState(r, stateStruct))
+}
+
+//WithSessionState returns a shallow copy of the request
+//with the SessionState added to its context
+func WithSessionState(r *http.Request, state *SessionState) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionStateKey, state))
+}
+
+//GetSessionState returns the SessionState added to the request context
+//by the AuthHandler, or nil if the request has no valid session
+func GetSessionState(r *http.Request) *SessionState {
+	state, _ := r.Context().Value(sessionStateKey).(*SessionState)
+	return state
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
+)
+
+func TestAuthHandler(t *testing.T) {
+	admin := createTestUser("normal")
+	admin.Role = users.RoleAdmin
+	member := createTestUser("normal")
+	member.Role = users.RoleMember
+
+	cases := []struct {
+		name               string
+		policy             Policy
+		user               *users.User
+		expectedStatusCode int
+		expectState        bool
+	}{
+		{
+			"Public Without Session",
+			PolicyPublic,
+			nil,
+			http.StatusOK,
+			false,
+		},
+		{
+			"Public With Session",
+			PolicyPublic,
+			member,
+			http.StatusOK,
+			true,
+		},
+		{
+			"Authenticated Without Session",
+			PolicyAuthenticated,
+			nil,
+			http.StatusUnauthorized,
+			false,
+		},
+		{
+			"Authenticated With Session",
+			PolicyAuthenticated,
+			member,
+			http.StatusOK,
+			true,
+		},
+		{
+			"Admin Without Session",
+			PolicyAdmin,
+			nil,
+			http.StatusUnauthorized,
+			false,
+		},
+		{
+			"Admin As Member",
+			PolicyAdmin,
+			member,
+			http.StatusForbidden,
+			false,
+		},
+		{
+			"Admin As Admin",
+			PolicyAdmin,
+			admin,
+			http.StatusOK,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		sessionStore := sessions.NewMemStore(time.Hour, time.Minute)
+		ctx := NewContext("test key", sessionStore, &users.MockStore{}, indexes.NewTrie(), NewNotifier())
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+		if c.user != nil {
+			sid := getSessionID("test key")
+			sessionStore.Save(sid, &SessionState{BeginTime: time.Now(), User: c.user})
+			req.Header.Set("Authorization", "Bearer "+sid.String())
+		}
+
+		var gotState *SessionState
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotState = GetSessionState(r)
+		})
+		respRec := httptest.NewRecorder()
+		NewAuthHandler(ctx, c.policy, handler).ServeHTTP(respRec, req)
+
+		if respRec.Code != c.expectedStatusCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d",
+				c.name, c.expectedStatusCode, respRec.Code)
+		}
+		if c.expectState && (gotState == nil || gotState.User.ID != c.user.ID) {
+			t.Errorf("case %s: expected session state in request context", c.name)
+		}
+		if !c.expectState && gotState != nil {
+			t.Errorf("case %s: unexpected session state in request context", c.name)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+//Backoff between attempts to connect to RabbitMQ, which doubles
+//after each failed attempt up to the maximum
+const (
+	MQBackoffMin = time.Second
+	MQBackoffMax = 30 * time.Second
+)
+
+//DeadLetterExchange is the exchange messages the Notifier can't decode are
+//dead-lettered to, and DeadLetterQueue is the durable queue that keeps them
+//for someone to look at
+const (
+	DeadLetterExchange = "dead-letters"
+	DeadLetterQueue    = "dead-letters"
+)
+
+//MQStatus describes the supervisor's connection to RabbitMQ
+type MQStatus struct {
+	Connected   bool       `json:"connected"`
+	ConnectedAt *time.Time `json:"connectedAt,omitempty"`
+	//Reconnects counts the connections made after the first
+	Reconnects  int        `json:"reconnects"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+//mqConnection is an open connection and channel to RabbitMQ
+type mqConnection interface {
+	//Channel returns the channel opened on the connection
+	Channel() *amqp.Channel
+	//Closed gets the error the connection or its channel closed with,
+	//which is nil if it was closed on purpose
+	Closed() <-chan *amqp.Error
+	//Close closes the connection
+	Close() error
+}
+
+//amqpConnection is an mqConnection to a RabbitMQ server
+type amqpConnection struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	closed  chan *amqp.Error
+}
+
+func (c *amqpConnection) Channel() *amqp.Channel     { return c.channel }
+func (c *amqpConnection) Closed() <-chan *amqp.Error { return c.closed }
+func (c *amqpConnection) Close() error               { return c.conn.Close() }
+
+//dialMQ connects to RabbitMQ and opens a channel. Errors that close only the
+//channel are reported as closing the connection, since the consumers stop either way.
+func dialMQ(url string) (mqConnection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("opening channel: %v", err)
+	}
+	//amqp closes each notification channel it is given, so they can't be shared
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+	closed := make(chan *amqp.Error, 1)
+	go func() {
+		select {
+		case err := <-connClosed:
+			closed <- err
+		case err := <-channelClosed:
+			closed <- err
+		}
+	}()
+	return &amqpConnection{conn: conn, channel: channel, closed: closed}, nil
+}
+
+//MQSupervisor keeps a connection to RabbitMQ open, reconnecting with backoff
+//whenever it is lost, and runs Setup on the channel of every new connection
+//so that the topology is declared and the consumers started again
+type MQSupervisor struct {
+	addr string
+	//Setup declares the exchanges and queues and starts consuming on the
+	//channel, and must be set before Run is called
+	Setup func(channel *amqp.Channel) error
+	dial  func(url string) (mqConnection, error)
+	stop  chan struct{}
+
+	mx            sync.Mutex
+	status        MQStatus
+	everConnected bool
+}
+
+//NewMQSupervisor constructs a new MQSupervisor for the server at the address
+func NewMQSupervisor(addr string) *MQSupervisor {
+	return &MQSupervisor{
+		addr: addr,
+		dial: dialMQ,
+		stop: make(chan struct{}),
+	}
+}
+
+//Run connects to RabbitMQ and runs Setup, then waits for the connection to be
+//lost and does it again. It waits MQBackoffMin after a failed attempt, twice
+//as long after each further failure, and returns once Stop is called.
+func (s *MQSupervisor) Run() {
+	backoff := MQBackoffMin
+	for {
+		conn, err := s.connect()
+		if err != nil {
+			s.failed(err)
+			log.Printf("error connecting to MQ, will retry in %v: %v", backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-s.stop:
+				return
+			}
+			if backoff *= 2; backoff > MQBackoffMax {
+				backoff = MQBackoffMax
+			}
+			continue
+		}
+		backoff = MQBackoffMin
+		select {
+		case amqpErr := <-conn.Closed():
+			conn.Close()
+			err := fmt.Errorf("connection closed")
+			if amqpErr != nil {
+				err = fmt.Errorf("connection lost: %v", amqpErr)
+			}
+			s.failed(err)
+			log.Printf("MQ %v, reconnecting", err)
+		case <-s.stop:
+			conn.Close()
+			return
+		}
+	}
+}
+
+//Stop stops Run and closes the connection
+func (s *MQSupervisor) Stop() {
+	close(s.stop)
+}
+
+//connect dials RabbitMQ and runs Setup, closing the connection if Setup fails
+func (s *MQSupervisor) connect() (mqConnection, error) {
+	conn, err := s.dial("amqp://" + s.addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Setup(conn.Channel()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("setting up: %v", err)
+	}
+	now := time.Now()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.everConnected {
+		s.status.Reconnects++
+	}
+	s.everConnected = true
+	s.status.Connected = true
+	s.status.ConnectedAt = &now
+	log.Printf("successfully connected to MQ at %s", s.addr)
+	return conn, nil
+}
+
+//failed records that the supervisor is not connected because of the error
+func (s *MQSupervisor) failed(err error) {
+	now := time.Now()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.status.Connected = false
+	s.status.ConnectedAt = nil
+	s.status.LastError = err.Error()
+	s.status.LastErrorAt = &now
+}
+
+//Status returns a copy of the supervisor's status
+func (s *MQSupervisor) Status() MQStatus {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.status
+}
+
+//ConsumeNotifications declares the durable queue the Notifier consumes and
+//the dead-letter exchange and queue its rejected messages go to, and returns
+//the messages delivered to the queue. They must be acknowledged.
+func ConsumeNotifications(channel *amqp.Channel, queue string) (<-chan amqp.Delivery, error) {
+	if err := channel.ExchangeDeclare(DeadLetterExchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("declaring dead-letter exchange: %v", err)
+	}
+	if _, err := channel.QueueDeclare(DeadLetterQueue, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("declaring dead-letter queue: %v", err)
+	}
+	if err := channel.QueueBind(DeadLetterQueue, "", DeadLetterExchange, false, nil); err != nil {
+		return nil, fmt.Errorf("binding dead-letter queue: %v", err)
+	}
+	q, err := channel.QueueDeclare(queue, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange": DeadLetterExchange,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("declaring notifications queue: %v", err)
+	}
+	return channel.Consume(q.Name, "", false, false, false, false, nil)
+}
+
+//mqHealth is the health of the gateway's use of RabbitMQ that anyone may see
+type mqHealth struct {
+	Connected bool `json:"connected"`
+}
+
+//mqDetails is the status of the connection to RabbitMQ and of the Notifier's
+//consumer, which may reveal internal addresses so only admins may see it
+type mqDetails struct {
+	MQStatus
+	Notifications ConsumerStatus `json:"notifications"`
+}
+
+//MQHealthHandler handles requests for the "/v1/health/mq" resource, responding
+//to GET with whether the gateway is connected to RabbitMQ. The status code is
+//503 unless it is connected and the Notifier is consuming, so it can be used
+//as a health check.
+func (ctx *Context) MQHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+	if ctx.MQ == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "The message queue is not configured"))
+		return
+	}
+	health := &mqHealth{Connected: ctx.MQ.Status().Connected}
+	status := http.StatusOK
+	if !health.Connected || !ctx.Notifier.Status().Running {
+		status = http.StatusServiceUnavailable
+	}
+	respond(w, health, status, ContentTypeJSON)
+}
+
+//MQDetailsHandler handles requests for the "/v1/health/mq/details" resource,
+//responding to GET with the status of the connection to RabbitMQ, including
+//the last error, and of the Notifier's consumer. Only admins may see it,
+//so it should be wrapped with PolicyAdmin.
+func (ctx *Context) MQDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil || !stateStruct.User.IsAdmin() {
+		WriteError(w, r, errActionNotAllowed)
+		return
+	}
+	if r.Method != http.MethodGet {
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+	if ctx.MQ == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "The message queue is not configured"))
+		return
+	}
+	respond(w, &mqDetails{MQStatus: ctx.MQ.Status(), Notifications: ctx.Notifier.Status()}, http.StatusOK, ContentTypeJSON)
+}
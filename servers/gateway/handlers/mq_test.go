@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/streadway/amqp"
+)
+
+//fakeMQConnection is an mqConnection that is lost when closed is sent to
+type fakeMQConnection struct {
+	closed    chan *amqp.Error
+	closeOnce sync.Once
+	isClosed  chan struct{}
+}
+
+func newFakeMQConnection() *fakeMQConnection {
+	return &fakeMQConnection{closed: make(chan *amqp.Error, 1), isClosed: make(chan struct{})}
+}
+
+func (c *fakeMQConnection) Channel() *amqp.Channel     { return nil }
+func (c *fakeMQConnection) Closed() <-chan *amqp.Error { return c.closed }
+func (c *fakeMQConnection) Close() error {
+	c.closeOnce.Do(func() { close(c.isClosed) })
+	return nil
+}
+
+//fakeAcknowledger records how deliveries were acknowledged
+type fakeAcknowledger struct {
+	mx       sync.Mutex
+	acked    []uint64
+	rejected []uint64
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.rejected = append(a.rejected, tag)
+	return nil
+}
+
+//waitForStatus waits until the supervisor's status satisfies the condition
+func waitForStatus(t *testing.T, s *MQSupervisor, cond func(MQStatus) bool) MQStatus {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		if status := s.Status(); cond(status) {
+			return status
+		}
+	}
+	t.Fatalf("timed out waiting for the MQ status, last was %+v", s.Status())
+	return MQStatus{}
+}
+
+func TestMQSupervisor(t *testing.T) {
+	conns := make(chan *fakeMQConnection, 10)
+	dials := 0
+	s := NewMQSupervisor("localhost:5672")
+	s.dial = func(url string) (mqConnection, error) {
+		dials++
+		//the first attempt fails, as if RabbitMQ was still starting
+		if dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		conn := newFakeMQConnection()
+		conns <- conn
+		return conn, nil
+	}
+	setups := make(chan struct{}, 10)
+	s.Setup = func(channel *amqp.Channel) error {
+		setups <- struct{}{}
+		return nil
+	}
+	go s.Run()
+
+	status := waitForStatus(t, s, func(status MQStatus) bool { return status.Connected })
+	if status.Reconnects != 0 || status.LastError != "connection refused" || status.ConnectedAt == nil {
+		t.Errorf("expected the first connection after the failed dial, got %+v", status)
+	}
+	first := <-conns
+
+	//losing the connection reconnects and runs Setup again
+	first.closed <- &amqp.Error{Code: amqp.ConnectionForced, Reason: "broker restarted"}
+	status = waitForStatus(t, s, func(status MQStatus) bool { return status.Reconnects == 1 && status.Connected })
+	if !strings.Contains(status.LastError, "broker restarted") {
+		t.Errorf("expected the last error to be the lost connection, got %q", status.LastError)
+	}
+	select {
+	case <-first.isClosed:
+	default:
+		t.Error("expected the lost connection to be closed")
+	}
+	if len(setups) != 2 {
+		t.Errorf("expected Setup to run for each connection, got %d", len(setups))
+	}
+
+	//stopping closes the current connection
+	second := <-conns
+	s.Stop()
+	select {
+	case <-second.isClosed:
+	case <-time.After(time.Second):
+		t.Error("expected stopping to close the connection")
+	}
+}
+
+func TestMQSupervisorSetupError(t *testing.T) {
+	conn := newFakeMQConnection()
+	s := NewMQSupervisor("localhost:5672")
+	s.dial = func(url string) (mqConnection, error) { return conn, nil }
+	s.Setup = func(channel *amqp.Channel) error { return errors.New("queue declared with other arguments") }
+	go s.Run()
+	defer s.Stop()
+
+	status := waitForStatus(t, s, func(status MQStatus) bool { return len(status.LastError) > 0 })
+	if status.Connected || !strings.Contains(status.LastError, "other arguments") {
+		t.Errorf("expected the supervisor not to be connected, got %+v", status)
+	}
+	select {
+	case <-conn.isClosed:
+	case <-time.After(time.Second):
+		t.Error("expected the connection to be closed when Setup fails")
+	}
+}
+
+func TestProcessMessagesDeadLetters(t *testing.T) {
+	n := NewNotifier()
+	fast := &client{userID: 1, send: make(chan []byte, 10), done: make(chan struct{})}
+	n.currConnections[1] = []*client{fast}
+	ack := &fakeAcknowledger{}
+	messages := make(chan amqp.Delivery, 3)
+	messages <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte(`{"type":"message-new"}`)}
+	messages <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 2, Body: []byte(`not json`)}
+	messages <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 3, Body: []byte(`{"type":"message-new"}`)}
+	close(messages)
+
+	//the loop keeps going after the bad message, and returns once the channel closes
+	done := make(chan struct{})
+	go func() {
+		n.ProcessMessages(messages)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the messages to be processed")
+	}
+
+	if len(ack.acked) != 2 || ack.acked[0] != 1 || ack.acked[1] != 3 {
+		t.Errorf("expected messages 1 and 3 to be acknowledged, got %v", ack.acked)
+	}
+	if len(ack.rejected) != 1 || ack.rejected[0] != 2 {
+		t.Errorf("expected message 2 to be dead-lettered, got %v", ack.rejected)
+	}
+	if len(fast.send) != 2 {
+		t.Errorf("expected 2 events to be broadcast, got %d", len(fast.send))
+	}
+	status := n.Status()
+	if status.Running || status.Processed != 2 || status.DeadLettered != 1 || status.LastProcessedAt == nil {
+		t.Errorf("unexpected consumer status %+v", status)
+	}
+}
+
+func TestMQHealthHandler(t *testing.T) {
+	connected := NewMQSupervisor("localhost:5672")
+	connected.status.Connected = true
+	cases := []struct {
+		name               string
+		method             string
+		mq                 *MQSupervisor
+		consuming          bool
+		expectedStatusCode int
+	}{
+		{
+			"Healthy",
+			http.MethodGet,
+			connected,
+			true,
+			http.StatusOK,
+		},
+		{
+			"Not Consuming",
+			http.MethodGet,
+			connected,
+			false,
+			http.StatusServiceUnavailable,
+		},
+		{
+			"Not Connected",
+			http.MethodGet,
+			NewMQSupervisor("localhost:5672"),
+			true,
+			http.StatusServiceUnavailable,
+		},
+		{
+			"Not Configured",
+			http.MethodGet,
+			nil,
+			true,
+			http.StatusServiceUnavailable,
+		},
+		{
+			"Wrong Method",
+			http.MethodPost,
+			connected,
+			true,
+			http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, c := range cases {
+		ctx := &Context{Notifier: NewNotifier(), MQ: c.mq}
+		if c.consuming {
+			ctx.Notifier.consuming(1)
+		}
+		req := httptest.NewRequest(c.method, "/v1/health/mq", nil)
+		respRec := httptest.NewRecorder()
+
+		ctx.MQHealthHandler(respRec, req)
+		if respRec.Code != c.expectedStatusCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d: %s",
+				c.name, c.expectedStatusCode, respRec.Code, respRec.Body.String())
+		}
+		if strings.Contains(respRec.Body.String(), "lastError") {
+			t.Errorf("case %s: expected errors to be hidden, got %s", c.name, respRec.Body.String())
+		}
+	}
+}
+
+func TestMQDetailsHandler(t *testing.T) {
+	mq := NewMQSupervisor("localhost:5672")
+	mq.failed(errors.New("dial tcp 10.0.0.5:5672: connection refused"))
+	cases := []struct {
+		name               string
+		user               *users.User
+		expectedStatusCode int
+	}{
+		{
+			"Admin",
+			&users.User{ID: 1, Role: users.RoleAdmin},
+			http.StatusOK,
+		},
+		{
+			"Not Admin",
+			&users.User{ID: 2, Role: users.RoleMember},
+			http.StatusForbidden,
+		},
+	}
+
+	for _, c := range cases {
+		ctx := &Context{Notifier: NewNotifier(), MQ: mq}
+		req := httptest.NewRequest(http.MethodGet, "/v1/health/mq/details", nil)
+		req = WithSessionState(req, &SessionState{BeginTime: time.Now(), User: c.user})
+		respRec := httptest.NewRecorder()
+
+		ctx.MQDetailsHandler(respRec, req)
+		if respRec.Code != c.expectedStatusCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d: %s",
+				c.name, c.expectedStatusCode, respRec.Code, respRec.Body.String())
+			continue
+		}
+		if c.expectedStatusCode == http.StatusOK && !strings.Contains(respRec.Body.String(), "connection refused") {
+			t.Errorf("case %s: expected the last error, got %s", c.name, respRec.Body.String())
+		}
+	}
+}
+
+func TestMQPublisherNotConnected(t *testing.T) {
+	p := NewMQPublisher(nil, "notifications")
+	if err := p.Publish(map[string]string{"type": "user-new"}); err == nil {
+		t.Error("expected an error publishing without a channel")
+	}
+}
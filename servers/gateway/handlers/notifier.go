@@ -4,21 +4,89 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/streadway/amqp"
 )
 
+//ClientListener is notified as WebSocket clients connect, send messages and disconnect
+type ClientListener interface {
+	ClientConnected(client *websocket.Conn, userID int64)
+	ClientMessage(client *websocket.Conn, userID int64, data []byte)
+	ClientDisconnected(client *websocket.Conn, userID int64)
+}
+
+//EventFilter decides whether events sent by one user are delivered to another
+type EventFilter interface {
+	Filtered(recipientID int64, senderID int64) bool
+}
+
+//Settings of the connections' write pumps and read loops
+const (
+	//SendBufferSize is how many events can wait to be written to a connection.
+	//A client that falls this far behind is disconnected as a slow consumer.
+	SendBufferSize = 256
+	//WriteWait is how long a write to a connection may take
+	WriteWait = 10 * time.Second
+	//PongWait is how long a connection may go without a pong or a message
+	//from the client before it is closed
+	PongWait = 60 * time.Second
+	//PingPeriod is how often connections are pinged, which must be less than PongWait
+	PingPeriod = PongWait * 9 / 10
+)
+
+//client is a WebSocket connection and the events waiting to be written to it
+type client struct {
+	conn   *websocket.Conn
+	userID int64
+	send   chan []byte
+	//done is closed to stop the write pump, which then closes the connection
+	done     chan struct{}
+	stopOnce sync.Once
+	//closeMessage is sent to the client when the write pump is stopped
+	closeMessage []byte
+}
+
+//stop stops the client's write pump, which sends the close message and
+//closes the connection. Only the first call has any effect.
+func (c *client) stop(code int, text string) {
+	c.stopOnce.Do(func() {
+		c.closeMessage = websocket.FormatCloseMessage(code, text)
+		close(c.done)
+	})
+}
+
+//ConsumerStatus describes how the Notifier is consuming the message queue
+type ConsumerStatus struct {
+	//Running is true while messages are being consumed
+	Running bool `json:"running"`
+	//Processed and DeadLettered count the messages broadcast and rejected
+	Processed       int64      `json:"processed"`
+	DeadLettered    int64      `json:"deadLettered"`
+	LastProcessedAt *time.Time `json:"lastProcessedAt,omitempty"`
+}
+
 //Notifier handles WebSocket Notifications
 type Notifier struct {
-	currConnections map[int64][]*websocket.Conn
+	currConnections map[int64][]*client
 	mx              sync.Mutex
+	//Listener is optional, and must be set before any clients are added
+	Listener ClientListener
+	//Filter is optional, every event is delivered when it is nil
+	Filter EventFilter
+
+	statusMx sync.Mutex
+	status   ConsumerStatus
+	//consumers counts the running ProcessMessages loops, since a new one may
+	//start before the one for a lost connection has finished
+	consumers int
 }
 
 //NewNotifier constructs a new Notifier
 func NewNotifier() *Notifier {
 	n := &Notifier{
-		currConnections: make(map[int64][]*websocket.Conn),
+		currConnections: make(map[int64][]*client),
 	}
 
 	return n
@@ -29,85 +97,284 @@ type messageInfo struct {
 	MessageType string      `json:"type,omitempty"`
 	Action      interface{} `json:"action,omitempty"`
 	UserIDs     []int64     `json:"userIDs,omitempty"`
+	Message     *struct {
+		Creator *eventUser `json:"creator,omitempty"`
+	} `json:"message,omitempty"`
+	Channel *struct {
+		Creator *eventUser `json:"creator,omitempty"`
+	} `json:"channel,omitempty"`
+	User *eventUser `json:"user,omitempty"`
+}
+
+//eventUser holds the ID of a user an event refers to
+type eventUser struct {
+	ID int64 `json:"id"`
 }
 
-//AddClient adds a new client to the Notifier
-func (n *Notifier) AddClient(client *websocket.Conn, userID int64) {
+//senderID returns the ID of the user who caused the event,
+//or 0 if the event doesn't say
+func (mi *messageInfo) senderID() int64 {
+	switch {
+	case mi.Message != nil && mi.Message.Creator != nil:
+		return mi.Message.Creator.ID
+	case mi.Channel != nil && mi.Channel.Creator != nil:
+		return mi.Channel.Creator.ID
+	case mi.User != nil:
+		return mi.User.ID
+	}
+	return 0
+}
+
+//AddClient adds a new client to the Notifier, starting a write pump that
+//writes events and pings to it and a read loop that reads from it
+func (n *Notifier) AddClient(conn *websocket.Conn, userID int64) {
+	c := &client{
+		conn:   conn,
+		userID: userID,
+		send:   make(chan []byte, SendBufferSize),
+		done:   make(chan struct{}),
+	}
 	n.mx.Lock()
-	defer n.mx.Unlock()
-	n.currConnections[userID] = append(n.currConnections[userID], client)
-	go n.readLoop(client, userID)
+	n.currConnections[userID] = append(n.currConnections[userID], c)
+	n.mx.Unlock()
+	if n.Listener != nil {
+		n.Listener.ClientConnected(conn, userID)
+	}
+	go n.writePump(c)
+	go n.readLoop(c)
+}
+
+//readLoop reads from the connection until it fails or goes quiet for PongWait,
+//then removes the client. It is the only place clients are disconnected from the
+//Listener's point of view, so ClientDisconnected is called once per client.
+func (n *Notifier) readLoop(c *client) {
+	c.conn.SetReadDeadline(time.Now().Add(PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(PongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		c.conn.SetReadDeadline(time.Now().Add(PongWait))
+		if n.Listener != nil {
+			n.Listener.ClientMessage(c.conn, c.userID, data)
+		}
+	}
+	n.mx.Lock()
+	n.removeClient(c)
+	n.mx.Unlock()
+	c.stop(websocket.CloseNormalClosure, "")
+	if n.Listener != nil {
+		n.Listener.ClientDisconnected(c.conn, c.userID)
+	}
 }
 
-//readLoop reads through each connection and closes any that have an error
-func (n *Notifier) readLoop(client *websocket.Conn, userID int64) {
+//writePump writes the client's events to its connection and pings it every
+//PingPeriod. It is the only goroutine that writes to the connection, and it
+//closes the connection when it stops, which also ends the read loop.
+func (n *Notifier) writePump(c *client) {
+	ticker := time.NewTicker(PingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
 	for {
-		if _, _, err := client.NextReader(); err != nil {
-			client.Close()
-			connIndex := findConn(n.currConnections[userID], client)
-			n.currConnections[userID] = append(n.currConnections[userID][:connIndex], n.currConnections[userID][connIndex+1:]...)
+		select {
+		case body := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(WriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, body); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			c.conn.WriteControl(websocket.CloseMessage, c.closeMessage, time.Now().Add(WriteWait))
+			return
+		}
+	}
+}
+
+//removeClient removes the client from the Notifier if it is still present,
+//the caller must hold the lock
+func (n *Notifier) removeClient(c *client) {
+	clients := n.currConnections[c.userID]
+	for i, other := range clients {
+		if other == c {
+			clients = append(clients[:i], clients[i+1:]...)
 			break
 		}
 	}
+	if len(clients) == 0 {
+		delete(n.currConnections, c.userID)
+	} else {
+		n.currConnections[c.userID] = clients
+	}
 }
 
-//ProcessMessages broadcasts messages consumed at gateway
+//CloseUserConnections closes and removes every WebSocket opened by the user
+func (n *Notifier) CloseUserConnections(userID int64) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	for _, c := range n.currConnections[userID] {
+		c.stop(websocket.ClosePolicyViolation, "Session ended")
+	}
+	delete(n.currConnections, userID)
+}
+
+//ProcessMessages broadcasts messages consumed at gateway until the channel
+//of messages closes, which happens when the connection to the queue is lost.
+//Messages that can't be decoded are rejected, which dead-letters them.
 func (n *Notifier) ProcessMessages(messages <-chan amqp.Delivery) {
+	n.consuming(1)
+	defer n.consuming(-1)
 	for message := range messages {
-		n.mx.Lock()
 		messageInfo := &messageInfo{}
 		if err := json.Unmarshal(message.Body, messageInfo); err != nil {
-			log.Printf("Error decoding json object: %v", err)
-			return
-		}
-		users := messageInfo.UserIDs
-		switch len(users) {
-		case 0:
-			n.broadcastPublic(message)
-		default:
-			n.broadcastPrivate(users, message)
+			log.Printf("dead-lettering message that can't be decoded: %v", err)
+			message.Reject(false)
+			n.processed(true)
+			continue
 		}
+		n.Broadcast(message.Body, messageInfo.UserIDs, messageInfo.senderID())
 		message.Ack(false)
+		n.processed(false)
+	}
+}
+
+//consuming adds delta to the number of running ProcessMessages loops
+func (n *Notifier) consuming(delta int) {
+	n.statusMx.Lock()
+	defer n.statusMx.Unlock()
+	n.consumers += delta
+	n.status.Running = n.consumers > 0
+}
+
+//processed records that a message was broadcast or dead-lettered
+func (n *Notifier) processed(deadLettered bool) {
+	now := time.Now()
+	n.statusMx.Lock()
+	defer n.statusMx.Unlock()
+	if deadLettered {
+		n.status.DeadLettered++
+	} else {
+		n.status.Processed++
+	}
+	n.status.LastProcessedAt = &now
+}
+
+//Status returns a copy of the status of the Notifier's consumer
+func (n *Notifier) Status() ConsumerStatus {
+	n.statusMx.Lock()
+	defer n.statusMx.Unlock()
+	return n.status
+}
+
+//Broadcast writes the event sent by senderID to the WebSockets created by users
+//in the userIDs list, or to all WebSockets if the list is empty. A senderID of 0
+//means the event wasn't sent by a user, so it is never filtered.
+func (n *Notifier) Broadcast(body []byte, userIDs []int64, senderID int64) {
+	//the filter may need to load block lists from the database,
+	//so the recipients are resolved before taking the lock
+	allowed := n.allowedRecipients(userIDs, senderID)
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.broadcast(body, userIDs, allowed)
+}
+
+//allowedRecipients returns the recipients the Filter allows the event to be
+//delivered to, or nil if the event isn't filtered. Public events are only
+//delivered to the users connected before the filter was checked.
+func (n *Notifier) allowedRecipients(userIDs []int64, senderID int64) map[int64]bool {
+	if n.Filter == nil || senderID <= 0 {
+		return nil
+	}
+	if len(userIDs) == 0 {
+		n.mx.Lock()
+		for user := range n.currConnections {
+			userIDs = append(userIDs, user)
+		}
 		n.mx.Unlock()
 	}
+	allowed := make(map[int64]bool, len(userIDs))
+	for _, user := range userIDs {
+		if !n.Filter.Filtered(user, senderID) {
+			allowed[user] = true
+		}
+	}
+	return allowed
+}
+
+//broadcast writes the event to the WebSockets of the allowed users,
+//the caller must hold the lock
+func (n *Notifier) broadcast(body []byte, userIDs []int64, allowed map[int64]bool) {
+	switch len(userIDs) {
+	case 0:
+		n.broadcastPublic(body, allowed)
+	default:
+		n.broadcastPrivate(userIDs, body, allowed)
+	}
+}
+
+//delivered returns true if the event may be delivered to the recipient,
+//a nil allowed set means every recipient is allowed
+func delivered(allowed map[int64]bool, recipientID int64) bool {
+	return allowed == nil || allowed[recipientID]
 }
 
 //broadcastPrivate only broadcasts to WebSockets created by users in userIDs list
-func (n *Notifier) broadcastPrivate(users []int64, message amqp.Delivery) {
+func (n *Notifier) broadcastPrivate(users []int64, body []byte, allowed map[int64]bool) {
+	var slow []*client
 	for _, user := range users {
-		if _, ok := n.currConnections[user]; ok {
-			for _, connection := range n.currConnections[user] {
-				if err := connection.WriteMessage(websocket.TextMessage, message.Body); err != nil {
-					connection.Close()
-					connIndex := findConn(n.currConnections[user], connection)
-					n.currConnections[user] = append(n.currConnections[user][:connIndex], n.currConnections[user][connIndex+1:]...)
-					return
-				}
+		if !delivered(allowed, user) {
+			continue
+		}
+		for _, c := range n.currConnections[user] {
+			if !enqueue(c, body) {
+				slow = append(slow, c)
 			}
 		}
 	}
+	n.disconnectSlow(slow)
 }
 
 //broadcastPublic broadcasts to all WebSockets
-func (n *Notifier) broadcastPublic(message amqp.Delivery) {
-	for user, connections := range n.currConnections {
-		for _, connection := range connections {
-			if err := connection.WriteMessage(websocket.TextMessage, message.Body); err != nil {
-				connection.Close()
-				connIndex := findConn(n.currConnections[user], connection)
-				n.currConnections[user] = append(n.currConnections[user][:connIndex], n.currConnections[user][connIndex+1:]...)
-				return
+func (n *Notifier) broadcastPublic(body []byte, allowed map[int64]bool) {
+	var slow []*client
+	for user, clients := range n.currConnections {
+		if !delivered(allowed, user) {
+			continue
+		}
+		for _, c := range clients {
+			if !enqueue(c, body) {
+				slow = append(slow, c)
 			}
 		}
 	}
+	n.disconnectSlow(slow)
 }
 
-//findConn finds the index of the connection in the slice
-func findConn(a []*websocket.Conn, x *websocket.Conn) int {
-	for i, n := range a {
-		if x == n {
-			return i
-		}
+//enqueue queues the event to be written to the client without waiting,
+//returning false if the client's buffer is full
+func enqueue(c *client, body []byte) bool {
+	select {
+	case c.send <- body:
+		return true
+	default:
+		return false
+	}
+}
+
+//disconnectSlow removes and closes the clients whose buffers overflowed,
+//the caller must hold the lock
+func (n *Notifier) disconnectSlow(slow []*client) {
+	for _, c := range slow {
+		log.Printf("disconnecting slow WebSocket client of user %d", c.userID)
+		n.removeClient(c)
+		c.stop(websocket.CloseTryAgainLater, "Client is too slow")
 	}
-	return -1
 }
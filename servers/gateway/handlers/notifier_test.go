@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//fakeListener records the users of clients as they disconnect
+type fakeListener struct {
+	disconnected chan int64
+}
+
+func (l *fakeListener) ClientConnected(client *websocket.Conn, userID int64) {}
+
+func (l *fakeListener) ClientMessage(client *websocket.Conn, userID int64, data []byte) {}
+
+func (l *fakeListener) ClientDisconnected(client *websocket.Conn, userID int64) {
+	l.disconnected <- userID
+}
+
+//newTestNotifier starts a server that adds each WebSocket to the notifier as the
+//user in the userID query string parameter, and returns a function to dial it
+func newTestNotifier(t *testing.T) (*Notifier, *fakeListener, func(userID string) *websocket.Conn, func()) {
+	listener := &fakeListener{disconnected: make(chan int64, 10)}
+	n := NewNotifier()
+	n.Listener = listener
+	upgrader := &websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		var userID int64
+		if r.URL.Query().Get("userID") == "2" {
+			userID = 2
+		} else {
+			userID = 1
+		}
+		n.AddClient(conn, userID)
+	}))
+	dial := func(userID string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?userID="+userID, nil)
+		if err != nil {
+			t.Fatalf("error dialing test server: %v", err)
+		}
+		return conn
+	}
+	return n, listener, dial, server.Close
+}
+
+//waitForClients waits until the notifier has the number of clients
+func waitForClients(t *testing.T, n *Notifier, expected int) {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		n.mx.Lock()
+		count := 0
+		for _, clients := range n.currConnections {
+			count += len(clients)
+		}
+		n.mx.Unlock()
+		if count == expected {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d clients", expected)
+}
+
+//readEvent reads the next event from the connection
+func readEvent(t *testing.T, conn *websocket.Conn) string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("error reading event: %v", err)
+	}
+	return string(data)
+}
+
+func TestNotifierBroadcast(t *testing.T) {
+	n, _, dial, closeServer := newTestNotifier(t)
+	defer closeServer()
+	user1 := dial("1")
+	defer user1.Close()
+	user2 := dial("2")
+	defer user2.Close()
+	waitForClients(t, n, 2)
+
+	n.Broadcast([]byte("private"), []int64{2}, 0)
+	n.Broadcast([]byte("public"), nil, 0)
+	if event := readEvent(t, user1); event != "public" {
+		t.Errorf("expected user 1 to only get the public event, got %q", event)
+	}
+	if event := readEvent(t, user2); event != "private" {
+		t.Errorf("expected user 2 to get the private event first, got %q", event)
+	}
+	if event := readEvent(t, user2); event != "public" {
+		t.Errorf("expected user 2 to get the public event, got %q", event)
+	}
+}
+
+//lockCheckingFilter filters the blocked senders, and records whether
+//it was asked while the notifier's lock was held
+type lockCheckingFilter struct {
+	n       *Notifier
+	blocked map[int64]int64
+	locked  bool
+}
+
+func (f *lockCheckingFilter) Filtered(recipientID int64, senderID int64) bool {
+	if !f.n.mx.TryLock() {
+		f.locked = true
+	} else {
+		f.n.mx.Unlock()
+	}
+	return f.blocked[recipientID] == senderID
+}
+
+func TestNotifierBroadcastFiltered(t *testing.T) {
+	n := NewNotifier()
+	filter := &lockCheckingFilter{n: n, blocked: map[int64]int64{1: 3}}
+	n.Filter = filter
+	blocker := &client{userID: 1, send: make(chan []byte, 10), done: make(chan struct{})}
+	other := &client{userID: 2, send: make(chan []byte, 10), done: make(chan struct{})}
+	n.currConnections[1] = []*client{blocker}
+	n.currConnections[2] = []*client{other}
+
+	n.Broadcast([]byte("private"), []int64{1, 2}, 3)
+	n.Broadcast([]byte("public"), nil, 3)
+	n.Broadcast([]byte("system"), nil, 0)
+	if filter.locked {
+		t.Error("expected block lists to be checked without holding the lock")
+	}
+	if len(blocker.send) != 1 {
+		t.Errorf("expected the blocker to only get the system event, got %d events", len(blocker.send))
+	}
+	if len(other.send) != 3 {
+		t.Errorf("expected the other user to get every event, got %d", len(other.send))
+	}
+}
+
+func TestNotifierDisconnect(t *testing.T) {
+	n, listener, dial, closeServer := newTestNotifier(t)
+	defer closeServer()
+
+	//clients that close are removed, and the listener is told once
+	user1 := dial("1")
+	waitForClients(t, n, 1)
+	user1.Close()
+	select {
+	case userID := <-listener.disconnected:
+		if userID != 1 {
+			t.Errorf("expected user 1 to disconnect, got %d", userID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the client to disconnect")
+	}
+	waitForClients(t, n, 0)
+
+	//closing a user's connections sends them a close message
+	user2 := dial("2")
+	defer user2.Close()
+	waitForClients(t, n, 1)
+	n.CloseUserConnections(2)
+	user2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := user2.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("expected a policy violation close message, got %v", err)
+	}
+	select {
+	case userID := <-listener.disconnected:
+		if userID != 2 {
+			t.Errorf("expected user 2 to disconnect, got %d", userID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the client to disconnect")
+	}
+	select {
+	case userID := <-listener.disconnected:
+		t.Errorf("expected each client to disconnect once, but user %d disconnected again", userID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifierSlowConsumer(t *testing.T) {
+	n := NewNotifier()
+	//clients without write pumps never drain their buffers
+	slow := &client{userID: 1, send: make(chan []byte, 2), done: make(chan struct{})}
+	fast := &client{userID: 2, send: make(chan []byte, 10), done: make(chan struct{})}
+	n.currConnections[1] = []*client{slow}
+	n.currConnections[2] = []*client{fast}
+
+	for i := 0; i < 3; i++ {
+		n.Broadcast([]byte("event"), []int64{1, 2}, 0)
+	}
+	select {
+	case <-slow.done:
+	default:
+		t.Error("expected the slow client to be stopped")
+	}
+	if _, found := n.currConnections[1]; found {
+		t.Error("expected the slow client to be removed")
+	}
+	if len(fast.send) != 3 {
+		t.Errorf("expected the fast client to get every event, got %d", len(fast.send))
+	}
+	n.Broadcast([]byte("event"), nil, 0)
+	if len(fast.send) != 4 {
+		t.Errorf("expected the fast client to keep getting events, got %d", len(fast.send))
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/preferences"
+)
+
+//maxPreferencesPatchBytes is the largest preferences patch accepted
+const maxPreferencesPatchBytes = 64 << 10
+
+//PreferencesHandler handles requests for the current user's preferences.
+//PATCH requests are JSON merge patches of the preferences document.
+func (ctx *Context) PreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	if ctx.PreferencesStore == nil {
+		WriteError(w, r, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Preferences are not available"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := ctx.PreferencesStore.Get(stateStruct.User.ID)
+		if err != nil {
+			WriteError(w, r, internalError(fmt.Errorf("getting preferences: %v", err)))
+			return
+		}
+		respond(w, prefs, http.StatusOK, ContentTypeJSON)
+
+	case http.MethodPatch:
+		contentType := r.Header.Get(HeaderContentType)
+		if !strings.HasPrefix(contentType, ContentTypeMergePatchJSON) && !strings.HasPrefix(contentType, ContentTypeJSON) {
+			WriteError(w, r, NewHTTPError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+				"Request body must be "+ContentTypeMergePatchJSON))
+			return
+		}
+		patch, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPreferencesPatchBytes))
+		if err != nil {
+			WriteError(w, r, NewHTTPError(http.StatusRequestEntityTooLarge, CodeInvalidPreferences, "Preferences patch is too large"))
+			return
+		}
+		patched, err := ctx.PreferencesStore.Patch(stateStruct.User.ID, patch)
+		if err != nil {
+			if _, ok := err.(*preferences.InvalidPatchError); ok {
+				WriteError(w, r, NewHTTPError(http.StatusBadRequest, CodeInvalidPreferences, err.Error()))
+				return
+			}
+			WriteError(w, r, internalError(fmt.Errorf("patching preferences: %v", err)))
+			return
+		}
+		respond(w, patched, http.StatusOK, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/presence"
+)
+
+//PresenceRefreshInterval is how often the status of every open connection is saved again
+const PresenceRefreshInterval = 30 * time.Second
+
+//PresenceConnectionTTL is how long a connection counts towards presence
+//without being refreshed, in case the gateway instance holding it goes away
+const PresenceConnectionTTL = 3 * PresenceRefreshInterval
+
+//PresenceIdleTimeout is how long a connection may go without an online
+//heartbeat from the client before it is considered away
+const PresenceIdleTimeout = 10 * time.Minute
+
+//MaxBatchIDs is the most user IDs that may be requested at once
+const MaxBatchIDs = 100
+
+//heartbeatType is the type of message clients send over their WebSocket to report activity
+const heartbeatType = "heartbeat"
+
+//heartbeat is sent by clients to say whether they are online or away
+type heartbeat struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+//trackedConn is an open WebSocket that counts towards a user's presence
+type trackedConn struct {
+	userID     int64
+	connID     string
+	status     string
+	lastActive time.Time
+}
+
+//PresenceTracker derives the presence of users from the lifecycle of their
+//WebSocket connections and the heartbeats clients send over them.
+//It implements ClientListener so it can be set as the Notifier's Listener.
+type PresenceTracker struct {
+	store      presence.Store
+	userStore  users.Store
+	notifier   *Notifier
+	instanceID string
+	nextConnID int64
+	conns      map[*websocket.Conn]*trackedConn
+	mx         sync.Mutex
+}
+
+//NewPresenceTracker constructs a new PresenceTracker
+func NewPresenceTracker(store presence.Store, userStore users.Store, notifier *Notifier) (*PresenceTracker, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("generating instance ID: %v", err)
+	}
+	return &PresenceTracker{
+		store:      store,
+		userStore:  userStore,
+		notifier:   notifier,
+		instanceID: hex.EncodeToString(idBytes),
+		conns:      make(map[*websocket.Conn]*trackedConn),
+	}, nil
+}
+
+//Start subscribes to presence events from every gateway instance, and starts
+//refreshing the status of connections held by this instance
+func (pt *PresenceTracker) Start() error {
+	events, err := pt.store.Subscribe()
+	if err != nil {
+		return err
+	}
+	go pt.broadcastEvents(events)
+	go pt.refreshLoop()
+	return nil
+}
+
+//ClientConnected marks the user online
+func (pt *PresenceTracker) ClientConnected(client *websocket.Conn, userID int64) {
+	pt.mx.Lock()
+	pt.nextConnID++
+	conn := &trackedConn{
+		userID:     userID,
+		connID:     pt.instanceID + "-" + strconv.FormatInt(pt.nextConnID, 10),
+		status:     presence.StatusOnline,
+		lastActive: time.Now(),
+	}
+	pt.conns[client] = conn
+	pt.mx.Unlock()
+	pt.update(userID, conn.connID, presence.StatusOnline)
+}
+
+//ClientMessage handles heartbeats from the client, other messages are ignored
+func (pt *PresenceTracker) ClientMessage(client *websocket.Conn, userID int64, data []byte) {
+	beat := &heartbeat{}
+	if err := json.Unmarshal(data, beat); err != nil || beat.Type != heartbeatType {
+		return
+	}
+	if beat.Status != presence.StatusOnline && beat.Status != presence.StatusAway {
+		return
+	}
+	pt.mx.Lock()
+	conn, found := pt.conns[client]
+	if !found {
+		pt.mx.Unlock()
+		return
+	}
+	conn.status = beat.Status
+	if beat.Status == presence.StatusOnline {
+		conn.lastActive = time.Now()
+	}
+	connID := conn.connID
+	pt.mx.Unlock()
+	pt.update(userID, connID, beat.Status)
+}
+
+//ClientDisconnected removes the connection from the user's presence
+func (pt *PresenceTracker) ClientDisconnected(client *websocket.Conn, userID int64) {
+	pt.mx.Lock()
+	conn, found := pt.conns[client]
+	delete(pt.conns, client)
+	pt.mx.Unlock()
+	if found {
+		pt.update(userID, conn.connID, presence.StatusOffline)
+	}
+}
+
+//refreshLoop saves the status of every open connection on an interval,
+//so they don't expire, and so idle connections become away. It also
+//sweeps for users whose connections expired.
+func (pt *PresenceTracker) refreshLoop() {
+	ticker := time.NewTicker(PresenceRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pt.refresh(time.Now())
+		pt.sweep()
+	}
+}
+
+//refresh saves the status of every open connection
+func (pt *PresenceTracker) refresh(now time.Time) {
+	pt.mx.Lock()
+	conns := make([]trackedConn, 0, len(pt.conns))
+	for _, conn := range pt.conns {
+		if conn.status == presence.StatusOnline && now.Sub(conn.lastActive) > PresenceIdleTimeout {
+			conn.status = presence.StatusAway
+		}
+		conns = append(conns, *conn)
+	}
+	pt.mx.Unlock()
+	for _, conn := range conns {
+		pt.update(conn.userID, conn.connID, conn.status)
+	}
+}
+
+//sweep publishes presence-change events for the users whose connections
+//expired, which nothing else would tell their contacts about
+func (pt *PresenceTracker) sweep() {
+	changed, err := pt.store.Sweep()
+	if err != nil {
+		log.Printf("error sweeping expired presence: %v", err)
+		return
+	}
+	for _, current := range changed {
+		pt.publish(current)
+	}
+}
+
+//update saves the status of a connection, and publishes a
+//presence-change event if the user's overall presence changed
+func (pt *PresenceTracker) update(userID int64, connID string, status string) {
+	current, changed, err := pt.store.Update(userID, connID, status)
+	if err != nil {
+		log.Printf("error updating presence of user %d: %v", userID, err)
+		return
+	}
+	if changed {
+		pt.publish(current)
+	}
+}
+
+//publish sends a presence-change event to every gateway instance, addressed
+//to the user and to every user who shares a channel with them
+func (pt *PresenceTracker) publish(current *presence.Presence) {
+	contactIDs, err := pt.userStore.GetContactIDs(current.UserID)
+	if err != nil {
+		log.Printf("error getting contacts of user %d: %v", current.UserID, err)
+	}
+	event := &presence.Event{
+		Type:     presence.EventPresenceChange,
+		Presence: current,
+		UserIDs:  append(contactIDs, current.UserID),
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("error encoding presence event: %v", err)
+		return
+	}
+	if err := pt.store.Publish(body); err != nil {
+		log.Printf("error publishing presence event: %v", err)
+	}
+}
+
+//broadcastEvents writes presence events from every gateway instance
+//to the interested users connected to this instance
+func (pt *PresenceTracker) broadcastEvents(events <-chan []byte) {
+	for body := range events {
+		event := &presence.Event{}
+		if err := json.Unmarshal(body, event); err != nil {
+			log.Printf("error decoding presence event: %v", err)
+			continue
+		}
+		if len(event.UserIDs) == 0 || event.Presence == nil {
+			continue
+		}
+		//the recipients are the user's contacts, which the recipients mustn't see
+		recipients := event.UserIDs
+		event.UserIDs = nil
+		clientBody, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("error encoding presence event: %v", err)
+			continue
+		}
+		pt.notifier.Broadcast(clientBody, recipients, event.Presence.UserID)
+	}
+}
+
+//PresenceHandler handles requests for the presence of a specific user
+func (ctx *Context) PresenceHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	reqID, err := parseID(mux.Vars(r)["id"], stateStruct)
+	if err != nil {
+		WriteError(w, r, errInvalidUserID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		presences, err := ctx.getPresence(reqID)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		respond(w, presences[0], http.StatusOK, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//BatchPresenceHandler handles requests for the presence of several users,
+//given as a comma-delimited list in the "ids" query string parameter
+func (ctx *Context) BatchPresenceHandler(w http.ResponseWriter, r *http.Request) {
+	if GetSessionState(r) == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		ids, err := parseIDList(r.URL.Query().Get("ids"))
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		presences, err := ctx.getPresence(ids...)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		respond(w, presences, http.StatusOK, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//getPresence gets the presence of each user from the PresenceStore
+func (ctx *Context) getPresence(userIDs ...int64) ([]*presence.Presence, error) {
+	if ctx.PresenceStore == nil {
+		return nil, NewHTTPError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Presence is not available")
+	}
+	presences, err := ctx.PresenceStore.Get(userIDs...)
+	if err != nil {
+		return nil, internalError(fmt.Errorf("getting presence: %v", err))
+	}
+	return presences, nil
+}
+
+//parseIDList parses a comma-delimited list of at most MaxBatchIDs user IDs
+func parseIDList(list string) ([]int64, error) {
+	if len(list) == 0 {
+		return nil, NewHTTPError(http.StatusBadRequest, CodeMissingQuery, "Missing 'ids' query string parameter")
+	}
+	parts := strings.Split(list, ",")
+	if len(parts) > MaxBatchIDs {
+		return nil, NewHTTPError(http.StatusBadRequest, CodeInvalidUserID,
+			fmt.Sprintf("At most %d user IDs may be requested at once", MaxBatchIDs))
+	}
+	ids := make([]int64, len(parts))
+	for i, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, NewHTTPError(http.StatusBadRequest, CodeInvalidUserID, "User IDs must be numbers")
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
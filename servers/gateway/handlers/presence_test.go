@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/presence"
+)
+
+func TestParseIDList(t *testing.T) {
+	cases := []struct {
+		name        string
+		list        string
+		expectedIDs []int64
+		expectError bool
+	}{
+		{
+			"Valid List",
+			"1, 2,3",
+			[]int64{1, 2, 3},
+			false,
+		},
+		{
+			"Empty List",
+			"",
+			nil,
+			true,
+		},
+		{
+			"Not A Number",
+			"1,me",
+			nil,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		ids, err := parseIDList(c.list)
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: unexpected error result: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(ids, c.expectedIDs) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expectedIDs, ids)
+		}
+	}
+}
+
+//nextPresenceEvent waits for the next presence event published to the store
+func nextPresenceEvent(t *testing.T, events <-chan []byte) *presence.Event {
+	select {
+	case body := <-events:
+		event := &presence.Event{}
+		if err := json.Unmarshal(body, event); err != nil {
+			t.Fatalf("error decoding event: %v", err)
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for presence event")
+	}
+	return nil
+}
+
+func TestPresenceTracker(t *testing.T) {
+	store := presence.NewMemStore(time.Minute)
+	events, _ := store.Subscribe()
+	tracker, err := NewPresenceTracker(store, &users.MockStore{}, NewNotifier())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := &websocket.Conn{}
+
+	tracker.ClientConnected(client, 1)
+	event := nextPresenceEvent(t, events)
+	if event.Type != presence.EventPresenceChange || event.Presence.Status != presence.StatusOnline {
+		t.Errorf("expected user to come online, got %v", event.Presence)
+	}
+	if !reflect.DeepEqual(event.UserIDs, []int64{1}) {
+		t.Errorf("expected event to be sent to user, got %v", event.UserIDs)
+	}
+
+	tracker.ClientMessage(client, 1, []byte(`{"type":"heartbeat","status":"away"}`))
+	if event = nextPresenceEvent(t, events); event.Presence.Status != presence.StatusAway {
+		t.Errorf("expected user to be away, got %s", event.Presence.Status)
+	}
+
+	tracker.ClientMessage(client, 1, []byte(`{"type":"heartbeat","status":"online"}`))
+	nextPresenceEvent(t, events)
+	tracker.refresh(time.Now().Add(PresenceIdleTimeout + time.Minute))
+	if event = nextPresenceEvent(t, events); event.Presence.Status != presence.StatusAway {
+		t.Errorf("expected idle user to be away, got %s", event.Presence.Status)
+	}
+
+	tracker.ClientDisconnected(client, 1)
+	if event = nextPresenceEvent(t, events); event.Presence.Status != presence.StatusOffline {
+		t.Errorf("expected user to be offline, got %s", event.Presence.Status)
+	}
+}
+
+func TestPresenceTrackerSweep(t *testing.T) {
+	store := presence.NewMemStore(time.Millisecond)
+	events, _ := store.Subscribe()
+	tracker, err := NewPresenceTracker(store, &users.MockStore{}, NewNotifier())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	//a connection held by another gateway that went away
+	if _, _, err := store.Update(1, "gone", presence.StatusOnline); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	tracker.sweep()
+	if event := nextPresenceEvent(t, events); event.Presence.UserID != 1 || event.Presence.Status != presence.StatusOffline {
+		t.Errorf("expected user 1 to go offline, got %v", event.Presence)
+	}
+}
+
+func TestPresenceTrackerBroadcastEvents(t *testing.T) {
+	n := NewNotifier()
+	contact := &client{userID: 2, send: make(chan []byte, 1), done: make(chan struct{})}
+	n.currConnections[2] = []*client{contact}
+	tracker, err := NewPresenceTracker(presence.NewMemStore(time.Minute), &users.MockStore{}, n)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := make(chan []byte, 1)
+	events <- []byte(`{"type":"presence-change","presence":{"userID":1,"status":"online"},"userIDs":[2,3,1]}`)
+	close(events)
+	tracker.broadcastEvents(events)
+
+	select {
+	case body := <-contact.send:
+		event := map[string]interface{}{}
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Fatalf("error decoding event: %v", err)
+		}
+		if _, found := event["userIDs"]; found || event["presence"] == nil {
+			t.Errorf("expected only the type and presence to be sent, got %s", body)
+		}
+	default:
+		t.Fatal("expected the contact to be sent the event")
+	}
+}
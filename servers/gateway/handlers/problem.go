@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+//problemTypeBase is prefixed to an error code to build the problem type URI
+const problemTypeBase = "/problems/"
+
+//Error codes are stable, machine-readable identifiers sent with every
+//error response. Clients should match on these rather than on detail text.
+const (
+	CodeInternal             = "internal_error"
+	CodeServiceUnavailable   = "service_unavailable"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInvalidJSON          = "invalid_json"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeForbidden            = "forbidden"
+	CodeInvalidUserID        = "invalid_user_id"
+	CodeUserNotFound         = "user_not_found"
+	CodeInvalidUser          = "invalid_user"
+	CodeWeakPassword         = "weak_password"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeTooManyAttempts      = "too_many_attempts"
+	CodeAccountDeactivated   = "account_deactivated"
+	CodeAccountSuspended     = "account_suspended"
+	CodeRegistrationClosed   = "registration_closed"
+	CodeInvalidInvite        = "invalid_invite"
+	CodeInviteNotFound       = "invite_not_found"
+	CodeMissingQuery         = "missing_query"
+	CodeInvalidResetCode     = "invalid_reset_code"
+	CodePasswordMismatch     = "password_mismatch"
+	CodeInvalidAvatar        = "invalid_avatar"
+	CodeAvatarNotFound       = "avatar_not_found"
+	CodeInvalidURL           = "invalid_url"
+	CodeFetchFailed          = "fetch_failed"
+	CodeInvalidPreferences   = "invalid_preferences"
+	CodeInvalidBlock         = "invalid_block"
+	CodeBlockNotFound        = "block_not_found"
+	CodeInvalidQuery         = "invalid_query"
+	CodeExportNotFound       = "export_not_found"
+	CodeExportTooSoon        = "export_too_soon"
+	CodeInvalidDownloadLink  = "invalid_download_link"
+)
+
+//HTTPError is an error with a status code and a stable error code that is
+//written to clients as an RFC 7807 problem. Detail is shown to the client,
+//while Err holds the underlying cause, which is only ever logged.
+type HTTPError struct {
+	Status int
+	Code   string
+	Detail string
+	Err    error
+}
+
+//NewHTTPError constructs a new HTTPError with a detail message for the client
+func NewHTTPError(status int, code string, detail string) *HTTPError {
+	return &HTTPError{
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+//Error implements the error interface
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+//internalError wraps an unexpected error so it is logged, but not shown to the client
+func internalError(err error) *HTTPError {
+	return &HTTPError{
+		Status: http.StatusInternalServerError,
+		Code:   CodeInternal,
+		Detail: "An unexpected error occurred",
+		Err:    err,
+	}
+}
+
+//Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+//WriteError writes the error to the client as an application/problem+json
+//response. Errors that aren't an *HTTPError are treated as internal errors.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	httpErr, ok := err.(*HTTPError)
+	if !ok {
+		httpErr = internalError(err)
+	}
+	if httpErr.Err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, httpErr.Err)
+	}
+	problem := &Problem{
+		Type:     problemTypeBase + httpErr.Code,
+		Title:    http.StatusText(httpErr.Status),
+		Status:   httpErr.Status,
+		Detail:   httpErr.Detail,
+		Instance: r.URL.Path,
+		Code:     httpErr.Code,
+	}
+	respond(w, problem, httpErr.Status, ContentTypeProblemJSON)
+}
+
+//errMethodNotAllowed is returned for request methods a resource doesn't support
+var errMethodNotAllowed = NewHTTPError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Request method not allowed")
+
+//errActionNotAllowed is returned when the current user may not perform an action
+var errActionNotAllowed = NewHTTPError(http.StatusForbidden, CodeForbidden, "Action not allowed")
+
+//errUnauthenticated is returned when there is no valid session
+var errUnauthenticated = NewHTTPError(http.StatusUnauthorized, CodeUnauthenticated, "You must be signed in")
+
+//errInvalidUserID is returned when a user ID in the URL can't be parsed
+var errInvalidUserID = NewHTTPError(http.StatusBadRequest, CodeInvalidUserID, "User ID must be a number or 'me'")
+
+//userStoreError converts an error from the user store into an HTTPError
+func userStoreError(err error) *HTTPError {
+	if err == users.ErrUserNotFound {
+		return NewHTTPError(http.StatusNotFound, CodeUserNotFound, "User not found")
+	}
+	return internalError(err)
+}
+
+//inactiveUserError converts an error from users.CheckActive into an HTTPError
+func inactiveUserError(err error) *HTTPError {
+	switch err {
+	case users.ErrUserDeactivated:
+		return NewHTTPError(http.StatusForbidden, CodeAccountDeactivated, "Account has been deactivated")
+	case users.ErrUserSuspended:
+		return NewHTTPError(http.StatusForbidden, CodeAccountSuspended, "Account is suspended")
+	}
+	return internalError(err)
+}
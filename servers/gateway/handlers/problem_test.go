@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		name               string
+		err                error
+		expectedStatusCode int
+		expectedCode       string
+		expectedDetail     string
+	}{
+		{
+			"HTTP Error",
+			NewHTTPError(http.StatusBadRequest, CodeInvalidUser, "invalid email address"),
+			http.StatusBadRequest,
+			CodeInvalidUser,
+			"invalid email address",
+		},
+		{
+			"Plain Error Is Internal",
+			errors.New("database is on fire"),
+			http.StatusInternalServerError,
+			CodeInternal,
+			"An unexpected error occurred",
+		},
+		{
+			"Internal Error Hides Cause",
+			internalError(errors.New("database is on fire")),
+			http.StatusInternalServerError,
+			CodeInternal,
+			"An unexpected error occurred",
+		},
+		{
+			"User Not Found",
+			userStoreError(users.ErrUserNotFound),
+			http.StatusNotFound,
+			CodeUserNotFound,
+			"User not found",
+		},
+		{
+			"Suspended User",
+			inactiveUserError(users.ErrUserSuspended),
+			http.StatusForbidden,
+			CodeAccountSuspended,
+			"Account is suspended",
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/users/me", nil)
+		respRec := httptest.NewRecorder()
+		WriteError(respRec, req, c.err)
+
+		resp := respRec.Result()
+		if resp.StatusCode != c.expectedStatusCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d",
+				c.name, c.expectedStatusCode, resp.StatusCode)
+		}
+		contentType := resp.Header.Get(HeaderContentType)
+		if !strings.Contains(contentType, ContentTypeProblemJSON) {
+			t.Errorf("case %s: incorrect Content-Type header: expected %s but got %s",
+				c.name, ContentTypeProblemJSON, contentType)
+		}
+
+		problem := &Problem{}
+		if err := json.Unmarshal(respRec.Body.Bytes(), problem); err != nil {
+			t.Errorf("case %s: error unmarshalling problem: %v", c.name, err)
+			continue
+		}
+		if problem.Status != c.expectedStatusCode {
+			t.Errorf("case %s: incorrect problem status: expected %d but got %d",
+				c.name, c.expectedStatusCode, problem.Status)
+		}
+		if problem.Code != c.expectedCode {
+			t.Errorf("case %s: incorrect problem code: expected %s but got %s",
+				c.name, c.expectedCode, problem.Code)
+		}
+		if problem.Type != problemTypeBase+c.expectedCode {
+			t.Errorf("case %s: incorrect problem type: got %s", c.name, problem.Type)
+		}
+		if problem.Detail != c.expectedDetail {
+			t.Errorf("case %s: incorrect problem detail: expected %s but got %s",
+				c.name, c.expectedDetail, problem.Detail)
+		}
+		if problem.Instance != "/v1/users/me" {
+			t.Errorf("case %s: incorrect problem instance: got %s", c.name, problem.Instance)
+		}
+	}
+}
@@ -7,9 +7,16 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
 )
 
+//forwardedUser is the user sent to microservices in the X-User header,
+//along with the users they have blocked so services can enforce it
+type forwardedUser struct {
+	*users.User
+	BlockedUserIDs []int64 `json:"blockedUserIDs"`
+}
+
 //NewServiceProxy returns a new ReverseProxy
 //for a microservice given a comma-delimited
 //list of network addresses
@@ -27,17 +34,27 @@ func (ctx *Context) NewServiceProxy(addrs string) *httputil.ReverseProxy {
 			mx.Unlock()
 
 			r.Header.Del(HeaderUser)
-			stateStruct := &SessionState{}
-			_, err := sessions.GetState(r, ctx.SigningKey, ctx.SessionStore, stateStruct)
-			if err != nil {
+			stateStruct := GetSessionState(r)
+			if stateStruct == nil {
 				return
 			}
-			userJSON, err := json.Marshal(stateStruct.User)
+			userJSON, err := json.Marshal(&forwardedUser{
+				User:           stateStruct.User,
+				BlockedUserIDs: ctx.Blocks.BlockedIDs(stateStruct.User.ID),
+			})
 			if err != nil {
 				return
 			}
 			r.Header.Set(HeaderUser, string(userJSON))
 
 		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			WriteError(w, r, &HTTPError{
+				Status: http.StatusBadGateway,
+				Code:   CodeServiceUnavailable,
+				Detail: "Service is unavailable",
+				Err:    err,
+			})
+		},
 	}
 }
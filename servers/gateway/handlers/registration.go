@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/invites"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+//RegistrationOpen lets anyone sign up
+const RegistrationOpen = "open"
+
+//RegistrationInvite requires a valid invite code to sign up
+const RegistrationInvite = "invite"
+
+//RegistrationDomains requires an email address from an allowed domain,
+//or a valid invite code, to sign up
+const RegistrationDomains = "domains"
+
+//errInviteRequired is returned when signing up without an invite code in invite-only mode
+var errInviteRequired = NewHTTPError(http.StatusForbidden, CodeRegistrationClosed, "An invite code is required to sign up")
+
+//errDomainNotAllowed is returned when signing up with an email from a domain that isn't allowed
+var errDomainNotAllowed = NewHTTPError(http.StatusForbidden, CodeRegistrationClosed, "Email address domain is not allowed to sign up")
+
+//errInvitesUnavailable is returned when an invite code is given but no invite store is configured
+var errInvitesUnavailable = NewHTTPError(http.StatusForbidden, CodeInvalidInvite, "Invites are not available")
+
+//RegistrationPolicy decides who is allowed to sign up
+type RegistrationPolicy struct {
+	Mode           string
+	AllowedDomains []string
+}
+
+//NewRegistrationPolicy constructs a new RegistrationPolicy from a mode
+//and a comma-delimited list of allowed email domains
+func NewRegistrationPolicy(mode string, domains string) (*RegistrationPolicy, error) {
+	if len(mode) == 0 {
+		mode = RegistrationOpen
+	}
+	policy := &RegistrationPolicy{Mode: mode}
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); len(domain) > 0 {
+			policy.AllowedDomains = append(policy.AllowedDomains, domain)
+		}
+	}
+
+	switch mode {
+	case RegistrationOpen, RegistrationInvite:
+	case RegistrationDomains:
+		if len(policy.AllowedDomains) == 0 {
+			return nil, fmt.Errorf("registration mode %s requires at least one allowed domain", mode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown registration mode %s", mode)
+	}
+	return policy, nil
+}
+
+//allowsEmail returns true if the email address belongs to an allowed domain
+func (rp *RegistrationPolicy) allowsEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range rp.AllowedDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+//checkRegistration returns an error if the new user isn't allowed to sign up.
+//If the new user has an invite code, the still usable invite is returned.
+func (ctx *Context) checkRegistration(newUser *users.NewUser) (*invites.Invite, error) {
+	if len(newUser.InviteCode) > 0 {
+		if ctx.InviteStore == nil {
+			return nil, errInvitesUnavailable
+		}
+		invite, err := ctx.InviteStore.GetByCode(newUser.InviteCode)
+		if err != nil {
+			return nil, inviteError(err)
+		}
+		if err := invite.CheckUsable(time.Now()); err != nil {
+			return nil, inviteError(err)
+		}
+		return invite, nil
+	}
+
+	if ctx.Registration == nil {
+		return nil, nil
+	}
+	switch ctx.Registration.Mode {
+	case RegistrationInvite:
+		return nil, errInviteRequired
+	case RegistrationDomains:
+		if !ctx.Registration.allowsEmail(newUser.Email) {
+			return nil, errDomainNotAllowed
+		}
+	}
+	return nil, nil
+}
+
+//inviteError converts an error from using an invite into an HTTPError
+func inviteError(err error) *HTTPError {
+	switch err {
+	case invites.ErrInviteNotFound:
+		return NewHTTPError(http.StatusForbidden, CodeInvalidInvite, "Invite code is not valid")
+	case invites.ErrInviteExpired, invites.ErrInviteUsedUp:
+		return NewHTTPError(http.StatusForbidden, CodeInvalidInvite, err.Error())
+	}
+	return internalError(err)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+func TestCheckRegistration(t *testing.T) {
+	cases := []struct {
+		name        string
+		mode        string
+		domains     string
+		email       string
+		expectedErr error
+	}{
+		{
+			"Open Registration",
+			RegistrationOpen,
+			"",
+			"test1@gmail.com",
+			nil,
+		},
+		{
+			"Default Mode Is Open",
+			"",
+			"",
+			"test1@gmail.com",
+			nil,
+		},
+		{
+			"Invite Only Without Code",
+			RegistrationInvite,
+			"",
+			"test1@uw.edu",
+			errInviteRequired,
+		},
+		{
+			"Allowed Domain",
+			RegistrationDomains,
+			"uw.edu, example.com",
+			"test1@UW.edu",
+			nil,
+		},
+		{
+			"Domain Not Allowed",
+			RegistrationDomains,
+			"uw.edu",
+			"test1@gmail.com",
+			errDomainNotAllowed,
+		},
+	}
+
+	for _, c := range cases {
+		policy, err := NewRegistrationPolicy(c.mode, c.domains)
+		if err != nil {
+			t.Fatalf("case %s: unexpected error creating policy: %v", c.name, err)
+		}
+		ctx := &Context{Registration: policy}
+		_, err = ctx.checkRegistration(&users.NewUser{Email: c.email})
+		if err != c.expectedErr {
+			t.Errorf("case %s: expected error %v but got %v", c.name, c.expectedErr, err)
+		}
+	}
+
+	if _, err := NewRegistrationPolicy(RegistrationDomains, ""); err == nil {
+		t.Errorf("expected error creating domains policy without domains")
+	}
+	if _, err := NewRegistrationPolicy("closed", ""); err == nil {
+		t.Errorf("expected error creating policy with unknown mode")
+	}
+
+	ctx := &Context{}
+	if _, err := ctx.checkRegistration(&users.NewUser{InviteCode: "abc"}); err != errInvitesUnavailable {
+		t.Errorf("expected error %v but got %v", errInvitesUnavailable, err)
+	}
+}
@@ -11,7 +11,7 @@ func respond(w http.ResponseWriter, value interface{}, statusCode int, contentTy
 	w.WriteHeader(statusCode)
 
 	switch contentType {
-	case ContentTypeJSON:
+	case ContentTypeJSON, ContentTypeProblemJSON:
 		if err := json.NewEncoder(w).Encode(value); err != nil {
 			log.Printf("Error encoding JSON: %v", err)
 		}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/affinity"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+//SearchBooster boosts user search results by how closely the searching user
+//is connected to each one, through shared channels and recent direct messages.
+//A nil *SearchBooster is valid and never boosts anyone.
+type SearchBooster struct {
+	store   affinity.Store
+	weights affinity.Weights
+}
+
+//NewSearchBooster constructs a new SearchBooster
+func NewSearchBooster(store affinity.Store, weights affinity.Weights) *SearchBooster {
+	return &SearchBooster{
+		store:   store,
+		weights: weights,
+	}
+}
+
+//Boosts returns the boost of each found user for the user searching, keyed by
+//user ID. Errors are logged and treated as no boosts, so search still works.
+func (sb *SearchBooster) Boosts(userID int64, found []*users.User) map[int64]float64 {
+	if sb == nil || len(found) == 0 {
+		return nil
+	}
+	candidates := make([]int64, len(found))
+	for i, user := range found {
+		candidates[i] = user.ID
+	}
+	affinities, err := sb.store.Get(userID, candidates, time.Now().Add(-affinity.RecentWindow))
+	if err != nil {
+		log.Printf("error getting search affinities for user %d: %v", userID, err)
+		return nil
+	}
+	return sb.weights.Scores(affinities)
+}
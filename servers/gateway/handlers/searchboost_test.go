@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/affinity"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+//fakeAffinityStore is an affinity.Store returning fixed affinities
+type fakeAffinityStore struct {
+	affinities map[int64]*affinity.Affinity
+	err        error
+	candidates []int64
+	since      time.Time
+}
+
+func (s *fakeAffinityStore) Get(userID int64, candidates []int64, since time.Time) (map[int64]*affinity.Affinity, error) {
+	s.candidates = candidates
+	s.since = since
+	return s.affinities, s.err
+}
+
+func TestSearchBooster(t *testing.T) {
+	found := []*users.User{{ID: 2}, {ID: 3}, {ID: 4}}
+	store := &fakeAffinityStore{affinities: map[int64]*affinity.Affinity{
+		2: {SharedChannels: 2},
+		4: {SharedChannels: 1, DirectMessages: 3},
+	}}
+	booster := NewSearchBooster(store, affinity.Weights{SharedChannel: 1, DirectMessage: 0.5})
+
+	boosts := booster.Boosts(1, found)
+	if expected := map[int64]float64{2: 2, 4: 2}; !reflect.DeepEqual(boosts, expected) {
+		t.Errorf("expected boosts %v but got %v", expected, boosts)
+	}
+	if !reflect.DeepEqual(store.candidates, []int64{2, 3, 4}) {
+		t.Errorf("expected every found user to be a candidate, got %v", store.candidates)
+	}
+	if age := time.Since(store.since); age < affinity.RecentWindow || age > affinity.RecentWindow+time.Minute {
+		t.Errorf("expected direct messages since the start of the recent window, got %v ago", age)
+	}
+
+	store.err = errors.New("store failed")
+	if boosts := booster.Boosts(1, found); boosts != nil {
+		t.Errorf("expected no boosts when the store fails, got %v", boosts)
+	}
+
+	var none *SearchBooster
+	if boosts := none.Boosts(1, found); boosts != nil {
+		t.Errorf("expected a nil booster to boost nobody, got %v", boosts)
+	}
+}
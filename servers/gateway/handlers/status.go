@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/audit"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+//UserStatusHandler handles requests to deactivate, suspend or reactivate a user.
+//Users may deactivate their own account, all other changes require an admin.
+func (ctx *Context) UserStatusHandler(w http.ResponseWriter, r *http.Request) {
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
+		return
+	}
+	vars := mux.Vars(r)
+	reqID, err := parseID(vars["id"], stateStruct)
+	if err != nil {
+		WriteError(w, r, errInvalidUserID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		status := &users.Status{}
+		if err := decodeReq(r, status); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		selfDeactivate := reqID == stateStruct.User.ID && status.Deactivated && status.SuspendedUntil == nil
+		if !stateStruct.User.IsAdmin() && !selfDeactivate {
+			WriteError(w, r, errActionNotAllowed)
+			return
+		}
+
+		prevUser, err := ctx.UserStore.GetByID(reqID)
+		if err != nil {
+			WriteError(w, r, userStoreError(err))
+			return
+		}
+		updatedUser, err := ctx.UserStore.UpdateStatus(reqID, status)
+		if err != nil {
+			WriteError(w, r, userStoreError(err))
+			return
+		}
+
+		switch {
+		case updatedUser.Deactivated && !prevUser.Deactivated:
+			ctx.Index.Delete(prevUser.ID)
+			ctx.publishUserEvent(EventUserDeleted, updatedUser)
+		case !updatedUser.Deactivated && prevUser.Deactivated:
+			ctx.Index.Upsert(updatedUser.ID, updatedUser.SearchFields())
+			ctx.publishUserEvent(EventUserUpdated, updatedUser)
+		case updatedUser.CheckActive(time.Now()) != nil:
+			//other gateways close the suspended user's WebSockets
+			ctx.publishUserEvent(EventUserUpdated, updatedUser)
+		}
+		ctx.recordAudit(r, audit.ActionStatusChanged, stateStruct.User.ID, updatedUser.ID, audit.OutcomeSuccess,
+			fmt.Sprintf("deactivated=%t suspended=%t", updatedUser.Deactivated, updatedUser.SuspendedUntil != nil))
+		if updatedUser.CheckActive(time.Now()) != nil {
+			if err := ctx.endUserSessions(updatedUser.ID); err != nil {
+				WriteError(w, r, internalError(fmt.Errorf("ending sessions of user %d: %v", updatedUser.ID, err)))
+				return
+			}
+			ctx.recordAudit(r, audit.ActionSessionsRevoked, stateStruct.User.ID, updatedUser.ID, audit.OutcomeSuccess, "")
+		}
+		respond(w, updatedUser, http.StatusOK, ContentTypeJSON)
+
+	default:
+		WriteError(w, r, errMethodNotAllowed)
+		return
+	}
+}
+
+//endUserSessions ends every session and closes every WebSocket belonging to
+//the user on this gateway. Other gateways close the user's WebSockets when
+//they get the user event.
+func (ctx *Context) endUserSessions(userID int64) error {
+	ctx.Notifier.CloseUserConnections(userID)
+	return ctx.SessionStore.DeleteUserSessions(userID)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/streadway/amqp"
+)
+
+//Types of event published when users sign up or are deactivated.
+//Profile changes are published as EventUserUpdated.
+const (
+	EventUserCreated = "user-created"
+	EventUserDeleted = "user-deleted"
+)
+
+//UserEventsExchange is the fanout exchange user events are published to,
+//so that every gateway gets a copy to keep its search index current
+//and to close the WebSockets of users who can no longer sign in
+const UserEventsExchange = "user-events"
+
+//userSyncEvent is published to every gateway when a user is created,
+//updated or deleted. Deleted events only have the user's ID.
+type userSyncEvent struct {
+	Type   string      `json:"type"`
+	User   *users.User `json:"user,omitempty"`
+	UserID int64       `json:"userID"`
+}
+
+//publishUserEvent shares a change to the user with every gateway, if the Context has a UserEvents publisher
+func (ctx *Context) publishUserEvent(eventType string, user *users.User) {
+	if ctx.UserEvents == nil {
+		return
+	}
+	event := &userSyncEvent{Type: eventType, User: user, UserID: user.ID}
+	if eventType == EventUserDeleted {
+		event.User = nil
+	}
+	if err := ctx.UserEvents.Publish(event); err != nil {
+		log.Printf("error publishing user event: %v", err)
+	}
+}
+
+//ConsumeUserEvents declares the user events exchange and a queue bound to it
+//for this gateway alone, and returns the events delivered to the queue.
+//The queue is deleted when the gateway disconnects.
+func ConsumeUserEvents(channel *amqp.Channel) (<-chan amqp.Delivery, error) {
+	if err := channel.ExchangeDeclare(UserEventsExchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("declaring user events exchange: %v", err)
+	}
+	q, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("declaring user events queue: %v", err)
+	}
+	if err := channel.QueueBind(q.Name, "", UserEventsExchange, false, nil); err != nil {
+		return nil, fmt.Errorf("binding user events queue: %v", err)
+	}
+	return channel.Consume(q.Name, "", true, true, false, false, nil)
+}
+
+//SyncUserEvents applies the user events published by every gateway to the
+//index, and closes the WebSockets of users who can no longer sign in, since
+//they may be connected to this gateway rather than the one that changed them.
+//The index is nil when it is shared by every gateway, which keeps it current.
+//Events that are lost are caught up by the periodic reconciliation.
+func SyncUserEvents(index indexes.Index, notifier *Notifier, events <-chan amqp.Delivery) {
+	for event := range events {
+		if err := applyUserEvent(index, notifier, event.Body); err != nil {
+			log.Printf("error applying user event: %v", err)
+		}
+	}
+}
+
+//applyUserEvent updates the index for a user event, and closes the user's
+//WebSockets if they were deleted, deactivated or suspended
+func applyUserEvent(index indexes.Index, notifier *Notifier, body []byte) error {
+	event := &userSyncEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return fmt.Errorf("decoding user event: %v", err)
+	}
+	active := true
+	switch event.Type {
+	case EventUserCreated, EventUserUpdated:
+		if event.User == nil {
+			return fmt.Errorf("%s event has no user", event.Type)
+		}
+		if index != nil {
+			if event.User.Deactivated {
+				index.Delete(event.User.ID)
+			} else {
+				index.Upsert(event.User.ID, event.User.SearchFields())
+			}
+		}
+		active = event.User.CheckActive(time.Now()) == nil
+	case EventUserDeleted:
+		if index != nil {
+			index.Delete(event.UserID)
+		}
+		active = false
+	default:
+		return fmt.Errorf("unknown user event type %q", event.Type)
+	}
+	if !active && notifier != nil {
+		notifier.CloseUserConnections(event.UserID)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+)
+
+func TestApplyUserEvent(t *testing.T) {
+	gopher := &users.User{ID: 1, UserName: "gopher", FirstName: "Competent", LastName: "Gopher"}
+	renamed := &users.User{ID: 1, UserName: "gopher", FirstName: "Mary", LastName: "Gopher"}
+	deactivated := &users.User{ID: 1, UserName: "gopher", FirstName: "Competent", LastName: "Gopher", Deactivated: true}
+	encode := func(event *userSyncEvent) []byte {
+		body, _ := json.Marshal(event)
+		return body
+	}
+
+	cases := []struct {
+		name        string
+		body        []byte
+		expectError bool
+		queries     map[string][]int64
+	}{
+		{
+			"Created",
+			encode(&userSyncEvent{Type: EventUserCreated, User: gopher, UserID: 1}),
+			false,
+			map[string][]int64{"comp": {1}, "goph": {1}},
+		},
+		{
+			"Updated",
+			encode(&userSyncEvent{Type: EventUserUpdated, User: renamed, UserID: 1}),
+			false,
+			map[string][]int64{"comp": nil, "mary": {1}},
+		},
+		{
+			"Updated To Deactivated",
+			encode(&userSyncEvent{Type: EventUserUpdated, User: deactivated, UserID: 1}),
+			false,
+			map[string][]int64{"comp": nil, "goph": nil},
+		},
+		{
+			"Deleted",
+			encode(&userSyncEvent{Type: EventUserDeleted, UserID: 1}),
+			false,
+			map[string][]int64{"comp": nil, "goph": nil},
+		},
+		{
+			"Created Without User",
+			encode(&userSyncEvent{Type: EventUserCreated, UserID: 1}),
+			true,
+			map[string][]int64{"comp": {1}},
+		},
+		{
+			"Unknown Type",
+			encode(&userSyncEvent{Type: "user-renamed", User: renamed, UserID: 1}),
+			true,
+			map[string][]int64{"comp": {1}},
+		},
+		{
+			"Invalid JSON",
+			[]byte("{"),
+			true,
+			map[string][]int64{"comp": {1}},
+		},
+	}
+
+	for _, c := range cases {
+		index := indexes.NewTrie()
+		index.Upsert(gopher.ID, gopher.SearchFields())
+		err := applyUserEvent(index, nil, c.body)
+		if c.expectError && err == nil {
+			t.Errorf("case %s: expected error but got none", c.name)
+		}
+		if !c.expectError && err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+		}
+		for query, expected := range c.queries {
+			if found := index.FindAll([]string{query}, 5, nil); !reflect.DeepEqual(found, expected) {
+				t.Errorf("case %s: expected %q to find %v but got %v", c.name, query, expected, found)
+			}
+		}
+	}
+}
+
+func TestApplyUserEventClosesConnections(t *testing.T) {
+	suspendedUntil := time.Now().Add(time.Hour)
+	encode := func(event *userSyncEvent) []byte {
+		body, _ := json.Marshal(event)
+		return body
+	}
+	cases := []struct {
+		name           string
+		body           []byte
+		expectedClosed bool
+	}{
+		{
+			"Updated",
+			encode(&userSyncEvent{Type: EventUserUpdated, User: &users.User{ID: 1}, UserID: 1}),
+			false,
+		},
+		{
+			"Suspended",
+			encode(&userSyncEvent{Type: EventUserUpdated, User: &users.User{ID: 1, SuspendedUntil: &suspendedUntil}, UserID: 1}),
+			true,
+		},
+		{
+			"Deleted",
+			encode(&userSyncEvent{Type: EventUserDeleted, UserID: 1}),
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		n := NewNotifier()
+		conn := &client{userID: 1, send: make(chan []byte, 1), done: make(chan struct{})}
+		n.currConnections[1] = []*client{conn}
+		//a shared index isn't updated by the events
+		if err := applyUserEvent(nil, n, c.body); err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		closed := false
+		select {
+		case <-conn.done:
+			closed = true
+		default:
+		}
+		if closed != c.expectedClosed {
+			t.Errorf("case %s: expected the connection to be closed to be %t but got %t", c.name, c.expectedClosed, closed)
+		}
+	}
+}
+
+func TestPublishUserEvent(t *testing.T) {
+	publisher := &recordingPublisher{}
+	ctx := &Context{UserEvents: publisher}
+	user := &users.User{ID: 3, UserName: "gopher"}
+
+	ctx.publishUserEvent(EventUserCreated, user)
+	ctx.publishUserEvent(EventUserDeleted, user)
+	if len(publisher.events) != 2 {
+		t.Fatalf("expected 2 events but got %d", len(publisher.events))
+	}
+	if created := publisher.events[0].(*userSyncEvent); created.User != user || created.UserID != 3 {
+		t.Errorf("expected the created event to have the user, got %+v", created)
+	}
+	if deleted := publisher.events[1].(*userSyncEvent); deleted.User != nil || deleted.UserID != 3 {
+		t.Errorf("expected the deleted event to only have the user's ID, got %+v", deleted)
+	}
+
+	//no publisher means nothing is published
+	(&Context{}).publishUserEvent(EventUserCreated, user)
+}
+
+//recordingPublisher is an EventPublisher that keeps the events it publishes
+type recordingPublisher struct {
+	events []interface{}
+}
+
+func (p *recordingPublisher) Publish(event interface{}) error {
+	p.events = append(p.events, event)
+	return nil
+}
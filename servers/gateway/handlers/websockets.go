@@ -1,11 +1,9 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/gorilla/websocket"
-	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
 )
 
 //TODO: add a handler that upgrades clients to a WebSocket connection
@@ -35,10 +33,9 @@ func NewWebSocketHandler(context *Context) *WebSocketHandler {
 
 //ServeHTTP implements the http.Handler interface for the WebSocketsHandler
 func (wsh *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	stateStruct := &SessionState{}
-	_, err := sessions.GetState(r, wsh.ctx.SigningKey, wsh.ctx.SessionStore, stateStruct)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error getting session state: %v", err), http.StatusUnauthorized)
+	stateStruct := GetSessionState(r)
+	if stateStruct == nil {
+		WriteError(w, r, errUnauthenticated)
 		return
 	}
 	// add websocket to context
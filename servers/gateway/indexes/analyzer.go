@@ -0,0 +1,101 @@
+package indexes
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//Analyzer turns text into the keys it is indexed and searched by.
+//Text is indexed and queried with the same analyzer so that both
+//sides of a search agree on what a key looks like.
+type Analyzer struct {
+	//Filters are applied in order to the whole text before it is tokenized
+	Filters []func(string) string
+	//Tokenize splits the filtered text into keys
+	Tokenize func(string) []string
+}
+
+//DefaultAnalyzer normalises compatibility characters such as full-width
+//letters, folds case and the diacritics of Latin, Greek and Cyrillic
+//letters, and splits on whitespace and punctuation
+var DefaultAnalyzer = &Analyzer{
+	Filters:  []func(string) string{norm.NFKC.String, FoldCase, FoldDiacritics},
+	Tokenize: TokenizeWords,
+}
+
+//Analyze returns the keys for the text using the DefaultAnalyzer
+func Analyze(text string) []string {
+	return DefaultAnalyzer.Analyze(text)
+}
+
+//Analyze returns the keys for the text, which may be empty
+//if the text has no letters or numbers
+func (a *Analyzer) Analyze(text string) []string {
+	for _, filter := range a.Filters {
+		text = filter(text)
+	}
+	return a.Tokenize(text)
+}
+
+//FoldCase lowercases the text, also folding the final form of sigma
+//so that Greek words match however they were split
+func FoldCase(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == 'ς' {
+			return 'σ'
+		}
+		return unicode.ToLower(r)
+	}, text)
+}
+
+//foldScripts are the scripts whose diacritics are folded. The marks of other
+//scripts, such as Devanagari vowel signs or kana voicing marks, change which
+//letter is written, so they are kept.
+var foldScripts = []*unicode.RangeTable{unicode.Latin, unicode.Greek, unicode.Cyrillic}
+
+//letterFolds are lowercase letters that have no decomposition but
+//are commonly typed as their unaccented or spelled out forms
+var letterFolds = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'ø': "o",
+	'ł': "l",
+	'đ': "d",
+	'ð': "d",
+	'þ': "th",
+	'ı': "i",
+}
+
+//FoldDiacritics removes the accents from Latin, Greek and Cyrillic letters,
+//so "José" becomes "jose" and "Ёлка" becomes "Елка". Letters without
+//a decomposition, like "ø", are folded if they are lowercase.
+func FoldDiacritics(text string) string {
+	var b strings.Builder
+	folding := false
+	for _, r := range norm.NFD.String(text) {
+		if unicode.Is(unicode.Mn, r) {
+			if !folding {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		folding = unicode.IsOneOf(foldScripts, r)
+		if fold, ok := letterFolds[r]; ok {
+			b.WriteString(fold)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+//TokenizeWords splits the text into runs of letters, marks and numbers,
+//so whitespace and punctuation of any kind or length never make empty keys
+func TokenizeWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,123 @@
+package indexes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			"Lowercase",
+			"Competent Gopher",
+			[]string{"competent", "gopher"},
+		},
+		{
+			"Latin Diacritics",
+			"José Núñez-Ångström",
+			[]string{"jose", "nunez", "angstrom"},
+		},
+		{
+			"Letters Without Decomposition",
+			"Straße Øre Łukasz",
+			[]string{"strasse", "ore", "lukasz"},
+		},
+		{
+			"Tabs And Repeated Spaces",
+			"  Mary\t\tAnn \n Smith  ",
+			[]string{"mary", "ann", "smith"},
+		},
+		{
+			"Punctuation",
+			"O'Brien, Jr. (test_1234)",
+			[]string{"o", "brien", "jr", "test", "1234"},
+		},
+		{
+			"Full Width",
+			"ＪＯＨＮ　Ｓｍｉｔｈ１２",
+			[]string{"john", "smith12"},
+		},
+		{
+			"Ligature",
+			"ﬁnn",
+			[]string{"finn"},
+		},
+		{
+			"Cyrillic",
+			"Ёлка Пётр",
+			[]string{"елка", "петр"},
+		},
+		{
+			"Greek",
+			"Άννα ΟΔΥΣΣΕΥΣ",
+			[]string{"αννα", "οδυσσευσ"},
+		},
+		{
+			"Half Width Kana",
+			"ｶﾞｲﾄﾞ",
+			[]string{"ガイド"},
+		},
+		{
+			"Kana Voicing Marks Kept",
+			"ガイド",
+			[]string{"ガイド"},
+		},
+		{
+			"Devanagari Vowel Signs Kept",
+			"प्रिया शर्मा",
+			[]string{"प्रिया", "शर्मा"},
+		},
+		{
+			"Chinese",
+			"世界",
+			[]string{"世界"},
+		},
+		{
+			"No Words",
+			" \t-- ",
+			[]string{},
+		},
+	}
+
+	for _, c := range cases {
+		result := Analyze(c.input)
+		if len(result) == 0 && len(c.expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("case %s: expected %q but got %q", c.name, c.expected, result)
+		}
+	}
+}
+
+func TestAnalyzerMatchesQueries(t *testing.T) {
+	cases := []struct {
+		name  string
+		name1 string
+		query string
+	}{
+		{"Unaccented Query", "José", "jose"},
+		{"Accented Query", "Jose", "JOSÉ"},
+		{"Full Width Query", "Tanaka", "ｔａｎａｋａ"},
+		{"Composed And Decomposed", "Zo\u00eb", "Zoe\u0308"},
+		{"Final Sigma", "Οδυσσεύς", "οδυσσευσ"},
+		{"Katakana", "ｻﾄｳ", "サトウ"},
+	}
+
+	for _, c := range cases {
+		trie := NewTrie()
+		trie.AddConvertedUsers(c.name1, "", "", 1)
+		keys := Analyze(c.query)
+		if len(keys) != 1 {
+			t.Errorf("case %s: expected one key for %q but got %q", c.name, c.query, keys)
+			continue
+		}
+		if result := trie.Find(keys[0], 1); !reflect.DeepEqual(result, []int64{1}) {
+			t.Errorf("case %s: expected %q to find %q, got %v", c.name, c.query, c.name1, result)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package indexes
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+//maxFuzzyCandidates bounds how many values a fuzzy search scores,
+//so that short queries matching most of the trie stay cheap
+const maxFuzzyCandidates = 1000
+
+//MaxEdits returns the number of typos tolerated in the query. Short
+//queries match too much with any typos, so they have to be exact.
+func MaxEdits(query string) int {
+	switch n := utf8.RuneCountInString(query); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+//fuzzyMatch is a value found by a fuzzy search, with how well its key matched
+type fuzzyMatch struct {
+	value int64
+	key   string
+	//distance is the fewest edits turning the query into a prefix of the key
+	distance int
+	//prefixLen is the number of leading runes the query and key share
+	prefixLen int
+}
+
+//better returns true if the match should be ranked before the other
+func (m *fuzzyMatch) better(other *fuzzyMatch) bool {
+	if m.distance != other.distance {
+		return m.distance < other.distance
+	}
+	if m.prefixLen != other.prefixLen {
+		return m.prefixLen > other.prefixLen
+	}
+	if m.key != other.key {
+		return m.key < other.key
+	}
+	return m.value < other.value
+}
+
+//fuzzySearch holds the state of one fuzzy search through a tree index
+type fuzzySearch struct {
+	query    []rune
+	maxEdits int
+	excluded int64set
+	matches  map[int64]*fuzzyMatch
+}
+
+//fuzzyPosition is how far a fuzzy search has got through one key
+type fuzzyPosition struct {
+	key []rune
+	//row is the edit distance from each prefix of the query to the key
+	row []int
+	//best is the smallest distance from the query to any prefix of the key
+	best int
+	//minCell is the smallest distance in the row
+	minCell   int
+	prefixLen int
+}
+
+//newFuzzySearch starts a fuzzy search, or returns nil if nothing can match
+func newFuzzySearch(query string, maxEdits int, n int, exclude []int64) *fuzzySearch {
+	if len(query) == 0 || n <= 0 || maxEdits < 0 {
+		return nil
+	}
+	search := &fuzzySearch{
+		query:    []rune(query),
+		maxEdits: maxEdits,
+		excluded: int64set{},
+		matches:  map[int64]*fuzzyMatch{},
+	}
+	for _, v := range exclude {
+		search.excluded.add(v)
+	}
+	return search
+}
+
+//start returns the position before the first rune of a key
+func (s *fuzzySearch) start() *fuzzyPosition {
+	//the first row is the edit distance from each prefix of the query to the empty key
+	row := make([]int, len(s.query)+1)
+	for i := range row {
+		row[i] = i
+	}
+	return &fuzzyPosition{key: []rune{}, row: row, best: len(s.query)}
+}
+
+//FindFuzzy finds up to `n` values whose keys start with a prefix within
+//`maxEdits` edits (insertions, deletions or substitutions) of `query`,
+//skipping any values in `exclude`. Values are ordered by the fewest edits,
+//then by the longest prefix shared exactly with the query.
+func (t *Trie) FindFuzzy(query string, maxEdits int, n int, exclude []int64) []int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	search := newFuzzySearch(query, maxEdits, n, exclude)
+	if t.length == 0 || search == nil {
+		return nil
+	}
+	start := search.start()
+	for _, k := range t.root.sortKeys() {
+		search.visit(t.root.children[k], k, start)
+	}
+	return search.results(n)
+}
+
+//results returns the values of the `n` best matches
+func (s *fuzzySearch) results(n int) []int64 {
+	matches := make([]*fuzzyMatch, 0, len(s.matches))
+	for _, match := range s.matches {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].better(matches[j]) })
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	result := make([]int64, len(matches))
+	for i, match := range matches {
+		result[i] = match.value
+	}
+	return result
+}
+
+//visit moves the search on through the node's key, collects the node's values
+//if the query is within reach of a prefix of the key, and visits the node's
+//children while a match is still possible.
+func (s *fuzzySearch) visit(node *trieNode, k rune, pos *fuzzyPosition) {
+	pos = s.next(pos, k)
+	s.addAll(node.values, pos)
+	if s.done(pos) {
+		return
+	}
+	for _, child := range node.sortKeys() {
+		s.visit(node.children[child], child, pos)
+	}
+}
+
+//next computes the next row of the Levenshtein matrix for the next rune of the key
+func (s *fuzzySearch) next(prev *fuzzyPosition, k rune) *fuzzyPosition {
+	pos := &fuzzyPosition{
+		key:       append(prev.key, k),
+		row:       make([]int, len(prev.row)),
+		best:      prev.best,
+		prefixLen: prev.prefixLen,
+	}
+	if pos.prefixLen == len(pos.key)-1 && pos.prefixLen < len(s.query) && s.query[pos.prefixLen] == k {
+		pos.prefixLen++
+	}
+
+	pos.row[0] = prev.row[0] + 1
+	pos.minCell = pos.row[0]
+	for i := 1; i < len(pos.row); i++ {
+		cost := 1
+		if s.query[i-1] == k {
+			cost = 0
+		}
+		pos.row[i] = min3(prev.row[i]+1, pos.row[i-1]+1, prev.row[i-1]+cost)
+		if pos.row[i] < pos.minCell {
+			pos.minCell = pos.row[i]
+		}
+	}
+	if last := pos.row[len(pos.row)-1]; last < pos.best {
+		pos.best = last
+	}
+	return pos
+}
+
+//addAll adds the values of a key if the query is within reach of a prefix of the key
+func (s *fuzzySearch) addAll(values int64set, pos *fuzzyPosition) {
+	if pos.best > s.maxEdits {
+		return
+	}
+	for _, v := range values.sorted() {
+		s.add(v, string(pos.key), pos.best, pos.prefixLen)
+	}
+}
+
+//done returns true if no longer key can match, because every cell
+//is too far away, or if enough candidates have been found
+func (s *fuzzySearch) done(pos *fuzzyPosition) bool {
+	return (pos.best > s.maxEdits && pos.minCell > s.maxEdits) || len(s.matches) >= maxFuzzyCandidates
+}
+
+//add records the match for the value, keeping the better of any earlier match
+func (s *fuzzySearch) add(value int64, key string, distance int, prefixLen int) {
+	if s.excluded.has(value) {
+		return
+	}
+	match := &fuzzyMatch{value: value, key: key, distance: distance, prefixLen: prefixLen}
+	if existing, ok := s.matches[value]; !ok || match.better(existing) {
+		s.matches[value] = match
+	}
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
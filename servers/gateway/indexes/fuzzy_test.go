@@ -0,0 +1,109 @@
+package indexes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindFuzzy(t *testing.T) {
+	input := []TestKeyVal{
+		{"john", 1},
+		{"joan", 2},
+		{"jonathan", 3},
+		{"jane", 4},
+		{"bob", 5},
+	}
+	cases := []struct {
+		name     string
+		query    string
+		maxEdits int
+		n        int
+		exclude  []int64
+		expected []int64
+	}{
+		{
+			"Transposed Letters",
+			"jonh",
+			1,
+			5,
+			nil,
+			[]int64{3, 1},
+		},
+		{
+			"Fewer Edits Rank First",
+			"jonh",
+			2,
+			5,
+			nil,
+			[]int64{3, 1, 2, 4},
+		},
+		{
+			"Exact Prefix Ranks First",
+			"jona",
+			1,
+			5,
+			nil,
+			[]int64{3, 2},
+		},
+		{
+			"No Edits Is A Prefix Match",
+			"jo",
+			0,
+			5,
+			nil,
+			[]int64{2, 1, 3},
+		},
+		{
+			"Limit",
+			"jonh",
+			1,
+			1,
+			nil,
+			[]int64{3},
+		},
+		{
+			"Excluded",
+			"jonh",
+			1,
+			5,
+			[]int64{3},
+			[]int64{1},
+		},
+		{
+			"Too Many Typos",
+			"xyzw",
+			1,
+			5,
+			nil,
+			nil,
+		},
+	}
+
+	trie := NewTrie()
+	for _, v := range input {
+		trie.Add(v.Key, v.Val)
+	}
+	for _, c := range cases {
+		result := trie.FindFuzzy(c.query, c.maxEdits, c.n, c.exclude)
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, result)
+		}
+	}
+}
+
+func TestMaxEdits(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected int
+	}{
+		{"jo", 0},
+		{"jonh", 1},
+		{"jonathon", 2},
+		{"世界", 0},
+	}
+	for _, c := range cases {
+		if got := MaxEdits(c.query); got != c.expected {
+			t.Errorf("query %s: expected %d edits but got %d", c.query, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package indexes
+
+import (
+	"io"
+	"time"
+)
+
+//Index is a search index of int64 values stored under string keys,
+//found by key prefix. Trie and RadixTree are both Indexes, so
+//either can be used for user search.
+//
+//Values can be indexed as documents with Upsert and Delete, which
+//replace every key of the value at once. The index remembers which keys
+//each value is stored under, so callers don't have to.
+type Index interface {
+	//Len returns the number of entries in the index
+	Len() int
+	//Add adds a key and value to the index
+	Add(key string, value int64)
+	//Remove removes a key/value pair from the index
+	Remove(key string, value int64)
+
+	//Upsert replaces the keys of the document with the value `id`
+	//with the analyzed keys of its fields
+	Upsert(id int64, fields []string)
+	//Delete removes every key of the document with the value `id`
+	Delete(id int64)
+	//IDs returns every value stored in the index
+	IDs() []int64
+
+	//Find finds `n` values matching `prefix`, in key order,
+	//and in ascending order for values with the same key
+	Find(prefix string, n int) []int64
+	//FindExcluding finds `n` values matching `prefix`, skipping any in `exclude`
+	FindExcluding(prefix string, n int, exclude []int64) []int64
+	//FindAll finds `n` distinct values with keys matching every one of the `prefixes`
+	FindAll(prefixes []string, n int, exclude []int64) []int64
+	//FindFuzzy finds `n` values with keys starting within `maxEdits` edits of `query`
+	FindFuzzy(query string, maxEdits int, n int, exclude []int64) []int64
+
+	//AddConvertedUsers adds the analyzed keys of the user's names to the index
+	AddConvertedUsers(firstName string, lastName string, userName string, id int64)
+	//RemoveConvertedUsers removes the analyzed keys of the user's first and last name,
+	//but not their username, Delete removes every key
+	RemoveConvertedUsers(firstName string, lastName string, id int64)
+
+	//Save writes a snapshot of the index to w, which Load can read into any Index
+	Save(w io.Writer, takenAt time.Time) error
+}
+
+//prefixNode is the node a prefix leads to in a tree index,
+//holding every value whose key starts with the prefix
+type prefixNode interface {
+	//findHelper adds up to `n` values that `keep` returns true for to the result,
+	//depth first in key order
+	findHelper(n int, keep func(int64) bool, result *[]int64)
+	//collect adds every value to the set
+	collect(values int64set)
+}
+
+//findExcluding finds `n` values under the node like Find, skipping any of the values in `exclude`
+func findExcluding(node prefixNode, n int, exclude []int64) []int64 {
+	excluded := int64set{}
+	for _, v := range exclude {
+		excluded.add(v)
+	}
+	var result []int64
+	node.findHelper(n, func(v int64) bool { return !excluded.has(v) }, &result)
+	return result
+}
+
+//findAll finds `n` distinct values under the nodes of every one of the
+//`prefixes`, in the order they are found for the first prefix, skipping
+//any of the values in `exclude`. `find` returns the node for a prefix,
+//or nil if the prefix is not in the index.
+func findAll(prefixes []string, n int, exclude []int64, find func(prefix string) prefixNode) []int64 {
+	if len(prefixes) == 0 || n <= 0 {
+		return nil
+	}
+	nodes := make([]prefixNode, len(prefixes))
+	for i, prefix := range prefixes {
+		if len(prefix) == 0 {
+			return nil
+		}
+		if nodes[i] = find(prefix); nodes[i] == nil {
+			return nil
+		}
+	}
+	//the values matching every other prefix, which the first prefix's values must be in
+	var required int64set
+	for _, node := range nodes[1:] {
+		values := int64set{}
+		node.collect(values)
+		if required == nil {
+			required = values
+		} else {
+			required = required.intersect(values)
+		}
+		if len(required) == 0 {
+			return nil
+		}
+	}
+	excluded := int64set{}
+	for _, v := range exclude {
+		excluded.add(v)
+	}
+	seen := int64set{}
+	var result []int64
+	nodes[0].findHelper(n, func(v int64) bool {
+		return !excluded.has(v) && (required == nil || required.has(v)) && seen.add(v)
+	}, &result)
+	return result
+}
+
+//documents records the keys each value is stored under
+type documents map[int64][]string
+
+//add records that the value is stored under the key
+func (d documents) add(value int64, key string) {
+	d[value] = append(d[value], key)
+}
+
+//remove records that the value is no longer stored under the key
+func (d documents) remove(value int64, key string) {
+	keys := d[value]
+	for i, k := range keys {
+		if k == key {
+			keys[i] = keys[len(keys)-1]
+			keys = keys[:len(keys)-1]
+			break
+		}
+	}
+	if len(keys) == 0 {
+		delete(d, value)
+	} else {
+		d[value] = keys
+	}
+}
+
+//ids returns every value that is stored under a key
+func (d documents) ids() []int64 {
+	ids := make([]int64, 0, len(d))
+	for id := range d {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+//keys returns a copy of the keys the value is stored under
+func (d documents) keys(value int64) []string {
+	return append([]string{}, d[value]...)
+}
+
+//analyzeFields returns the distinct keys the analyzer makes from the fields
+func analyzeFields(fields []string) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, field := range fields {
+		for _, key := range Analyze(field) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+//addKeyVal adds the key and value pairs
+func addKeyVal(index Index, result []string, id int64) {
+	for _, r := range result {
+		index.Add(r, id)
+	}
+}
+
+//removeKeyVal removes the key and value pairs
+func removeKeyVal(index Index, result []string, id int64) {
+	for _, r := range result {
+		index.Remove(r, id)
+	}
+}
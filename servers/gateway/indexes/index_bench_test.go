@@ -0,0 +1,73 @@
+package indexes
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+//benchmarkEntries are the keys of a large user base, which
+//share prefixes like real names do
+var benchmarkEntries = randomKeys(100000, 2)
+
+var benchmarkIndexes = []struct {
+	name     string
+	newIndex func() Index
+}{
+	{"Trie", func() Index { return NewTrie() }},
+	{"RadixTree", func() Index { return NewRadixTree() }},
+}
+
+//BenchmarkIndexMemory reports the heap used by an index of every benchmark entry
+func BenchmarkIndexMemory(b *testing.B) {
+	for _, bi := range benchmarkIndexes {
+		b.Run(bi.name, func(b *testing.B) {
+			var index Index
+			var before, after runtime.MemStats
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				index = bi.newIndex()
+				for _, e := range benchmarkEntries {
+					index.Add(e.Key, e.Val)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+			}
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc), "heap-bytes")
+			runtime.KeepAlive(index)
+		})
+	}
+}
+
+func BenchmarkIndexFind(b *testing.B) {
+	for _, bi := range benchmarkIndexes {
+		index := bi.newIndex()
+		for _, e := range benchmarkEntries {
+			index.Add(e.Key, e.Val)
+		}
+		for _, prefix := range []string{"a", "abc", "abcde"} {
+			b.Run(fmt.Sprintf("%s/%s", bi.name, prefix), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					index.Find(prefix, 20)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkIndexFindFuzzy(b *testing.B) {
+	for _, bi := range benchmarkIndexes {
+		index := bi.newIndex()
+		for _, e := range benchmarkEntries {
+			index.Add(e.Key, e.Val)
+		}
+		b.Run(bi.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				index.FindFuzzy("abcde", 1, 20, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package indexes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpsertDelete(t *testing.T) {
+	type step struct {
+		upsert []string
+		delete bool
+	}
+	cases := []struct {
+		name     string
+		steps    []step
+		queries  map[string][]int64
+		expected int
+	}{
+		{
+			"Insert",
+			[]step{{upsert: []string{"Competent", "Gopher", "gopher1"}}},
+			map[string][]int64{"comp": {1}, "goph": {1}},
+			3,
+		},
+		{
+			"Rename Removes Old Keys Including Username",
+			[]step{
+				{upsert: []string{"Competent", "Gopher", "gopher1"}},
+				{upsert: []string{"Mary", "Gopher", "mgopher"}},
+			},
+			map[string][]int64{"comp": nil, "gopher1": nil, "mary": {1}, "mgoph": {1}, "gopher": {1}},
+			3,
+		},
+		{
+			"Repeated Words Indexed Once",
+			[]step{{upsert: []string{"Ann Ann", "ANN", "ann"}}},
+			map[string][]int64{"ann": {1}},
+			1,
+		},
+		{
+			"Delete",
+			[]step{
+				{upsert: []string{"Competent", "Gopher", "gopher1"}},
+				{delete: true},
+			},
+			map[string][]int64{"comp": nil, "gopher": nil},
+			0,
+		},
+		{
+			"Delete Keys Added Directly",
+			[]step{
+				{upsert: []string{"Competent"}},
+				{upsert: []string{"Gopher"}},
+				{delete: true},
+			},
+			map[string][]int64{"comp": nil, "gopher": nil},
+			0,
+		},
+		{
+			"Delete Missing Document",
+			[]step{{delete: true}},
+			map[string][]int64{},
+			0,
+		},
+	}
+
+	for _, bi := range benchmarkIndexes {
+		for _, c := range cases {
+			index := bi.newIndex()
+			//another document shares keys, and must be left alone
+			index.Upsert(2, []string{"Other", "Gopherson"})
+			for _, s := range c.steps {
+				if s.delete {
+					index.Delete(1)
+				} else {
+					index.Upsert(1, s.upsert)
+				}
+			}
+			for query, expected := range c.queries {
+				if found := index.FindAll([]string{query}, 5, []int64{2}); !reflect.DeepEqual(found, expected) {
+					t.Errorf("%s case %s: expected %q to find %v but got %v", bi.name, c.name, query, expected, found)
+				}
+			}
+			if index.Len() != c.expected+2 {
+				t.Errorf("%s case %s: expected length %d but got %d", bi.name, c.name, c.expected+2, index.Len())
+			}
+			if found := index.Find("gophers", 5); !reflect.DeepEqual(found, []int64{2}) {
+				t.Errorf("%s case %s: expected the other document to be found, got %v", bi.name, c.name, found)
+			}
+		}
+	}
+}
+
+func TestDocumentsTrackKeys(t *testing.T) {
+	for _, bi := range benchmarkIndexes {
+		index := bi.newIndex()
+		index.Add("john", 1)
+		index.Add("smith", 1)
+		index.Remove("john", 1)
+		//the index forgets john, so replacing the document only has to remove smith
+		index.Upsert(1, []string{"Jane"})
+		if found := index.FindAll([]string{"smith"}, 5, nil); found != nil {
+			t.Errorf("%s: expected smith to be removed but found %v", bi.name, found)
+		}
+		if found := index.FindAll([]string{"jane"}, 5, nil); !reflect.DeepEqual(found, []int64{1}) {
+			t.Errorf("%s: expected jane to be found but got %v", bi.name, found)
+		}
+		if index.Len() != 1 {
+			t.Errorf("%s: expected length 1 but got %d", bi.name, index.Len())
+		}
+	}
+}
+
+func TestIDs(t *testing.T) {
+	for _, bi := range benchmarkIndexes {
+		index := bi.newIndex()
+		index.Upsert(1, []string{"Competent Gopher"})
+		index.Upsert(2, []string{"Blue Whale"})
+		index.Add("other", 3)
+		index.Delete(2)
+		ids := sortedIDs(index.IDs())
+		if !reflect.DeepEqual(ids, []int64{1, 3}) {
+			t.Errorf("%s: expected IDs [1 3] but got %v", bi.name, ids)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package indexes
 
+import "sort"
+
 type int64set map[int64]struct{}
 
 func (s int64set) add(value int64) bool {
@@ -19,6 +21,20 @@ func (s int64set) has(value int64) bool {
 	return exists
 }
 
+//intersect returns a new set of the values in both sets
+func (s int64set) intersect(other int64set) int64set {
+	if len(other) < len(s) {
+		s, other = other, s
+	}
+	ret := int64set{}
+	for k := range s {
+		if other.has(k) {
+			ret.add(k)
+		}
+	}
+	return ret
+}
+
 func (s int64set) all() []int64 {
 	ret := make([]int64, 0, len(s))
 	for k := range s {
@@ -28,3 +44,11 @@ func (s int64set) all() []int64 {
 	// sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
 	return ret
 }
+
+//sorted returns the values in ascending order, so values that share a key
+//are always found in the same order
+func (s int64set) sorted() []int64 {
+	ret := s.all()
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
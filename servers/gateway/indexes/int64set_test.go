@@ -166,3 +166,57 @@ func TestInt64SetAll(t *testing.T) {
 		}
 	}
 }
+
+func TestInt64SetIntersect(t *testing.T) {
+	cases := []struct {
+		name     string
+		a        []int64
+		b        []int64
+		expected []int64
+	}{
+		{
+			"Some Shared",
+			[]int64{1, 2, 3},
+			[]int64{2, 3, 4, 5},
+			[]int64{2, 3},
+		},
+		{
+			"None Shared",
+			[]int64{1, 2},
+			[]int64{3},
+			[]int64{},
+		},
+		{
+			"Same Values",
+			[]int64{1, 2},
+			[]int64{2, 1},
+			[]int64{1, 2},
+		},
+		{
+			"One Empty",
+			[]int64{},
+			[]int64{1},
+			[]int64{},
+		},
+	}
+
+	for _, c := range cases {
+		a := int64set{}
+		for _, v := range c.a {
+			a.add(v)
+		}
+		b := int64set{}
+		for _, v := range c.b {
+			b.add(v)
+		}
+
+		actual := a.intersect(b).all()
+		sort.Slice(actual, func(i, j int) bool { return actual[i] < actual[j] })
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("case %s: incorrect results: expected %v but got %v", c.name, c.expected, actual)
+		}
+		if len(a) != len(c.a) || len(b) != len(c.b) {
+			t.Errorf("case %s: intersect changed the sets", c.name)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package indexes
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//radixNode is a node of a radix tree. Chains of nodes with a single child
+//and no values are compressed into one node, so the edge into a node is
+//labelled with a whole run of characters rather than one.
+type radixNode struct {
+	//label is the part of the key on the edge from the parent to this node
+	label string
+	//children are sorted by the first rune of their labels, which are all different
+	children []*radixNode
+	values   int64set
+}
+
+//RadixTree is a path-compressed trie. It has the same operations as Trie,
+//but stores a node per branch instead of per character and keeps children
+//in a sorted slice instead of a map, so it uses much less memory.
+type RadixTree struct {
+	root   *radixNode
+	mx     sync.RWMutex
+	length int
+	docs   documents
+}
+
+//NewRadixTree constructs a new RadixTree.
+func NewRadixTree() *RadixTree {
+	return &RadixTree{root: &radixNode{}, docs: documents{}}
+}
+
+//Len returns the number of entries in the tree.
+func (t *RadixTree) Len() int {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	return t.length
+}
+
+//Add adds a key and value to the tree.
+func (t *RadixTree) Add(key string, value int64) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.add(key, value)
+}
+
+//add adds a key and value to the tree while the tree is locked
+func (t *RadixTree) add(key string, value int64) {
+	currNode := t.root
+	fullKey := key
+	for len(key) > 0 {
+		i, child := currNode.child(key)
+		if child == nil {
+			child = &radixNode{label: key}
+			currNode.insertChild(i, child)
+			currNode = child
+			break
+		}
+		common := commonPrefixLen(key, child.label)
+		if common < len(child.label) {
+			//split the edge where the key leaves it
+			split := &radixNode{label: child.label[:common], children: []*radixNode{child}}
+			child.label = child.label[common:]
+			currNode.children[i] = split
+			child = split
+		}
+		currNode = child
+		key = key[common:]
+	}
+	if currNode.values == nil {
+		currNode.values = int64set{}
+	}
+	if currNode.values.add(value) {
+		t.docs.add(value, fullKey)
+		t.length++
+	}
+}
+
+//Remove removes a key/value pair from the tree
+//and merges or trims nodes left without values.
+func (t *RadixTree) Remove(key string, value int64) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.remove(key, value)
+}
+
+//remove removes a key/value pair while the tree is locked
+func (t *RadixTree) remove(key string, value int64) {
+	fullKey := key
+	path := []*radixNode{t.root}
+	currNode := t.root
+	for len(key) > 0 {
+		_, child := currNode.child(key)
+		if child == nil || commonPrefixLen(key, child.label) < len(child.label) {
+			return
+		}
+		currNode = child
+		key = key[len(child.label):]
+		path = append(path, currNode)
+	}
+	if !currNode.values.remove(value) {
+		return
+	}
+	t.docs.remove(value, fullKey)
+	t.length--
+	//tidy up from the parent of the removed node towards the root
+	for i := len(path) - 2; i >= 0; i-- {
+		path[i].compact()
+	}
+}
+
+//Upsert replaces the keys of the document with the value `id` with
+//the keys the analyzer makes from its fields, in one step so that
+//searches never see a mix of its old and new keys
+func (t *RadixTree) Upsert(id int64, fields []string) {
+	keys := analyzeFields(fields)
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, key := range t.docs.keys(id) {
+		t.remove(key, id)
+	}
+	for _, key := range keys {
+		t.add(key, id)
+	}
+}
+
+//Delete removes every key of the document with the value `id`
+func (t *RadixTree) Delete(id int64) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, key := range t.docs.keys(id) {
+		t.remove(key, id)
+	}
+}
+
+//Find finds `n` values matching `prefix`. If the tree
+//is entirely empty, or the prefix is empty, or n == 0,
+//or the prefix is not found, this returns a nil slice.
+func (t *RadixTree) Find(prefix string, n int) []int64 {
+	return t.FindExcluding(prefix, n, nil)
+}
+
+//FindExcluding finds `n` values matching `prefix` like Find,
+//but skips any of the values in `exclude`.
+func (t *RadixTree) FindExcluding(prefix string, n int, exclude []int64) []int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	if t.length == 0 || len(prefix) == 0 || n <= 0 {
+		return nil
+	}
+	currNode := t.root.find(prefix)
+	if currNode == nil {
+		return nil
+	}
+	return findExcluding(currNode, n, exclude)
+}
+
+//FindAll finds `n` distinct values that have keys matching every
+//one of the `prefixes`, skipping any of the values in `exclude`.
+func (t *RadixTree) FindAll(prefixes []string, n int, exclude []int64) []int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	if t.length == 0 {
+		return nil
+	}
+	return findAll(prefixes, n, exclude, func(prefix string) prefixNode {
+		if node := t.root.find(prefix); node != nil {
+			return node
+		}
+		return nil
+	})
+}
+
+//FindFuzzy finds up to `n` values whose keys start with a prefix within
+//`maxEdits` edits of `query` like Trie.FindFuzzy.
+func (t *RadixTree) FindFuzzy(query string, maxEdits int, n int, exclude []int64) []int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	search := newFuzzySearch(query, maxEdits, n, exclude)
+	if t.length == 0 || search == nil {
+		return nil
+	}
+	start := search.start()
+	for _, child := range t.root.children {
+		search.visitRadix(child, start)
+	}
+	return search.results(n)
+}
+
+//visitRadix moves the search on through each rune of the node's label,
+//then collects its values and visits its children like visit
+func (s *fuzzySearch) visitRadix(node *radixNode, pos *fuzzyPosition) {
+	for _, k := range node.label {
+		if s.done(pos) {
+			return
+		}
+		pos = s.next(pos, k)
+	}
+	s.addAll(node.values, pos)
+	if s.done(pos) {
+		return
+	}
+	for _, child := range node.children {
+		s.visitRadix(child, pos)
+	}
+}
+
+//IDs returns every value stored in the tree
+func (t *RadixTree) IDs() []int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	return t.docs.ids()
+}
+
+//AddConvertedUsers adds the keys the analyzer makes from the user's
+//first name, last name and username to the tree
+func (t *RadixTree) AddConvertedUsers(firstName string, lastName string, userName string, id int64) {
+	addKeyVal(t, Analyze(firstName), id)
+	addKeyVal(t, Analyze(lastName), id)
+	addKeyVal(t, Analyze(userName), id)
+}
+
+//RemoveConvertedUsers removes the keys the analyzer makes from
+//the user's first and last name from the tree
+func (t *RadixTree) RemoveConvertedUsers(firstName string, lastName string, id int64) {
+	removeKeyVal(t, Analyze(firstName), id)
+	removeKeyVal(t, Analyze(lastName), id)
+}
+
+//Save writes a gzipped snapshot of the tree to w, recording `takenAt`
+//as the time the tree was up to date with its source.
+func (t *RadixTree) Save(w io.Writer, takenAt time.Time) error {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	return writeSnapshot(w, takenAt, t.root.countKeys(), func(sw *snapshotWriter) {
+		t.root.save(sw, "")
+	})
+}
+
+//child returns the child whose label starts with the same rune as the key,
+//or nil and the index it would be inserted at
+func (currNode *radixNode) child(key string) (int, *radixNode) {
+	r, _ := utf8.DecodeRuneInString(key)
+	i := sort.Search(len(currNode.children), func(i int) bool {
+		first, _ := utf8.DecodeRuneInString(currNode.children[i].label)
+		return first >= r
+	})
+	if i < len(currNode.children) {
+		if first, _ := utf8.DecodeRuneInString(currNode.children[i].label); first == r {
+			return i, currNode.children[i]
+		}
+	}
+	return i, nil
+}
+
+//insertChild inserts the child at index i, keeping the children sorted
+func (currNode *radixNode) insertChild(i int, child *radixNode) {
+	currNode.children = append(currNode.children, nil)
+	copy(currNode.children[i+1:], currNode.children[i:])
+	currNode.children[i] = child
+}
+
+//find returns the node holding every key that starts with the prefix,
+//or nil if no key does. The prefix may end part way through the node's label.
+func (currNode *radixNode) find(prefix string) *radixNode {
+	for len(prefix) > 0 {
+		_, child := currNode.child(prefix)
+		if child == nil {
+			return nil
+		}
+		common := commonPrefixLen(prefix, child.label)
+		switch {
+		case common == len(prefix):
+			return child
+		case common < len(child.label):
+			return nil
+		}
+		currNode = child
+		prefix = prefix[common:]
+	}
+	return currNode
+}
+
+//findHelper is a helper method for Find which does the depth first search,
+//adding the values that `keep` returns true for.
+func (currNode *radixNode) findHelper(n int, keep func(int64) bool, result *[]int64) {
+	for _, v := range currNode.values.sorted() {
+		if len(*result) >= n {
+			return
+		}
+		if keep(v) {
+			*result = append(*result, v)
+		}
+	}
+	for _, child := range currNode.children {
+		if len(*result) >= n {
+			return
+		}
+		child.findHelper(n, keep, result)
+	}
+}
+
+//collect adds the values of the node and all its descendants to the set
+func (currNode *radixNode) collect(values int64set) {
+	for v := range currNode.values {
+		values.add(v)
+	}
+	for _, child := range currNode.children {
+		child.collect(values)
+	}
+}
+
+//compact removes children with no values or children, and merges
+//children with no values and a single child into that child
+func (currNode *radixNode) compact() {
+	children := currNode.children[:0]
+	for _, child := range currNode.children {
+		if len(child.values) > 0 || len(child.children) > 1 {
+			children = append(children, child)
+			continue
+		}
+		if len(child.children) == 1 {
+			grandchild := child.children[0]
+			grandchild.label = child.label + grandchild.label
+			children = append(children, grandchild)
+		}
+	}
+	for i := len(children); i < len(currNode.children); i++ {
+		currNode.children[i] = nil
+	}
+	currNode.children = children
+}
+
+//countKeys returns the number of keys with values in the node and its descendants
+func (currNode *radixNode) countKeys() int {
+	count := 0
+	if len(currNode.values) > 0 {
+		count++
+	}
+	for _, child := range currNode.children {
+		count += child.countKeys()
+	}
+	return count
+}
+
+//save writes the keys with values in the node and its descendants
+func (currNode *radixNode) save(sw *snapshotWriter, key string) {
+	key += currNode.label
+	if len(currNode.values) > 0 {
+		sw.writeKey(key, currNode.values)
+	}
+	for _, child := range currNode.children {
+		child.save(sw, key)
+	}
+}
+
+//commonPrefixLen returns the length in bytes of the longest
+//prefix of whole runes that the strings share
+func commonPrefixLen(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) {
+		ra, size := utf8.DecodeRuneInString(a[i:])
+		rb, _ := utf8.DecodeRuneInString(b[i:])
+		if ra != rb {
+			break
+		}
+		i += size
+	}
+	return i
+}
@@ -0,0 +1,226 @@
+package indexes
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func sortedIDs(ids []int64) []int64 {
+	sorted := append([]int64{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+func TestRadixTreeAddFind(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []TestKeyVal
+		prefix   string
+		n        int
+		expected []int64
+	}{
+		{
+			"Split Edge",
+			[]TestKeyVal{{"john", 1}, {"joan", 2}},
+			"jo",
+			5,
+			[]int64{1, 2},
+		},
+		{
+			"Prefix Ends Inside Label",
+			[]TestKeyVal{{"jonathan", 3}},
+			"jonat",
+			5,
+			[]int64{3},
+		},
+		{
+			"Key Is Prefix Of Another",
+			[]TestKeyVal{{"johnny", 2}, {"john", 1}},
+			"john",
+			5,
+			[]int64{1, 2},
+		},
+		{
+			"Prefix Leaves Label",
+			[]TestKeyVal{{"john", 1}},
+			"jox",
+			5,
+			nil,
+		},
+		{
+			"Prefix Longer Than Key",
+			[]TestKeyVal{{"jo", 1}},
+			"john",
+			5,
+			nil,
+		},
+		{
+			"Multibyte Runes",
+			[]TestKeyVal{{"世界", 1}, {"世纪", 2}, {"ガイド", 3}},
+			"世",
+			5,
+			[]int64{1, 2},
+		},
+		{
+			"Empty Prefix",
+			[]TestKeyVal{{"c", 3}, {"a", 1}, {"b", 2}},
+			"",
+			2,
+			nil,
+		},
+		{
+			"Limited",
+			[]TestKeyVal{{"ac", 3}, {"aa", 1}, {"ab", 2}},
+			"a",
+			2,
+			[]int64{1, 2},
+		},
+	}
+
+	for _, c := range cases {
+		tree := NewRadixTree()
+		for _, v := range c.input {
+			tree.Add(v.Key, v.Val)
+		}
+		if tree.Len() != len(c.input) {
+			t.Errorf("case %s: expected length %d but got %d", c.name, len(c.input), tree.Len())
+		}
+		result := tree.Find(c.prefix, c.n)
+		if result != nil {
+			//values under the same key are found in any order
+			result = sortedIDs(result)
+		}
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, result)
+		}
+	}
+}
+
+func TestRadixTreeRemove(t *testing.T) {
+	tree := NewRadixTree()
+	tree.Add("john", 1)
+	tree.Add("joan", 2)
+	tree.Add("johnny", 3)
+	tree.Add("john", 4)
+
+	tree.Remove("john", 1)
+	tree.Remove("john", 5)
+	tree.Remove("jo", 2)
+	if tree.Len() != 3 {
+		t.Errorf("expected length 3 but got %d", tree.Len())
+	}
+	if found := sortedIDs(tree.Find("john", 5)); !reflect.DeepEqual(found, []int64{3, 4}) {
+		t.Errorf("expected [3 4] but got %v", found)
+	}
+
+	tree.Remove("john", 4)
+	tree.Remove("joan", 2)
+	//only johnny is left, so the tree should have merged back into one edge
+	if len(tree.root.children) != 1 || tree.root.children[0].label != "johnny" {
+		t.Errorf("expected a single johnny edge but got %d children", len(tree.root.children))
+	}
+	if found := tree.Find("jo", 5); !reflect.DeepEqual(found, []int64{3}) {
+		t.Errorf("expected [3] but got %v", found)
+	}
+
+	tree.Delete(3)
+	if tree.Len() != 0 || len(tree.root.children) != 0 {
+		t.Errorf("expected an empty tree but got length %d with %d children", tree.Len(), len(tree.root.children))
+	}
+}
+
+//randomKeys returns `count` random entries with keys made from a small
+//alphabet, so that they share plenty of prefixes
+func randomKeys(count int, seed int64) []TestKeyVal {
+	random := rand.New(rand.NewSource(seed))
+	alphabet := []rune("abcdeéö世界")
+	entries := make([]TestKeyVal, count)
+	for i := range entries {
+		key := make([]rune, 1+random.Intn(8))
+		for j := range key {
+			key[j] = alphabet[random.Intn(len(alphabet))]
+		}
+		entries[i] = TestKeyVal{string(key), int64(random.Intn(count / 2))}
+	}
+	return entries
+}
+
+func TestRadixTreeMatchesTrie(t *testing.T) {
+	entries := randomKeys(2000, 1)
+	trie := NewTrie()
+	tree := NewRadixTree()
+	for _, e := range entries {
+		trie.Add(e.Key, e.Val)
+		tree.Add(e.Key, e.Val)
+	}
+	//remove a third of the entries, and every key of a few values
+	for _, e := range entries[:len(entries)/3] {
+		trie.Remove(e.Key, e.Val)
+		tree.Remove(e.Key, e.Val)
+	}
+	for v := int64(0); v < 20; v++ {
+		trie.Delete(v)
+		tree.Delete(v)
+	}
+	if trie.Len() != tree.Len() {
+		t.Errorf("expected length %d but got %d", trie.Len(), tree.Len())
+	}
+
+	exclude := []int64{25, 30, 35}
+	for _, prefix := range []string{"a", "ab", "abc", "é", "世界", "ö世", "eeee", "x"} {
+		expected := sortedIDs(trie.FindExcluding(prefix, 1000, exclude))
+		found := sortedIDs(tree.FindExcluding(prefix, 1000, exclude))
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("prefix %q: expected %v but got %v", prefix, expected, found)
+		}
+	}
+	for _, prefixes := range [][]string{{"a", "b"}, {"ab", "世"}, {"é", "ö", "c"}} {
+		expected := sortedIDs(trie.FindAll(prefixes, 1000, exclude))
+		found := sortedIDs(tree.FindAll(prefixes, 1000, exclude))
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("prefixes %q: expected %v but got %v", prefixes, expected, found)
+		}
+	}
+	for _, query := range []string{"abcd", "世界a", "edcba"} {
+		expected := trie.FindFuzzy(query, 2, 50, exclude)
+		found := tree.FindFuzzy(query, 2, 50, exclude)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("fuzzy query %q: expected %v but got %v", query, expected, found)
+		}
+	}
+
+	//snapshots of either load into the other
+	buf := &bytes.Buffer{}
+	if err := tree.Save(buf, time.Now()); err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+	loaded := NewTrie()
+	if _, err := Load(buf, loaded); err != nil {
+		t.Fatalf("unexpected error loading: %v", err)
+	}
+	if loaded.Len() != trie.Len() {
+		t.Errorf("expected loaded length %d but got %d", trie.Len(), loaded.Len())
+	}
+	if expected, found := sortedIDs(trie.Find("a", 1000)), sortedIDs(loaded.Find("a", 1000)); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected loaded snapshot to find %v but got %v", expected, found)
+	}
+}
+
+func TestRadixTreeConvertedUsers(t *testing.T) {
+	tree := NewRadixTree()
+	tree.AddConvertedUsers("José", "Núñez", "jnunez", 1)
+	tree.AddConvertedUsers("Joan", "Smith", "jsmith", 2)
+
+	if found := tree.FindAll([]string{"jose", "nu"}, 5, nil); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("expected [1] but got %v", found)
+	}
+	tree.RemoveConvertedUsers("José", "Núñez", 1)
+	tree.Remove("jnunez", 1)
+	if found := tree.FindAll([]string{"j"}, 5, nil); !reflect.DeepEqual(found, []int64{2}) {
+		t.Errorf("expected [2] after removing user 1 but got %v", found)
+	}
+}
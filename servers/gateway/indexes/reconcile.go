@@ -0,0 +1,32 @@
+package indexes
+
+//reconciler is an Index that records which values are upserted into it
+type reconciler struct {
+	Index
+	seen map[int64]bool
+}
+
+//Upsert upserts the document into the index and records that it was seen
+func (r *reconciler) Upsert(id int64, fields []string) {
+	r.seen[id] = true
+	r.Index.Upsert(id, fields)
+}
+
+//Reconcile makes the index match its source, which `load` upserts every
+//current document from, and then removes every document it didn't upsert.
+//This repairs anything lost updates left behind.
+func Reconcile(index Index, load func(index Index) error) error {
+	//only values indexed before loading can be stale, anything indexed
+	//since then was added by a request or event that is newer
+	indexed := index.IDs()
+	r := &reconciler{Index: index, seen: map[int64]bool{}}
+	if err := load(r); err != nil {
+		return err
+	}
+	for _, id := range indexed {
+		if !r.seen[id] {
+			index.Delete(id)
+		}
+	}
+	return nil
+}
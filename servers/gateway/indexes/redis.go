@@ -0,0 +1,427 @@
+package indexes
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//redisPageSize is how many keys are read from redis at a time
+const redisPageSize = 256
+
+//maxFuzzyPages bounds how many pages of keys a fuzzy search reads from redis
+const maxFuzzyPages = 64
+
+//RedisIndex is an Index stored in redis, so that every gateway shares one
+//index and nothing has to be loaded at startup. Every key is kept in a
+//sorted set with the same score, so the keys starting with a prefix are a
+//lexicographic range. Each key has a set of the values stored under it and
+//each value has a set of the keys it is stored under, like documents.
+//
+//Redis errors are logged, and searches return whatever was found before the error.
+type RedisIndex struct {
+	//Redis client used to talk to redis server.
+	Client *redis.Client
+	//Prefix is put before the name of every redis key the index uses
+	Prefix string
+}
+
+//NewRedisIndex constructs a new RedisIndex storing its keys under `prefix`
+func NewRedisIndex(client *redis.Client, prefix string) *RedisIndex {
+	return &RedisIndex{
+		Client: client,
+		Prefix: prefix,
+	}
+}
+
+//redisIndexScript changes the keys of one value. Running it as a script
+//makes each change atomic, so searches never see a value half added or
+//half replaced, and concurrent gateways can't lose each other's changes.
+//ARGV is the prefix, the operation, the value and then the keys.
+var redisIndexScript = redis.NewScript(`
+local prefix, op, id = ARGV[1], ARGV[2], ARGV[3]
+local docKey = prefix .. ":doc:" .. id
+
+local function add(key)
+	if redis.call("SADD", prefix .. ":key:" .. key, id) == 1 then
+		redis.call("ZADD", prefix .. ":keys", 0, key)
+		redis.call("SADD", docKey, key)
+		redis.call("SADD", prefix .. ":ids", id)
+		redis.call("INCR", prefix .. ":len")
+	end
+end
+
+local function remove(key)
+	if redis.call("SREM", prefix .. ":key:" .. key, id) == 1 then
+		if redis.call("SCARD", prefix .. ":key:" .. key) == 0 then
+			redis.call("ZREM", prefix .. ":keys", key)
+		end
+		redis.call("SREM", docKey, key)
+		if redis.call("SCARD", docKey) == 0 then
+			redis.call("SREM", prefix .. ":ids", id)
+		end
+		redis.call("DECR", prefix .. ":len")
+	end
+end
+
+if op == "upsert" or op == "delete" then
+	for _, key in ipairs(redis.call("SMEMBERS", docKey)) do
+		remove(key)
+	end
+end
+for i = 4, #ARGV do
+	if op == "remove" then
+		remove(ARGV[i])
+	else
+		add(ARGV[i])
+	end
+end
+return #ARGV - 3
+`)
+
+//change runs the index script for the operation on the value and keys
+func (ri *RedisIndex) change(op string, value int64, keys []string) {
+	args := make([]interface{}, 0, len(keys)+3)
+	args = append(args, ri.Prefix, op, strconv.FormatInt(value, 10))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	if err := redisIndexScript.Run(ri.Client, nil, args...).Err(); err != nil {
+		log.Printf("error running %s for value %d on search index in redis: %v", op, value, err)
+	}
+}
+
+//Len returns the number of entries in the index.
+func (ri *RedisIndex) Len() int {
+	length, err := ri.Client.Get(ri.Prefix + ":len").Int64()
+	if err != nil && err != redis.Nil {
+		log.Printf("error getting search index length from redis: %v", err)
+	}
+	return int(length)
+}
+
+//Add adds a key and value to the index.
+func (ri *RedisIndex) Add(key string, value int64) {
+	ri.change("add", value, []string{key})
+}
+
+//Remove removes a key/value pair from the index.
+func (ri *RedisIndex) Remove(key string, value int64) {
+	ri.change("remove", value, []string{key})
+}
+
+//Upsert replaces the keys of the document with the value `id` with
+//the keys the analyzer makes from its fields, in one step so that
+//searches never see a mix of its old and new keys
+func (ri *RedisIndex) Upsert(id int64, fields []string) {
+	ri.change("upsert", id, analyzeFields(fields))
+}
+
+//Delete removes every key of the document with the value `id`
+func (ri *RedisIndex) Delete(id int64) {
+	ri.change("delete", id, nil)
+}
+
+//IDs returns every value stored in the index
+func (ri *RedisIndex) IDs() []int64 {
+	members, err := ri.Client.SMembers(ri.Prefix + ":ids").Result()
+	if err != nil {
+		log.Printf("error getting search index values from redis: %v", err)
+		return nil
+	}
+	ids, err := parseValues(members)
+	if err != nil {
+		log.Printf("error reading search index values from redis: %v", err)
+	}
+	return ids
+}
+
+//Find finds `n` values matching `prefix`. If the prefix is empty,
+//or n == 0, or the prefix is not found, this returns a nil slice.
+func (ri *RedisIndex) Find(prefix string, n int) []int64 {
+	return ri.FindExcluding(prefix, n, nil)
+}
+
+//FindExcluding finds `n` values matching `prefix` like Find,
+//but skips any of the values in `exclude`.
+func (ri *RedisIndex) FindExcluding(prefix string, n int, exclude []int64) []int64 {
+	if len(prefix) == 0 || n <= 0 {
+		return nil
+	}
+	return findExcluding(&redisPrefix{index: ri, prefix: prefix}, n, exclude)
+}
+
+//FindAll finds `n` distinct values that have keys matching every
+//one of the `prefixes`, skipping any of the values in `exclude`.
+func (ri *RedisIndex) FindAll(prefixes []string, n int, exclude []int64) []int64 {
+	return findAll(prefixes, n, exclude, func(prefix string) prefixNode {
+		keys, err := ri.keyRange(prefixMin(prefix), prefixMax(prefix), 1)
+		if err != nil {
+			log.Printf("error finding %q in search index in redis: %v", prefix, err)
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		return &redisPrefix{index: ri, prefix: prefix}
+	})
+}
+
+//FindFuzzy finds up to `n` values whose keys start with a prefix within
+//`maxEdits` edits of `query` like Trie.FindFuzzy. The keys are walked in
+//order, sharing the work for the runes each key has in common with the
+//last, and skipping every key under a prefix that can no longer match.
+//
+//Any key can be within reach of a typo in its first runes, so the walk starts
+//at the first key rather than at a prefix of the query. Each page read costs a
+//ZRANGEBYLEX and a pipeline of SMEMBERS, and a search reads at most
+//maxFuzzyPages pages, so matches beyond the first maxFuzzyPages*redisPageSize
+//keys walked are missed, as the tree indexes miss them past maxFuzzyCandidates.
+func (ri *RedisIndex) FindFuzzy(query string, maxEdits int, n int, exclude []int64) []int64 {
+	search := newFuzzySearch(query, maxEdits, n, exclude)
+	if search == nil {
+		return nil
+	}
+	//path holds the position after each rune of the last key walked
+	path := []*fuzzyPosition{search.start()}
+	min := "-"
+	for page := 0; page < maxFuzzyPages && len(search.matches) < maxFuzzyCandidates; page++ {
+		keys, err := ri.keyRange(min, "+", redisPageSize)
+		if err != nil {
+			log.Printf("error fuzzy finding %q in search index in redis: %v", query, err)
+			break
+		}
+		if len(keys) == 0 {
+			break
+		}
+		min = "(" + keys[len(keys)-1]
+
+		var matched []string
+		var positions []*fuzzyPosition
+		pruned := ""
+		for _, key := range keys {
+			if len(pruned) > 0 && strings.HasPrefix(key, pruned) {
+				continue
+			}
+			pruned = ""
+			runes := []rune(key)
+			last := path[len(path)-1].key
+			common := 0
+			for common < len(runes) && common < len(last) && runes[common] == last[common] {
+				common++
+			}
+			path = path[:common+1]
+			pos := path[common]
+			dead := false
+			for i := common; i < len(runes) && !dead; i++ {
+				if dead = search.done(pos); dead {
+					pruned = string(runes[:i])
+				} else {
+					pos = search.next(pos, runes[i])
+					path = append(path, pos)
+				}
+			}
+			if dead {
+				continue
+			}
+			if pos.best <= search.maxEdits {
+				matched = append(matched, key)
+				positions = append(positions, pos)
+			}
+			if search.done(pos) {
+				pruned = key
+			}
+		}
+		if len(pruned) > 0 {
+			//skip every remaining key under the pruned prefix
+			min = "(" + pruned + "\xff"
+		}
+
+		values, err := ri.values(matched)
+		if err != nil {
+			log.Printf("error fuzzy finding %q in search index in redis: %v", query, err)
+			break
+		}
+		for i, key := range matched {
+			//the position's runes may have been reused by a later key, so use the key itself
+			for _, v := range values[i] {
+				search.add(v, key, positions[i].best, positions[i].prefixLen)
+			}
+		}
+		if len(keys) < redisPageSize {
+			break
+		}
+	}
+	return search.results(n)
+}
+
+//AddConvertedUsers adds the keys the analyzer makes from the user's
+//first name, last name and username to the index
+func (ri *RedisIndex) AddConvertedUsers(firstName string, lastName string, userName string, id int64) {
+	addKeyVal(ri, Analyze(firstName), id)
+	addKeyVal(ri, Analyze(lastName), id)
+	addKeyVal(ri, Analyze(userName), id)
+}
+
+//RemoveConvertedUsers removes the keys the analyzer makes from
+//the user's first and last name from the index
+func (ri *RedisIndex) RemoveConvertedUsers(firstName string, lastName string, id int64) {
+	removeKeyVal(ri, Analyze(firstName), id)
+	removeKeyVal(ri, Analyze(lastName), id)
+}
+
+//Save writes a gzipped snapshot of the index to w, recording `takenAt`
+//as the time the index was up to date with its source. The keys are read
+//a page at a time, so changes made while saving may be partly included.
+func (ri *RedisIndex) Save(w io.Writer, takenAt time.Time) error {
+	var keys []string
+	var values []int64set
+	err := ri.scan("", func(key string, vals []int64) bool {
+		set := int64set{}
+		for _, v := range vals {
+			set.add(v)
+		}
+		keys = append(keys, key)
+		values = append(values, set)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("reading search index from redis: %v", err)
+	}
+	return writeSnapshot(w, takenAt, len(keys), func(sw *snapshotWriter) {
+		for i, key := range keys {
+			sw.writeKey(key, values[i])
+		}
+	})
+}
+
+//keyRange returns up to `count` keys in the lexicographic range from min to max
+func (ri *RedisIndex) keyRange(min string, max string, count int64) ([]string, error) {
+	return ri.Client.ZRangeByLex(ri.Prefix+":keys", redis.ZRangeBy{
+		Min:   min,
+		Max:   max,
+		Count: count,
+	}).Result()
+}
+
+//values returns the sorted values stored under each of the keys
+func (ri *RedisIndex) values(keys []string) ([][]int64, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := ri.Client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.SMembers(ri.Prefix + ":key:" + key)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+	values := make([][]int64, len(keys))
+	for i, cmd := range cmds {
+		vals, err := parseValues(cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(vals, func(a, b int) bool { return vals[a] < vals[b] })
+		values[i] = vals
+	}
+	return values, nil
+}
+
+//scan calls fn with each key starting with the prefix and its values, in
+//key order, until fn returns false. The empty prefix scans every key.
+func (ri *RedisIndex) scan(prefix string, fn func(key string, values []int64) bool) error {
+	min, max := prefixMin(prefix), prefixMax(prefix)
+	for {
+		keys, err := ri.keyRange(min, max, redisPageSize)
+		if err != nil || len(keys) == 0 {
+			return err
+		}
+		values, err := ri.values(keys)
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			if !fn(key, values[i]) {
+				return nil
+			}
+		}
+		if len(keys) < redisPageSize {
+			return nil
+		}
+		min = "(" + keys[len(keys)-1]
+	}
+}
+
+//prefixMin is the start of the lexicographic range of keys starting with the prefix
+func prefixMin(prefix string) string {
+	if len(prefix) == 0 {
+		return "-"
+	}
+	return "[" + prefix
+}
+
+//prefixMax is the end of the lexicographic range of keys starting with the prefix.
+//No UTF-8 text contains the byte 0xff, so every such key sorts before prefix+"\xff".
+func prefixMax(prefix string) string {
+	if len(prefix) == 0 {
+		return "+"
+	}
+	return "(" + prefix + "\xff"
+}
+
+//parseValues parses the values stored in a redis set
+func parseValues(members []string) ([]int64, error) {
+	values := make([]int64, 0, len(members))
+	for _, member := range members {
+		v, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return values, fmt.Errorf("invalid search index value %q: %v", member, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+//redisPrefix is the prefixNode of every key in a RedisIndex starting with the prefix
+type redisPrefix struct {
+	index  *RedisIndex
+	prefix string
+}
+
+//findHelper adds up to `n` values that `keep` returns true for to the result, in key order
+func (p *redisPrefix) findHelper(n int, keep func(int64) bool, result *[]int64) {
+	err := p.index.scan(p.prefix, func(key string, values []int64) bool {
+		for _, v := range values {
+			if len(*result) >= n {
+				return false
+			}
+			if keep(v) {
+				*result = append(*result, v)
+			}
+		}
+		return len(*result) < n
+	})
+	if err != nil {
+		log.Printf("error finding %q in search index in redis: %v", p.prefix, err)
+	}
+}
+
+//collect adds every value under the prefix to the set
+func (p *redisPrefix) collect(values int64set) {
+	err := p.index.scan(p.prefix, func(key string, vals []int64) bool {
+		for _, v := range vals {
+			values.add(v)
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf("error finding %q in search index in redis: %v", p.prefix, err)
+	}
+}
@@ -0,0 +1,213 @@
+package indexes
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+//newTestRedis starts an in-process redis server, which the caller must close
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("error starting test redis: %v", err)
+	}
+	return server, redis.NewClient(&redis.Options{Addr: server.Addr()})
+}
+
+func TestRedisIndexAddRemove(t *testing.T) {
+	server, client := newTestRedis(t)
+	defer server.Close()
+	index := NewRedisIndex(client, "search")
+
+	index.Add("john", 1)
+	index.Add("joan", 2)
+	index.Add("johnny", 3)
+	index.Add("john", 4)
+	index.Add("john", 4)
+	if index.Len() != 4 {
+		t.Errorf("expected length 4 but got %d", index.Len())
+	}
+	if found := index.Find("john", 5); !reflect.DeepEqual(found, []int64{1, 4, 3}) {
+		t.Errorf("expected [1 4 3] in key order but got %v", found)
+	}
+	if found := index.Find("jo", 2); !reflect.DeepEqual(found, []int64{2, 1}) {
+		t.Errorf("expected [2 1] but got %v", found)
+	}
+
+	index.Remove("john", 1)
+	index.Remove("john", 5)
+	index.Remove("jo", 2)
+	if index.Len() != 3 {
+		t.Errorf("expected length 3 but got %d", index.Len())
+	}
+	index.Remove("john", 4)
+	if found := index.Find("john", 5); !reflect.DeepEqual(found, []int64{3}) {
+		t.Errorf("expected [3] but got %v", found)
+	}
+	//keys without values are removed from the sorted set of keys
+	if keys, _ := client.ZRange("search:keys", 0, -1).Result(); !reflect.DeepEqual(keys, []string{"joan", "johnny"}) {
+		t.Errorf("expected keys [joan johnny] but got %v", keys)
+	}
+
+	index.Delete(2)
+	index.Delete(3)
+	if index.Len() != 0 || index.Find("j", 5) != nil || len(index.IDs()) != 0 {
+		t.Errorf("expected an empty index but got length %d", index.Len())
+	}
+	if keys := server.Keys(); len(keys) != 1 || keys[0] != "search:len" {
+		t.Errorf("expected only the length to be left in redis but got %v", keys)
+	}
+}
+
+func TestRedisIndexMatchesRadixTree(t *testing.T) {
+	server, client := newTestRedis(t)
+	defer server.Close()
+
+	entries := randomKeys(2000, 3)
+	tree := NewRadixTree()
+	index := NewRedisIndex(client, "search")
+	for _, e := range entries {
+		tree.Add(e.Key, e.Val)
+		index.Add(e.Key, e.Val)
+	}
+	for _, e := range entries[:len(entries)/3] {
+		tree.Remove(e.Key, e.Val)
+		index.Remove(e.Key, e.Val)
+	}
+	for v := int64(0); v < 20; v++ {
+		tree.Delete(v)
+		index.Delete(v)
+	}
+	if tree.Len() != index.Len() {
+		t.Errorf("expected length %d but got %d", tree.Len(), index.Len())
+	}
+	if expected, found := sortedIDs(tree.IDs()), sortedIDs(index.IDs()); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected IDs %v but got %v", expected, found)
+	}
+
+	exclude := []int64{25, 30, 35}
+	for _, prefix := range []string{"a", "ab", "abc", "é", "世界", "ö世", "eeee", "x"} {
+		expected := sortedIDs(tree.FindExcluding(prefix, 1000, exclude))
+		found := sortedIDs(index.FindExcluding(prefix, 1000, exclude))
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("prefix %q: expected %v but got %v", prefix, expected, found)
+		}
+	}
+	for _, prefixes := range [][]string{{"a", "b"}, {"ab", "世"}, {"é", "ö", "c"}, {"a", "x"}} {
+		expected := sortedIDs(tree.FindAll(prefixes, 1000, exclude))
+		found := sortedIDs(index.FindAll(prefixes, 1000, exclude))
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("prefixes %q: expected %v but got %v", prefixes, expected, found)
+		}
+	}
+	for _, query := range []string{"abcd", "世界a", "edcba", "ééé"} {
+		expected := tree.FindFuzzy(query, 2, 50, exclude)
+		found := index.FindFuzzy(query, 2, 50, exclude)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("fuzzy query %q: expected %v but got %v", query, expected, found)
+		}
+	}
+
+	//snapshots of the redis index load into an in-process index
+	buf := &bytes.Buffer{}
+	if err := index.Save(buf, time.Now()); err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+	loaded := NewTrie()
+	if _, err := Load(buf, loaded); err != nil {
+		t.Fatalf("unexpected error loading: %v", err)
+	}
+	if loaded.Len() != tree.Len() {
+		t.Errorf("expected loaded length %d but got %d", tree.Len(), loaded.Len())
+	}
+}
+
+func TestRedisIndexShared(t *testing.T) {
+	server, client := newTestRedis(t)
+	defer server.Close()
+
+	//two gateways sharing the index see each other's changes
+	gateway1 := NewRedisIndex(client, "search")
+	gateway2 := NewRedisIndex(redis.NewClient(&redis.Options{Addr: server.Addr()}), "search")
+	//an index under another prefix is kept apart
+	other := NewRedisIndex(client, "other")
+
+	gateway1.Upsert(1, []string{"Competent", "Gopher", "gopher1"})
+	other.Upsert(1, []string{"Blue", "Whale", "whale"})
+	gateway2.Upsert(1, []string{"Mary", "Gopher", "mgopher"})
+
+	if found := gateway1.FindAll([]string{"mary", "goph"}, 5, nil); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("expected the renamed user to be found but got %v", found)
+	}
+	if found := gateway1.Find("comp", 5); found != nil {
+		t.Errorf("expected the old name to be removed but found %v", found)
+	}
+	if gateway1.Len() != 3 || other.Len() != 2 {
+		t.Errorf("expected lengths 3 and 2 but got %d and %d", gateway1.Len(), other.Len())
+	}
+	if found := other.Find("goph", 5); found != nil {
+		t.Errorf("expected the other index not to find gophers but got %v", found)
+	}
+
+	gateway2.Delete(1)
+	if found := gateway1.Find("goph", 5); found != nil {
+		t.Errorf("expected the deleted user to be gone but found %v", found)
+	}
+	if found := other.Find("whale", 5); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("expected the other index to keep its user but got %v", found)
+	}
+}
+
+func TestRedisIndexErrors(t *testing.T) {
+	server, client := newTestRedis(t)
+	index := NewRedisIndex(client, "search")
+	index.Upsert(1, []string{"Competent Gopher"})
+	server.Close()
+
+	//searches find nothing rather than failing when redis is down
+	if found := index.Find("comp", 5); found != nil {
+		t.Errorf("expected nothing to be found but got %v", found)
+	}
+	if found := index.FindFuzzy("gopher", 1, 5, nil); found != nil {
+		t.Errorf("expected nothing to be found but got %v", found)
+	}
+	if err := index.Save(&bytes.Buffer{}, time.Now()); err == nil {
+		t.Error("expected an error saving while redis is down")
+	}
+}
+
+func TestRedisIndexFindFuzzyPages(t *testing.T) {
+	server, client := newTestRedis(t)
+	defer server.Close()
+	index := NewRedisIndex(client, "search")
+
+	//more keys than a fuzzy search reads, none sharing a prefix that can be skipped
+	var keys []redis.Z
+	for i := 0; i < 150*150; i++ {
+		keys = append(keys, redis.Z{Member: string([]rune{rune(0x100 + i/150), rune(0x100 + i%150)})})
+	}
+	if err := client.ZAdd("search:keys", keys...).Err(); err != nil {
+		t.Fatalf("unexpected error adding keys: %v", err)
+	}
+
+	pages := 0
+	client.WrapProcess(func(process func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			if cmd.Name() == "zrangebylex" {
+				pages++
+			}
+			return process(cmd)
+		}
+	})
+	if found := index.FindFuzzy("abc", 1, 5, nil); found != nil {
+		t.Errorf("expected nothing to be found but got %v", found)
+	}
+	if pages != maxFuzzyPages {
+		t.Errorf("expected %d pages of keys to be read but got %d", maxFuzzyPages, pages)
+	}
+}
@@ -0,0 +1,219 @@
+package indexes
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+//snapshotMagic starts every snapshot, followed by the format version
+const snapshotMagic = "trie"
+
+//snapshotVersion is the version of the snapshot format written by Save
+const snapshotVersion = 1
+
+//ErrInvalidSnapshot is returned when a snapshot is corrupt or in an unknown format
+var ErrInvalidSnapshot = errors.New("invalid index snapshot")
+
+//Save writes a gzipped snapshot of the trie to w, recording `takenAt`
+//as the time the trie was up to date with its source.
+func (t *Trie) Save(w io.Writer, takenAt time.Time) error {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	return writeSnapshot(w, takenAt, t.root.countKeys(), func(sw *snapshotWriter) {
+		t.root.save(sw, []rune{})
+	})
+}
+
+//writeSnapshot writes a snapshot with `keys` keys, which `save` writes with writeKey.
+//Each key is written once with its values sorted and delta encoded.
+func writeSnapshot(w io.Writer, takenAt time.Time, keys int, save func(sw *snapshotWriter)) error {
+	gz := gzip.NewWriter(w)
+	sw := &snapshotWriter{w: bufio.NewWriter(gz)}
+	sw.writeString(snapshotMagic)
+	sw.writeUvarint(snapshotVersion)
+	sw.writeVarint(takenAt.UnixNano())
+	sw.writeUvarint(uint64(keys))
+	save(sw)
+	if sw.err == nil {
+		sw.err = sw.w.Flush()
+	}
+	if sw.err != nil {
+		return fmt.Errorf("writing index snapshot: %v", sw.err)
+	}
+	return gz.Close()
+}
+
+//Load reads a snapshot written by Save into the empty index,
+//returning the time the snapshot was taken at
+func Load(r io.Reader, index Index) (time.Time, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return time.Time{}, ErrInvalidSnapshot
+	}
+	defer gz.Close()
+	sr := &snapshotReader{r: bufio.NewReader(gz)}
+	if sr.readString() != snapshotMagic || sr.readUvarint() != snapshotVersion {
+		return time.Time{}, ErrInvalidSnapshot
+	}
+	takenAt := time.Unix(0, sr.readVarint())
+
+	keys := sr.readUvarint()
+	for i := uint64(0); i < keys && sr.err == nil; i++ {
+		key := sr.readString()
+		count := sr.readUvarint()
+		var value int64
+		for j := uint64(0); j < count && sr.err == nil; j++ {
+			if j == 0 {
+				value = sr.readVarint()
+			} else {
+				value += int64(sr.readUvarint())
+			}
+			index.Add(key, value)
+		}
+	}
+	//reading to the end checks the gzip checksum, catching corrupt snapshots
+	if _, err := sr.r.ReadByte(); sr.err != nil || err != io.EOF {
+		return time.Time{}, ErrInvalidSnapshot
+	}
+	return takenAt, nil
+}
+
+//SaveFile saves a snapshot of the index to the file at path. The snapshot is
+//written to a temporary file first, so the file is never left half written.
+func SaveFile(index Index, path string, takenAt time.Time) error {
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return fmt.Errorf("creating index snapshot: %v", err)
+	}
+	if err := index.Save(f, takenAt); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing index snapshot: %v", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+//LoadFile loads a snapshot from the file at path into the empty index.
+//The error satisfies os.IsNotExist if there is no snapshot yet.
+func LoadFile(path string, index Index) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	return Load(f, index)
+}
+
+//countKeys returns the number of keys with values in the node and its descendants
+func (currNode *trieNode) countKeys() int {
+	count := 0
+	if len(currNode.values) > 0 {
+		count++
+	}
+	for _, child := range currNode.children {
+		count += child.countKeys()
+	}
+	return count
+}
+
+//save writes the keys with values in the node and its descendants
+func (currNode *trieNode) save(sw *snapshotWriter, key []rune) {
+	if len(currNode.values) > 0 {
+		sw.writeKey(string(key), currNode.values)
+	}
+	for _, k := range currNode.sortKeys() {
+		currNode.children[k].save(sw, append(key, k))
+	}
+}
+
+//snapshotWriter writes varints and strings, keeping the first error
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+//writeKey writes the key with its values sorted and delta encoded
+func (sw *snapshotWriter) writeKey(key string, values int64set) {
+	sorted := values.all()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	sw.writeString(key)
+	sw.writeUvarint(uint64(len(sorted)))
+	for i, v := range sorted {
+		if i == 0 {
+			sw.writeVarint(v)
+		} else {
+			sw.writeUvarint(uint64(v - sorted[i-1]))
+		}
+	}
+}
+
+func (sw *snapshotWriter) writeUvarint(v uint64) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(sw.buf[:binary.PutUvarint(sw.buf[:], v)])
+	}
+}
+
+func (sw *snapshotWriter) writeVarint(v int64) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(sw.buf[:binary.PutVarint(sw.buf[:], v)])
+	}
+}
+
+func (sw *snapshotWriter) writeString(s string) {
+	sw.writeUvarint(uint64(len(s)))
+	if sw.err == nil {
+		_, sw.err = sw.w.WriteString(s)
+	}
+}
+
+//snapshotReader reads varints and strings, keeping the first error
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+//maxSnapshotString bounds the length of a key, so a corrupt
+//snapshot can't make Load allocate huge strings
+const maxSnapshotString = 1 << 16
+
+func (sr *snapshotReader) readUvarint() uint64 {
+	if sr.err != nil {
+		return 0
+	}
+	var v uint64
+	v, sr.err = binary.ReadUvarint(sr.r)
+	return v
+}
+
+func (sr *snapshotReader) readVarint() int64 {
+	if sr.err != nil {
+		return 0
+	}
+	var v int64
+	v, sr.err = binary.ReadVarint(sr.r)
+	return v
+}
+
+func (sr *snapshotReader) readString() string {
+	n := sr.readUvarint()
+	if sr.err != nil {
+		return ""
+	}
+	if n > maxSnapshotString {
+		sr.err = ErrInvalidSnapshot
+		return ""
+	}
+	buf := make([]byte, n)
+	_, sr.err = io.ReadFull(sr.r, buf)
+	return string(buf)
+}
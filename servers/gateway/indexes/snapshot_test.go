@@ -0,0 +1,108 @@
+package indexes
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	cases := []struct {
+		name  string
+		input []TestKeyVal
+	}{
+		{
+			"Empty",
+			[]TestKeyVal{},
+		},
+		{
+			"Shared Prefixes",
+			[]TestKeyVal{
+				{"john", 1},
+				{"john", 3},
+				{"johnny", 2},
+				{"jo", 7},
+				{"smith", 1},
+			},
+		},
+		{
+			"Non Latin Keys",
+			[]TestKeyVal{
+				{"世界", 1},
+				{"αννα", 2},
+				{"प्रिया", 3},
+			},
+		},
+		{
+			"Large And Negative Values",
+			[]TestKeyVal{
+				{"a", 1 << 40},
+				{"a", -5},
+				{"a", 0},
+			},
+		},
+	}
+
+	takenAt := time.Date(2018, 5, 1, 12, 30, 0, 0, time.UTC)
+	for _, c := range cases {
+		trie := NewTrie()
+		for _, v := range c.input {
+			trie.Add(v.Key, v.Val)
+		}
+		buf := &bytes.Buffer{}
+		if err := trie.Save(buf, takenAt); err != nil {
+			t.Errorf("case %s: unexpected error saving: %v", c.name, err)
+			continue
+		}
+		loaded := NewTrie()
+		loadedAt, err := Load(buf, loaded)
+		if err != nil {
+			t.Errorf("case %s: unexpected error loading: %v", c.name, err)
+			continue
+		}
+		if !loadedAt.Equal(takenAt) {
+			t.Errorf("case %s: expected snapshot time %v but got %v", c.name, takenAt, loadedAt)
+		}
+		if loaded.Len() != trie.Len() {
+			t.Errorf("case %s: expected length %d but got %d", c.name, trie.Len(), loaded.Len())
+		}
+		for _, v := range c.input {
+			expected := trie.Find(v.Key, 10)
+			found := loaded.Find(v.Key, 10)
+			sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+			sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+			if !reflect.DeepEqual(found, expected) {
+				t.Errorf("case %s: expected %q to find %v but got %v", c.name, v.Key, expected, found)
+			}
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	trie := NewTrie()
+	trie.Add("john", 1)
+	trie.Add("jane", 2)
+	buf := &bytes.Buffer{}
+	if err := trie.Save(buf, time.Now()); err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+	valid := buf.Bytes()
+
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", []byte{}},
+		{"Not Gzipped", []byte("trie")},
+		{"Truncated", valid[:len(valid)-6]},
+		{"Corrupt Checksum", append(append([]byte{}, valid[:len(valid)-8]...), 0, 0, 0, 0, 0, 0, 0, 0)},
+	}
+
+	for _, c := range cases {
+		if _, err := Load(bytes.NewReader(c.data), NewTrie()); err != ErrInvalidSnapshot {
+			t.Errorf("case %s: expected ErrInvalidSnapshot but got %v", c.name, err)
+		}
+	}
+}
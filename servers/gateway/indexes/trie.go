@@ -2,7 +2,6 @@ package indexes
 
 import (
 	"sort"
-	"strings"
 	"sync"
 )
 
@@ -23,6 +22,7 @@ type Trie struct {
 	root   *trieNode
 	mx     sync.RWMutex
 	length int
+	docs   documents
 }
 
 //NewTrie constructs a new Trie.
@@ -30,6 +30,7 @@ func NewTrie() *Trie {
 	return &Trie{
 		root:   &trieNode{children: map[rune]*trieNode{}},
 		length: 0,
+		docs:   documents{},
 	}
 }
 
@@ -42,14 +43,21 @@ func (t *Trie) Len() int {
 func (t *Trie) Add(key string, value int64) {
 	t.mx.Lock()
 	defer t.mx.Unlock()
+	t.add(key, value)
+}
+
+//add adds a key and value to the trie while the trie is locked
+func (t *Trie) add(key string, value int64) {
 	currNode := t.root
 
 	currNode = addHelper(key, currNode)
 	if currNode.values == nil {
 		currNode.values = int64set{}
 	}
-	currNode.values.add(value)
-	t.length++
+	if currNode.values.add(value) {
+		t.docs.add(value, key)
+		t.length++
+	}
 }
 
 //addHelper is a helper method for the Add function
@@ -73,32 +81,65 @@ func addHelper(key string, currNode *trieNode) *trieNode {
 //is entirely empty, or the prefix is empty, or n == 0,
 //or the prefix is not found, this returns a nil slice.
 func (t *Trie) Find(prefix string, n int) []int64 {
+	return t.FindExcluding(prefix, n, nil)
+}
+
+//FindExcluding finds `n` values matching `prefix` like Find,
+//but skips any of the values in `exclude`.
+func (t *Trie) FindExcluding(prefix string, n int, exclude []int64) []int64 {
 	t.mx.RLock()
 	defer t.mx.RUnlock()
 	//do checks properly
 	if t.length == 0 || len(prefix) == 0 || n == 0 {
 		return nil
 	}
-	currNode := t.root
+	currNode := t.root.find(prefix)
+	if currNode == nil {
+		return nil
+	}
+	return findExcluding(currNode, n, exclude)
+
+}
+
+//FindAll finds `n` distinct values that have keys matching every
+//one of the `prefixes`, skipping any of the values in `exclude`.
+//Values are found in the order Find would find them for the first
+//prefix. If any prefix is not found, this returns a nil slice.
+func (t *Trie) FindAll(prefixes []string, n int, exclude []int64) []int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	if t.length == 0 {
+		return nil
+	}
+	return findAll(prefixes, n, exclude, func(prefix string) prefixNode {
+		if node := t.root.find(prefix); node != nil {
+			return node
+		}
+		return nil
+	})
+}
+
+//find returns the node for the prefix, or nil if the prefix is not in the trie
+func (currNode *trieNode) find(prefix string) *trieNode {
 	for _, p := range prefix {
 		if currNode.children[p] == nil {
 			return nil
 		}
 		currNode = currNode.children[p]
 	}
-	var result []int64
-	currNode.findHelper(n, &result)
-	return result
-
+	return currNode
 }
 
-//findHelper is a helper method for Find which does the depth first search.
-func (currNode *trieNode) findHelper(n int, result *[]int64) {
-	//sort alphabetically here
-	for v := range currNode.values {
+//findHelper is a helper method for Find which does the depth first search,
+//adding the values that `keep` returns true for.
+func (currNode *trieNode) findHelper(n int, keep func(int64) bool, result *[]int64) {
+	for _, v := range currNode.values.sorted() {
 		if len(*result) >= n {
 			return
 		}
+		if !keep(v) {
+			continue
+		}
 		*result = append(*result, v)
 	}
 	sortedKeys := currNode.sortKeys()
@@ -107,11 +148,21 @@ func (currNode *trieNode) findHelper(n int, result *[]int64) {
 		if len(*result) == n {
 			return
 		}
-		currNode.children[k].findHelper(n, result)
+		currNode.children[k].findHelper(n, keep, result)
 	}
 
 }
 
+//collect adds the values of the node and all its descendants to the set
+func (currNode *trieNode) collect(values int64set) {
+	for v := range currNode.values {
+		values.add(v)
+	}
+	for _, child := range currNode.children {
+		child.collect(values)
+	}
+}
+
 func (currNode *trieNode) sortKeys() []rune {
 	keys := []rune{}
 	for k := range currNode.children {
@@ -126,23 +177,17 @@ func (currNode *trieNode) sortKeys() []rune {
 func (t *Trie) Remove(key string, value int64) {
 	t.mx.Lock()
 	defer t.mx.Unlock()
-	currNode := t.root
-	exited := false
-	for _, k := range key {
-		if currNode.children[k] == nil {
-			exited = true
-			return
-		}
-		currNode = currNode.children[k]
-
-	}
+	t.remove(key, value)
+}
 
-	if !exited && currNode.values.has(value) {
-		currNode.values.remove(value)
+//remove removes a key/value pair while the trie is locked
+func (t *Trie) remove(key string, value int64) {
+	currNode := t.root.find(key)
+	if currNode != nil && currNode.values.remove(value) {
+		t.docs.remove(value, key)
 		t.length--
 		trimBranches(currNode)
 	}
-
 }
 
 //trimBranches removes empty
@@ -156,35 +201,48 @@ func trimBranches(currNode *trieNode) {
 	}
 }
 
-//convertToLowerAndSpace converts username, firstname, and lastname to lowercase
-//and splits by space
-func convertToLowerAndSpace(input string) []string {
-	return strings.Split(strings.ToLower(input), " ")
-}
-
-//AddConvertedUsers adds each key and value pair to the trie
-func (t *Trie) AddConvertedUsers(firstName string, lastName string, userName string, id int64) {
-	addKeyVal(t, convertToLowerAndSpace(firstName), id)
-	addKeyVal(t, convertToLowerAndSpace(lastName), id)
-	addKeyVal(t, convertToLowerAndSpace(userName), id)
+//Upsert replaces the keys of the document with the value `id` with
+//the keys the analyzer makes from its fields, in one step so that
+//searches never see a mix of its old and new keys
+func (t *Trie) Upsert(id int64, fields []string) {
+	keys := analyzeFields(fields)
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, key := range t.docs.keys(id) {
+		t.remove(key, id)
+	}
+	for _, key := range keys {
+		t.add(key, id)
+	}
 }
 
-//addKeyVal adds the key and value pairs
-func addKeyVal(t *Trie, result []string, id int64) {
-	for _, r := range result {
-		t.Add(r, id)
+//Delete removes every key of the document with the value `id`
+func (t *Trie) Delete(id int64) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, key := range t.docs.keys(id) {
+		t.remove(key, id)
 	}
 }
 
-//RemoveConvertedUsers removes each key and value pair to the trie
-func (t *Trie) RemoveConvertedUsers(firstName string, lastName string, id int64) {
-	removeKeyVal(t, convertToLowerAndSpace(firstName), id)
-	removeKeyVal(t, convertToLowerAndSpace(lastName), id)
+//IDs returns every value stored in the trie
+func (t *Trie) IDs() []int64 {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	return t.docs.ids()
 }
 
-func removeKeyVal(t *Trie, result []string, id int64) {
-	for _, r := range result {
-		t.Remove(r, id)
-	}
+//AddConvertedUsers adds the keys the analyzer makes from the user's
+//first name, last name and username to the trie
+func (t *Trie) AddConvertedUsers(firstName string, lastName string, userName string, id int64) {
+	addKeyVal(t, Analyze(firstName), id)
+	addKeyVal(t, Analyze(lastName), id)
+	addKeyVal(t, Analyze(userName), id)
+}
 
+//RemoveConvertedUsers removes the keys the analyzer makes from
+//the user's first and last name from the trie
+func (t *Trie) RemoveConvertedUsers(firstName string, lastName string, id int64) {
+	removeKeyVal(t, Analyze(firstName), id)
+	removeKeyVal(t, Analyze(lastName), id)
 }
@@ -2,6 +2,7 @@ package indexes
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -294,6 +295,24 @@ func TestAddConvertedUsers(t *testing.T) {
 			"m",
 			[]int64{1},
 		},
+		{
+			"Add accented found unaccented",
+			"José",
+			"Núñez",
+			"test1234",
+			1,
+			"nun",
+			[]int64{1},
+		},
+		{
+			"Add tabs and repeated spaces",
+			"Mary\t  Ann",
+			"Gopher",
+			"test1234",
+			1,
+			"ann",
+			[]int64{1},
+		},
 	}
 
 	for _, c := range cases {
@@ -383,3 +402,163 @@ func TestRemoveConvertedUsers(t *testing.T) {
 	}
 
 }
+
+func TestFindExcluding(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    []TestKeyVal
+		findQ    string
+		n        int
+		exclude  []int64
+		expected []int64
+	}{
+		{
+			"Nothing Excluded",
+			[]TestKeyVal{
+				{"hell", 5},
+				{"hello", 1},
+				{"hero", 10},
+			},
+			"he",
+			3,
+			nil,
+			[]int64{5, 1, 10},
+		},
+		{
+			"Excluded Values Skipped",
+			[]TestKeyVal{
+				{"hell", 5},
+				{"hello", 1},
+				{"hero", 10},
+			},
+			"he",
+			3,
+			[]int64{1},
+			[]int64{5, 10},
+		},
+		{
+			"Excluded Values Don't Count Toward Limit",
+			[]TestKeyVal{
+				{"hell", 5},
+				{"hello", 1},
+				{"hero", 10},
+			},
+			"he",
+			2,
+			[]int64{5},
+			[]int64{1, 10},
+		},
+		{
+			"Everything Excluded",
+			[]TestKeyVal{
+				{"hello", 1},
+			},
+			"he",
+			2,
+			[]int64{1},
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		trie := NewTrie()
+		for _, v := range c.input {
+			trie.Add(v.Key, v.Val)
+		}
+		testResult := trie.FindExcluding(c.findQ, c.n, c.exclude)
+		if !reflect.DeepEqual(c.expected, testResult) {
+			t.Errorf("case: %s, unexpected result expected %v, got %v", c.name, c.expected, testResult)
+		}
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	input := []TestKeyVal{
+		{"john", 1},
+		{"smith", 1},
+		{"johnny", 2},
+		{"smithers", 2},
+		{"johnsmi", 2},
+		{"john", 3},
+		{"doe", 3},
+		{"jane", 4},
+		{"smith", 4},
+	}
+	cases := []struct {
+		name     string
+		prefixes []string
+		n        int
+		exclude  []int64
+		expected []int64
+	}{
+		{
+			"Single Prefix Distinct Values",
+			[]string{"johnn"},
+			5,
+			nil,
+			[]int64{2},
+		},
+		{
+			"Every Prefix Must Match",
+			[]string{"john", "smi"},
+			5,
+			nil,
+			[]int64{1, 2},
+		},
+		{
+			"Order Independent",
+			[]string{"smi", "john"},
+			5,
+			nil,
+			[]int64{1, 2},
+		},
+		{
+			"No Value Matches Every Prefix",
+			[]string{"jane", "doe"},
+			5,
+			nil,
+			nil,
+		},
+		{
+			"Prefix Not Found",
+			[]string{"john", "zzz"},
+			5,
+			nil,
+			nil,
+		},
+		{
+			"Excluded Values",
+			[]string{"john", "smi"},
+			5,
+			[]int64{1},
+			[]int64{2},
+		},
+		{
+			"Limited",
+			[]string{"jo", "smi"},
+			1,
+			nil,
+			[]int64{1},
+		},
+		{
+			"No Prefixes",
+			[]string{},
+			5,
+			nil,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		trie := NewTrie()
+		for _, v := range input {
+			trie.Add(v.Key, v.Val)
+		}
+		result := trie.FindAll(c.prefixes, c.n, c.exclude)
+		//values under the same key are found in any order, so sort to compare
+		sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, result)
+		}
+	}
+}
@@ -2,14 +2,24 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/exports"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/affinity"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/audit"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/blocks"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/channels"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/invites"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/models/preferences"
 	"github.com/info344-s18/challenges-ask710/servers/gateway/models/users"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/presence"
 	"github.com/streadway/amqp"
 
 	"github.com/info344-s18/challenges-ask710/servers/gateway/sessions"
@@ -38,9 +48,14 @@ func main() {
 	redisAddr := reqEnv("REDISADDR")
 	messageAddrs := reqEnv("MESSAGESADDR")
 	summaryAddrs := reqEnv("SUMMARYADDR")
+	searchAddrs := reqEnv("SEARCHADDR")
 	mqAddr := reqEnv("MQADDR")
 	mqName := reqEnv("MQNAME")
 	dsn := reqEnv("DSN")
+	registration, err := handlers.NewRegistrationPolicy(os.Getenv("REGISTRATIONMODE"), os.Getenv("ALLOWEDDOMAINS"))
+	if err != nil {
+		log.Fatalf("Error reading registration settings: %v", err)
+	}
 
 	if len(addr) == 0 {
 		addr = ":443"
@@ -55,7 +70,7 @@ func main() {
 		DB:       0,
 	})
 
-	_, err := redisClient.Ping().Result()
+	_, err = redisClient.Ping().Result()
 	if err != nil {
 		log.Printf("Error connecting to redis database: %v", err)
 		os.Exit(1)
@@ -71,60 +86,166 @@ func main() {
 	defer db.Close()
 	userStore := users.NewMySQLStore(db)
 
-	trie, err := userStore.LoadUsers()
+	//SEARCHSNAPSHOT is where the search trie is saved, so it can be
+	//loaded and caught up at startup instead of rebuilt from every user
+	snapshotPath := os.Getenv("SEARCHSNAPSHOT")
+	var index, channelIndex indexes.Index
+	var syncedAt time.Time
+	//the redis index is shared by every gateway, so it needs no snapshot or user events
+	sharedIndex := os.Getenv("SEARCHINDEX") == "redis"
+	if sharedIndex {
+		index = indexes.NewRedisIndex(redisClient, SearchIndexRedisPrefix)
+		channelIndex = indexes.NewRedisIndex(redisClient, SearchChannelsRedisPrefix)
+		snapshotPath = ""
+		syncedAt, err = users.OpenSharedSearchIndex(userStore, index)
+	} else {
+		var newIndex func() indexes.Index
+		newIndex, err = searchIndexType(os.Getenv("SEARCHINDEX"))
+		if err != nil {
+			log.Fatalf("Error reading search index settings: %v", err)
+		}
+		index, syncedAt, err = users.LoadSearchIndex(userStore, newIndex, snapshotPath)
+		channelIndex = newIndex()
+	}
 	if err != nil {
-		trie = indexes.NewTrie()
+		log.Fatalf("Error loading search index: %v", err)
 	}
+	go syncSearchIndex(userStore, index, syncedAt, snapshotPath)
 
-	conn, err := connectToMQ(mqAddr)
-	if err != nil {
-		log.Printf("error dialing MQ: %v", err)
+	channelStore := channels.NewMySQLStore(db)
+	if channelIndex.Len() == 0 {
+		if err := channelStore.LoadChannels(channelIndex); err != nil {
+			log.Fatalf("Error loading channel search index: %v", err)
+		}
 	}
+	go reconcileChannelIndex(channelStore, channelIndex)
 
-	channel, err := conn.Channel()
+	notifier := handlers.NewNotifier()
+	ctx := handlers.NewContext(sessionKey, redisStore, userStore, index, notifier)
+	ctx.InviteStore = invites.NewMySQLStore(db)
+	ctx.Registration = registration
+	//the publishers are given a channel each time the MQ supervisor connects
+	events := handlers.NewMQPublisher(nil, mqName)
+	userEvents := handlers.NewMQExchangePublisher(nil, handlers.UserEventsExchange)
+	ctx.Events = events
+	ctx.UserEvents = userEvents
+	//SEARCHCHANNELWEIGHT and SEARCHDMWEIGHT are how much each shared channel
+	//and recent direct messages boost users in search results
+	searchWeights, err := affinity.ParseWeights(os.Getenv("SEARCHCHANNELWEIGHT"), os.Getenv("SEARCHDMWEIGHT"))
 	if err != nil {
-		log.Printf("error getting channel: %v", err)
+		log.Fatalf("Error reading search weights: %v", err)
+	}
+	ctx.SearchBoost = handlers.NewSearchBooster(affinity.NewMySQLStore(db), searchWeights)
+	ctx.PreferencesStore = preferences.NewRedisCache(redisClient, preferences.NewMySQLStore(db), time.Hour)
+	ctx.Blocks = handlers.NewBlockList(blocks.NewMySQLStore(db))
+	//AUDITLOG writes the audit log to a JSON lines file instead of MySQL
+	if auditPath := os.Getenv("AUDITLOG"); len(auditPath) > 0 {
+		fileSink, err := audit.NewFileSink(auditPath)
+		if err != nil {
+			log.Fatalf("Error opening audit log: %v", err)
+		}
+		defer fileSink.Close()
+		ctx.Audit = fileSink
+	} else {
+		ctx.Audit = audit.NewMySQLSink(db)
+	}
+	//TRUSTEDPROXIES lists the addresses of proxies in front of the gateway,
+	//whose X-Forwarded-For header is believed when auditing
+	if proxies := os.Getenv("TRUSTEDPROXIES"); len(proxies) > 0 {
+		for _, proxy := range strings.Split(proxies, ",") {
+			ctx.TrustedProxies = append(ctx.TrustedProxies, strings.TrimSpace(proxy))
+		}
 	}
+	notifier.Filter = ctx.Blocks
 
-	q, err := channel.QueueDeclare(mqName,
-		false,
-		false,
-		false,
-		false,
-		nil)
+	exportDir := os.Getenv("EXPORTDIR")
+	if len(exportDir) == 0 {
+		exportDir = "exports"
+	}
+	exporter, err := exports.NewExporter(exportDir, exports.NewMySQLSource(db), userStore, ctx.PreferencesStore)
 	if err != nil {
-		log.Printf("error declaring queue: %v", err)
+		log.Fatalf("Error creating exporter: %v", err)
 	}
+	exporter.Done = ctx.ExportDone
+	ctx.Exporter = exporter
+	go purgeExports(exporter)
 
-	messages, err := channel.Consume(q.Name,
-		"",
-		false,
-		false,
-		false,
-		false,
-		nil)
-
+	presenceStore := presence.NewRedisStore(redisClient, handlers.PresenceConnectionTTL)
+	ctx.PresenceStore = presenceStore
+	presenceTracker, err := handlers.NewPresenceTracker(presenceStore, userStore, notifier)
 	if err != nil {
-		log.Printf("error consuming messages: %v", err)
+		log.Fatalf("Error creating presence tracker: %v", err)
 	}
-	notifier := handlers.NewNotifier()
-	ctx := handlers.NewContext(sessionKey, redisStore, userStore, trie, notifier)
+	if err := presenceTracker.Start(); err != nil {
+		log.Fatalf("Error starting presence tracker: %v", err)
+	}
+	notifier.Listener = presenceTracker
 
-	go ctx.Notifier.ProcessMessages(messages)
+	channelIndexer := handlers.NewChannelIndexer(channelIndex)
+	ctx.ChannelStore = channelStore
+	ctx.ChannelIndex = channelIndex
+
+	//the supervisor declares the queues and starts consuming again every time it
+	//reconnects, so the gateway starts and keeps running while RabbitMQ is down
+	mqSupervisor := handlers.NewMQSupervisor(mqAddr)
+	mqSupervisor.Setup = func(channel *amqp.Channel) error {
+		messages, err := handlers.ConsumeNotifications(channel, mqName)
+		if err != nil {
+			return err
+		}
+		syncEvents, err := handlers.ConsumeUserEvents(channel)
+		if err != nil {
+			return err
+		}
+		channelEvents, err := handlers.ConsumeChannelEvents(channel)
+		if err != nil {
+			return err
+		}
+		events.SetChannel(channel)
+		userEvents.SetChannel(channel)
+		go notifier.ProcessMessages(messages)
+		//a shared index is kept current by every gateway, but user events
+		//still close the WebSockets of users who can no longer sign in
+		var syncIndex indexes.Index
+		if !sharedIndex {
+			syncIndex = index
+		}
+		go handlers.SyncUserEvents(syncIndex, notifier, syncEvents)
+		go channelIndexer.SyncEvents(channelEvents)
+		return nil
+	}
+	ctx.MQ = mqSupervisor
+	go mqSupervisor.Run()
 
 	mux := mux.NewRouter()
 
-	mux.HandleFunc("/v1/users", ctx.UsersHandler)
-	mux.HandleFunc("/v1/users/{id}", ctx.SpecificUserHandler)
-	mux.HandleFunc("/v1/sessions", ctx.SessionsHandler)
-	mux.HandleFunc("/v1/sessions/{id}", ctx.SpecificSessionHandler)
-	mux.HandleFunc("/v1/users/{id}/avatar", ctx.AvatarHandler)
-	mux.HandleFunc("/v1/resetcodes", ctx.ResetHandler)
-	mux.HandleFunc("/v1/passwords/{email}", ctx.CompleteResetHandler)
+	mux.Handle("/v1/users", ctx.Public(http.HandlerFunc(ctx.UsersHandler)))
+	mux.Handle("/v1/users/{id}", ctx.Authenticated(http.HandlerFunc(ctx.SpecificUserHandler)))
+	mux.Handle("/v1/sessions", ctx.Public(http.HandlerFunc(ctx.SessionsHandler)))
+	mux.Handle("/v1/sessions/{id}", ctx.Authenticated(http.HandlerFunc(ctx.SpecificSessionHandler)))
+	mux.Handle("/v1/users/{id}/avatar", ctx.Authenticated(http.HandlerFunc(ctx.AvatarHandler)))
+	mux.Handle("/v1/users/{id}/status", ctx.Authenticated(http.HandlerFunc(ctx.UserStatusHandler)))
+	mux.Handle("/v1/users/{id}/presence", ctx.Authenticated(http.HandlerFunc(ctx.PresenceHandler)))
+	mux.Handle("/v1/users/me/preferences", ctx.Authenticated(http.HandlerFunc(ctx.PreferencesHandler)))
+	mux.Handle("/v1/users/me/blocks", ctx.Authenticated(http.HandlerFunc(ctx.BlocksHandler)))
+	mux.Handle("/v1/users/me/exports", ctx.Authenticated(http.HandlerFunc(ctx.ExportsHandler)))
+	mux.Handle("/v1/users/me/exports/{id}", ctx.Authenticated(http.HandlerFunc(ctx.SpecificExportHandler)))
+	mux.Handle("/v1/exports/{id}/download", ctx.Public(http.HandlerFunc(ctx.ExportDownloadHandler)))
+	mux.Handle("/v1/users/me/blocks/{id}", ctx.Authenticated(http.HandlerFunc(ctx.SpecificBlockHandler)))
+	mux.Handle("/v1/presence", ctx.Authenticated(http.HandlerFunc(ctx.BatchPresenceHandler)))
+	mux.Handle("/v1/search/channels", ctx.Authenticated(http.HandlerFunc(ctx.ChannelSearchHandler)))
+	mux.Handle("/v1/resetcodes", ctx.Public(http.HandlerFunc(ctx.ResetHandler)))
+	mux.Handle("/v1/passwords/{email}", ctx.Public(http.HandlerFunc(ctx.CompleteResetHandler)))
+	mux.Handle("/v1/invites", ctx.Admin(http.HandlerFunc(ctx.InvitesHandler)))
+	mux.Handle("/v1/invites/{code}", ctx.Admin(http.HandlerFunc(ctx.SpecificInviteHandler)))
+	mux.Handle("/v1/audit", ctx.Admin(http.HandlerFunc(ctx.AuditHandler)))
+	mux.Handle("/v1/health/mq", ctx.Public(http.HandlerFunc(ctx.MQHealthHandler)))
+	mux.Handle("/v1/health/mq/details", ctx.Admin(http.HandlerFunc(ctx.MQDetailsHandler)))
 
-	mux.Handle("/v1/summary", ctx.NewServiceProxy(summaryAddrs))
+	mux.Handle("/v1/summary", ctx.Public(ctx.NewServiceProxy(summaryAddrs)))
+	mux.Handle("/v1/search/messages", ctx.Authenticated(ctx.NewServiceProxy(searchAddrs)))
 
-	messageService := ctx.NewServiceProxy(messageAddrs)
+	messageService := ctx.Authenticated(ctx.NewServiceProxy(messageAddrs))
 	mux.Handle("/v1/channels", messageService)
 	mux.Handle("/v1/channels/{channelID}", messageService)
 	mux.Handle("/v1/channels/{channelID}/members", messageService)
@@ -133,13 +254,90 @@ func main() {
 	mux.Handle("/v1/users/me/starred/messages", messageService)
 	mux.Handle("/v1/users/me/starred/messages/{messageID}", messageService)
 
-	mux.Handle("/v1/ws", handlers.NewWebSocketHandler(ctx))
+	mux.Handle("/v1/ws", ctx.Authenticated(handlers.NewWebSocketHandler(ctx)))
 	wrappedMux := handlers.NewCorsHandler(mux)
 
 	log.Printf("Server is listening at https://%s", addr)
 	log.Fatal(http.ListenAndServeTLS(addr, tlsCertPath, tlsKeyPath, wrappedMux))
 }
 
+//purgeExports deletes old exports every hour
+func purgeExports(exporter *exports.Exporter) {
+	for range time.Tick(time.Hour) {
+		if err := exporter.Purge(time.Now().Add(-handlers.ExportRetention)); err != nil {
+			log.Printf("error purging exports: %v", err)
+		}
+	}
+}
+
+//SearchSyncInterval is how often the search index catches up
+//with changed users and is saved to its snapshot
+const SearchSyncInterval = 5 * time.Minute
+
+//SearchReconcileInterval is how often the search index is
+//reconciled with every user, in case anything was missed
+const SearchReconcileInterval = time.Hour
+
+//SearchIndexRedisPrefix is put before the redis keys of the shared search index
+const SearchIndexRedisPrefix = "search:users"
+
+//SearchChannelsRedisPrefix is put before the redis keys of the shared channel search index
+const SearchChannelsRedisPrefix = "search:channels"
+
+//searchIndexType returns the constructor for the search index named by
+//SEARCHINDEX, which is "trie" by default or "radix" for the smaller radix tree
+func searchIndexType(name string) (func() indexes.Index, error) {
+	switch name {
+	case "", "trie":
+		return func() indexes.Index { return indexes.NewTrie() }, nil
+	case "radix":
+		return func() indexes.Index { return indexes.NewRadixTree() }, nil
+	default:
+		return nil, fmt.Errorf("unknown search index %q", name)
+	}
+}
+
+//syncSearchIndex catches the index up with users changed by any gateway,
+//then saves it to the snapshot at path if there is one. Every so often it
+//reconciles the index with every user instead, to repair anything the
+//user events and catching up missed.
+func syncSearchIndex(userStore users.Store, index indexes.Index, syncedAt time.Time, path string) {
+	catchUp := time.Tick(SearchSyncInterval)
+	reconcile := time.Tick(SearchReconcileInterval)
+	for {
+		select {
+		case <-reconcile:
+			if err := users.Reconcile(userStore, index); err != nil {
+				log.Printf("error reconciling search index: %v", err)
+			}
+		case <-catchUp:
+			caughtUp, err := users.CatchUp(userStore, index, syncedAt)
+			if err != nil {
+				log.Printf("error catching up search index: %v", err)
+				continue
+			}
+			syncedAt = caughtUp
+			if len(path) == 0 {
+				continue
+			}
+			if err := indexes.SaveFile(index, path, syncedAt); err != nil {
+				log.Printf("error saving search snapshot: %v", err)
+			}
+		}
+	}
+}
+
+//reconcileChannelIndex reconciles the channel search index with the channel
+//table every SearchSyncInterval. Each channel event is only consumed by one
+//gateway, so this is how the others catch up with the changed channels.
+func reconcileChannelIndex(store channels.Store, index indexes.Index) {
+	for range time.Tick(SearchSyncInterval) {
+		if err := indexes.Reconcile(index, store.LoadChannels); err != nil {
+			log.Printf("error reconciling channel search index: %v", err)
+		}
+	}
+}
+
 func reqEnv(name string) string {
 	val := os.Getenv(name)
 	if len(val) == 0 {
@@ -149,21 +347,3 @@ func reqEnv(name string) string {
 	}
 	return val
 }
-
-//connectToMQ makes retries if necessary to connect to rabbitmq
-func connectToMQ(addr string) (*amqp.Connection, error) {
-	mqURL := "amqp://" + addr
-	var conn *amqp.Connection
-	var err error
-	for i := 1; i <= handlers.MaxConnRetries; i++ {
-		conn, err = amqp.Dial(mqURL)
-		if err == nil {
-			log.Printf("successfully connect to %s", mqURL)
-			return conn, nil
-		}
-		log.Printf("error connecting to MQ at %s: %v", mqURL, err)
-		log.Printf("will retry in %d seconds", i*2)
-		time.Sleep(time.Second * time.Duration(i*2))
-	}
-	return nil, err
-}
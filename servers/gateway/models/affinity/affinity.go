@@ -0,0 +1,76 @@
+package affinity
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+//RecentWindow is how far back direct messages count as recent interaction
+const RecentWindow = 30 * 24 * time.Hour
+
+//Affinity is how closely a user is connected to another user
+type Affinity struct {
+	//SharedChannels is the number of channels both users are members of
+	SharedChannels int
+	//DirectMessages is the number of messages either user sent in
+	//their direct message channels since the start of RecentWindow
+	DirectMessages int
+}
+
+//Weights are how much each kind of connection adds to an affinity's score
+type Weights struct {
+	SharedChannel float64
+	DirectMessage float64
+}
+
+//DefaultWeights count recent direct messages for more than shared channels,
+//since talking to someone directly says more than being in the same channel
+var DefaultWeights = Weights{SharedChannel: 1, DirectMessage: 2}
+
+//Score returns how strongly the affinity should boost a search result. Each
+//shared channel adds its weight, while direct messages add their weight for
+//every doubling, so a long conversation can't drown out everything else.
+func (w Weights) Score(a *Affinity) float64 {
+	if a == nil {
+		return 0
+	}
+	return w.SharedChannel*float64(a.SharedChannels) +
+		w.DirectMessage*math.Log2(1+float64(a.DirectMessages))
+}
+
+//Scores returns the score of each user's affinity, leaving out users with no score
+func (w Weights) Scores(affinities map[int64]*Affinity) map[int64]float64 {
+	scores := make(map[int64]float64, len(affinities))
+	for id, a := range affinities {
+		if score := w.Score(a); score != 0 {
+			scores[id] = score
+		}
+	}
+	return scores
+}
+
+//ParseWeights returns the weights for shared channels and direct messages
+//given as decimal numbers, using the default for either that is empty
+func ParseWeights(sharedChannel string, directMessage string) (Weights, error) {
+	weights := DefaultWeights
+	for _, w := range []struct {
+		name   string
+		value  string
+		weight *float64
+	}{
+		{"shared channel", sharedChannel, &weights.SharedChannel},
+		{"direct message", directMessage, &weights.DirectMessage},
+	} {
+		if len(w.value) == 0 {
+			continue
+		}
+		f, err := strconv.ParseFloat(w.value, 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return Weights{}, fmt.Errorf("invalid %s weight %q", w.name, w.value)
+		}
+		*w.weight = f
+	}
+	return weights, nil
+}
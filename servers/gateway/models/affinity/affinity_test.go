@@ -0,0 +1,66 @@
+package affinity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	cases := []struct {
+		name     string
+		weights  Weights
+		affinity *Affinity
+		expected float64
+	}{
+		{"No Affinity", DefaultWeights, nil, 0},
+		{"Shared Channels", DefaultWeights, &Affinity{SharedChannels: 3}, 3},
+		{"Direct Messages Grow Slowly", DefaultWeights, &Affinity{DirectMessages: 7}, 6},
+		{"Both", Weights{SharedChannel: 0.5, DirectMessage: 1}, &Affinity{SharedChannels: 2, DirectMessages: 1}, 2},
+		{"Zero Weights", Weights{}, &Affinity{SharedChannels: 2, DirectMessages: 15}, 0},
+	}
+
+	for _, c := range cases {
+		if score := c.weights.Score(c.affinity); score != c.expected {
+			t.Errorf("case %s: expected score %v but got %v", c.name, c.expected, score)
+		}
+	}
+}
+
+func TestScores(t *testing.T) {
+	scores := DefaultWeights.Scores(map[int64]*Affinity{
+		1: {SharedChannels: 2},
+		2: {},
+		3: {DirectMessages: 1},
+	})
+	expected := map[int64]float64{1: 2, 3: 2}
+	if !reflect.DeepEqual(scores, expected) {
+		t.Errorf("expected %v but got %v", expected, scores)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	cases := []struct {
+		name          string
+		sharedChannel string
+		directMessage string
+		expected      Weights
+		expectError   bool
+	}{
+		{"Defaults", "", "", DefaultWeights, false},
+		{"Both Set", "0.5", "3", Weights{SharedChannel: 0.5, DirectMessage: 3}, false},
+		{"One Set", "0", "", Weights{SharedChannel: 0, DirectMessage: DefaultWeights.DirectMessage}, false},
+		{"Not A Number", "lots", "", Weights{}, true},
+		{"Negative", "", "-1", Weights{}, true},
+		{"Infinite", "+Inf", "", Weights{}, true},
+	}
+
+	for _, c := range cases {
+		weights, err := ParseWeights(c.sharedChannel, c.directMessage)
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: expected error %t but got %v", c.name, c.expectError, err)
+		}
+		if weights != c.expected {
+			t.Errorf("case %s: expected %+v but got %+v", c.name, c.expected, weights)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package affinity
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//sqlSharedChannels counts the channels the user shares with each candidate
+const sqlSharedChannels = "select them.usersid, count(*) from channel_users me " +
+	"join channel_users them on them.channelid = me.channelid " +
+	"where me.usersid = ? and them.usersid <> me.usersid and them.usersid in "
+
+//sqlDirectMessages counts the recent messages in the private channels
+//that have only the user and a candidate as members
+const sqlDirectMessages = "select them.usersid, count(*) from channel c " +
+	"join channel_users me on me.channelid = c.id " +
+	"join channel_users them on them.channelid = c.id " +
+	"join messages m on m.channelid = c.id " +
+	"where c.channelprivate = true " +
+	"and (select count(*) from channel_users cu where cu.channelid = c.id) = 2 " +
+	"and m.createdat >= ? and me.usersid = ? and them.usersid <> me.usersid and them.usersid in "
+
+//MySQLStore represents an affinity.Store backed by the
+//channel_users and messages tables in MySQL
+type MySQLStore struct {
+	db *sql.DB
+}
+
+//NewMySQLStore constructs a new MySQLStore.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{
+		db: db,
+	}
+}
+
+//Get returns the affinity of the user with each of the candidates,
+//counting direct messages sent since `since`. Candidates with no
+//connection to the user are left out.
+func (s *MySQLStore) Get(userID int64, candidates []int64, since time.Time) (map[int64]*Affinity, error) {
+	affinities := map[int64]*Affinity{}
+	if len(candidates) == 0 {
+		return affinities, nil
+	}
+	in := "(?" + strings.Repeat(",?", len(candidates)-1) + ") group by them.usersid"
+
+	args := []interface{}{userID}
+	for _, id := range candidates {
+		args = append(args, id)
+	}
+	err := s.count(sqlSharedChannels+in, args, func(id int64, count int) {
+		affinityOf(affinities, id).SharedChannels = count
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting shared channels: %v", err)
+	}
+
+	args = append([]interface{}{since}, args...)
+	err = s.count(sqlDirectMessages+in, args, func(id int64, count int) {
+		affinityOf(affinities, id).DirectMessages = count
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting direct messages: %v", err)
+	}
+	return affinities, nil
+}
+
+//count runs a query for rows of a user ID and a count, passing each to `add`
+func (s *MySQLStore) count(query string, args []interface{}, add func(id int64, count int)) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return err
+		}
+		add(id, count)
+	}
+	return rows.Err()
+}
+
+//affinityOf returns the user's affinity, adding it if it isn't there yet
+func affinityOf(affinities map[int64]*Affinity, id int64) *Affinity {
+	a, ok := affinities[id]
+	if !ok {
+		a = &Affinity{}
+		affinities[id] = a
+	}
+	return a
+}
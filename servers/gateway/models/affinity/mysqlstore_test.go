@@ -0,0 +1,108 @@
+package affinity
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestGet(t *testing.T) {
+	since := time.Now().Add(-RecentWindow)
+	in := "(?,?,?) group by them.usersid"
+	cases := []struct {
+		name        string
+		expect      func(mock sqlmock.Sqlmock)
+		expected    map[int64]*Affinity
+		expectError bool
+	}{
+		{
+			"Shared Channels And Direct Messages",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(sqlSharedChannels+in)).
+					WithArgs(1, 2, 3, 4).
+					WillReturnRows(sqlmock.NewRows([]string{"usersid", "count"}).AddRow(2, 3).AddRow(3, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlDirectMessages+in)).
+					WithArgs(since, 1, 2, 3, 4).
+					WillReturnRows(sqlmock.NewRows([]string{"usersid", "count"}).AddRow(2, 10).AddRow(4, 1))
+			},
+			map[int64]*Affinity{
+				2: {SharedChannels: 3, DirectMessages: 10},
+				3: {SharedChannels: 1},
+				4: {DirectMessages: 1},
+			},
+			false,
+		},
+		{
+			"No Connections",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(sqlSharedChannels + in)).
+					WillReturnRows(sqlmock.NewRows([]string{"usersid", "count"}))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlDirectMessages + in)).
+					WillReturnRows(sqlmock.NewRows([]string{"usersid", "count"}))
+			},
+			map[int64]*Affinity{},
+			false,
+		},
+		{
+			"Shared Channels Error",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(sqlSharedChannels + in)).
+					WillReturnError(errors.New("query failed"))
+			},
+			nil,
+			true,
+		},
+		{
+			"Direct Messages Error",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(sqlSharedChannels + in)).
+					WillReturnRows(sqlmock.NewRows([]string{"usersid", "count"}))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlDirectMessages + in)).
+					WillReturnError(errors.New("query failed"))
+			},
+			nil,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating sql mock: %v", err)
+		}
+		c.expect(mock)
+		store := NewMySQLStore(db)
+		affinities, err := store.Get(1, []int64{2, 3, 4}, since)
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: expected error %t but got %v", c.name, c.expectError, err)
+		}
+		if !reflect.DeepEqual(affinities, c.expected) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, affinities)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("case %s: unmet sqlmock expectations: %v", c.name, err)
+		}
+		db.Close()
+	}
+}
+
+func TestGetNoCandidates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	//no queries are expected
+	affinities, err := NewMySQLStore(db).Get(1, nil, time.Now())
+	if err != nil || len(affinities) != 0 {
+		t.Errorf("expected no affinities and no error but got %v, %v", affinities, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+package affinity
+
+import "time"
+
+//Store represents a store of how users are connected to each other
+type Store interface {
+	//Get returns the affinity of the user with each of the candidates,
+	//counting direct messages sent since `since`. Candidates with no
+	//connection to the user are left out.
+	Get(userID int64, candidates []int64, since time.Time) (map[int64]*Affinity, error)
+}
@@ -0,0 +1,102 @@
+package audit
+
+import "time"
+
+//Actions recorded in the audit log
+const (
+	ActionSignIn                 = "sign-in"
+	ActionSignOut                = "sign-out"
+	ActionPasswordResetRequested = "password-reset-requested"
+	ActionPasswordReset          = "password-reset"
+	ActionProfileUpdated         = "profile-updated"
+	ActionAvatarUploaded         = "avatar-uploaded"
+	ActionStatusChanged          = "status-changed"
+	ActionSessionsRevoked        = "sessions-revoked"
+	ActionDataExported           = "data-exported"
+)
+
+//Outcomes of an audited action
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+//DefaultLimit is the number of entries returned when a query doesn't set a limit
+const DefaultLimit = 50
+
+//MaxLimit is the most entries a query may return
+const MaxLimit = 200
+
+//Entry is one record in the audit log. ActorID is the user who made the
+//request, and TargetID the user it acted on; either is 0 when unknown.
+type Entry struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	ActorID   int64     `json:"actorID,omitempty"`
+	Action    string    `json:"action"`
+	TargetID  int64     `json:"targetID,omitempty"`
+	IP        string    `json:"ip"`
+	//ForwardedFor is the X-Forwarded-For header sent with the request,
+	//which is kept as sent since clients can forge it
+	ForwardedFor string `json:"forwardedFor,omitempty"`
+	UserAgent    string `json:"userAgent"`
+	Outcome      string `json:"outcome"`
+	Detail       string `json:"detail,omitempty"`
+}
+
+//Query filters the audit log. Zero values match every entry.
+type Query struct {
+	ActorID  int64
+	TargetID int64
+	Action   string
+	Outcome  string
+	Since    *time.Time
+	Until    *time.Time
+	//Before is a cursor, only entries with an ID less than it are returned
+	Before int64
+	Limit  int
+}
+
+//Page is one page of audit log entries, newest first. NextCursor is
+//passed as the Before of the next query, and is 0 on the last page.
+type Page struct {
+	Entries    []*Entry `json:"entries"`
+	NextCursor int64    `json:"nextCursor,omitempty"`
+}
+
+//limit returns the number of entries the query should return
+func (q *Query) limit() int {
+	if q.Limit <= 0 {
+		return DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		return MaxLimit
+	}
+	return q.Limit
+}
+
+//Matches returns true if the entry matches every filter in the query
+func (q *Query) Matches(entry *Entry) bool {
+	switch {
+	case q.ActorID != 0 && entry.ActorID != q.ActorID,
+		q.TargetID != 0 && entry.TargetID != q.TargetID,
+		q.Action != "" && entry.Action != q.Action,
+		q.Outcome != "" && entry.Outcome != q.Outcome,
+		q.Since != nil && entry.CreatedAt.Before(*q.Since),
+		q.Until != nil && !entry.CreatedAt.Before(*q.Until),
+		q.Before != 0 && entry.ID >= q.Before:
+		return false
+	}
+	return true
+}
+
+//newPage builds a page from up to limit+1 entries, newest first,
+//setting the cursor if there are more entries than the limit
+func newPage(entries []*Entry, limit int) *Page {
+	page := &Page{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = entries[limit-1].ID
+	}
+	return page
+}
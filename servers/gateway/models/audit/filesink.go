@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+//maxLineBytes is the longest line read from an audit log file
+const maxLineBytes = 1 << 20
+
+//FileSink appends the audit log to a file as JSON lines,
+//for deployments without a database to spare
+type FileSink struct {
+	path   string
+	file   *os.File
+	lastID int64
+	mx     sync.Mutex
+}
+
+//NewFileSink opens the audit log file at path, creating it if necessary
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %v", err)
+	}
+	fs := &FileSink{
+		path: path,
+		file: file,
+	}
+	//continue numbering entries from the last one in the file
+	err = fs.scan(func(entry *Entry) {
+		if entry.ID > fs.lastID {
+			fs.lastID = entry.ID
+		}
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return fs, nil
+}
+
+//Record appends the entry to the audit log, setting its ID
+func (fs *FileSink) Record(entry *Entry) error {
+	fs.mx.Lock()
+	defer fs.mx.Unlock()
+	entry.ID = fs.lastID + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding audit entry: %v", err)
+	}
+	if _, err := fs.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing audit entry: %v", err)
+	}
+	fs.lastID = entry.ID
+	return nil
+}
+
+//Query returns a page of entries matching the query, newest first.
+//The whole file is read, so this is only suitable for small logs.
+func (fs *FileSink) Query(q *Query) (*Page, error) {
+	fs.mx.Lock()
+	defer fs.mx.Unlock()
+	matches := []*Entry{}
+	err := fs.scan(func(entry *Entry) {
+		if q.Matches(entry) {
+			matches = append(matches, entry)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	limit := q.limit()
+	entries := make([]*Entry, 0, limit+1)
+	for i := len(matches) - 1; i >= 0 && len(entries) <= limit; i-- {
+		entries = append(entries, matches[i])
+	}
+	return newPage(entries, limit), nil
+}
+
+//Close closes the audit log file
+func (fs *FileSink) Close() error {
+	return fs.file.Close()
+}
+
+//scan calls fn with every entry in the file, oldest first
+func (fs *FileSink) scan(fn func(entry *Entry)) error {
+	if _, err := fs.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("reading audit log: %v", err)
+	}
+	scanner := bufio.NewScanner(fs.file)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineBytes)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("decoding audit entry: %v", err)
+		}
+		fn(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading audit log: %v", err)
+	}
+	return nil
+}
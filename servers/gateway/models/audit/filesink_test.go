@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Now()
+	entries := []*Entry{
+		{CreatedAt: start, ActorID: 1, TargetID: 1, Action: ActionSignIn, Outcome: OutcomeSuccess},
+		{CreatedAt: start.Add(time.Minute), TargetID: 2, Action: ActionSignIn, Outcome: OutcomeFailure},
+		{CreatedAt: start.Add(2 * time.Minute), ActorID: 1, TargetID: 1, Action: ActionProfileUpdated, Outcome: OutcomeSuccess},
+	}
+	for i, entry := range entries {
+		if err := sink.Record(entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entry.ID != int64(i+1) {
+			t.Errorf("expected entry ID %d but got %d", i+1, entry.ID)
+		}
+	}
+	sink.Close()
+
+	//reopening continues numbering where the file left off
+	sink, err = NewFileSink(path)
+	if err != nil {
+		t.Fatalf("unexpected error reopening: %v", err)
+	}
+	defer sink.Close()
+	entry := &Entry{CreatedAt: start.Add(3 * time.Minute), ActorID: 1, TargetID: 1, Action: ActionSignOut, Outcome: OutcomeSuccess}
+	if err := sink.Record(entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.ID != 4 {
+		t.Errorf("expected entry ID 4 after reopening but got %d", entry.ID)
+	}
+
+	since := start.Add(time.Minute)
+	cases := []struct {
+		name           string
+		query          *Query
+		expectedIDs    []int64
+		expectedCursor int64
+	}{
+		{
+			"Everything Newest First",
+			&Query{},
+			[]int64{4, 3, 2, 1},
+			0,
+		},
+		{
+			"By Actor",
+			&Query{ActorID: 1},
+			[]int64{4, 3, 1},
+			0,
+		},
+		{
+			"By Action And Outcome",
+			&Query{Action: ActionSignIn, Outcome: OutcomeFailure},
+			[]int64{2},
+			0,
+		},
+		{
+			"Since",
+			&Query{Since: &since},
+			[]int64{4, 3, 2},
+			0,
+		},
+		{
+			"First Page",
+			&Query{Limit: 2},
+			[]int64{4, 3},
+			3,
+		},
+		{
+			"Last Page",
+			&Query{Limit: 2, Before: 3},
+			[]int64{2, 1},
+			0,
+		},
+	}
+
+	for _, c := range cases {
+		page, err := sink.Query(c.query)
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		ids := []int64{}
+		for _, entry := range page.Entries {
+			ids = append(ids, entry.ID)
+		}
+		if len(ids) != len(c.expectedIDs) {
+			t.Errorf("case %s: expected entries %v but got %v", c.name, c.expectedIDs, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != c.expectedIDs[i] {
+				t.Errorf("case %s: expected entries %v but got %v", c.name, c.expectedIDs, ids)
+				break
+			}
+		}
+		if page.NextCursor != c.expectedCursor {
+			t.Errorf("case %s: expected cursor %d but got %d", c.name, c.expectedCursor, page.NextCursor)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+//MySQLSink writes the audit log to the audit_log table in MySQL
+type MySQLSink struct {
+	db *sql.DB
+}
+
+//NewMySQLSink constructs a new MySQLSink.
+func NewMySQLSink(db *sql.DB) *MySQLSink {
+	return &MySQLSink{
+		db: db,
+	}
+}
+
+//nullID converts a user ID to a nullable column value, as 0 means unknown
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+//Record appends the entry to the audit log, setting its ID
+func (s *MySQLSink) Record(entry *Entry) error {
+	insq := "insert into audit_log(createdat, actorid, action, targetid, ip, forwardedfor, useragent, outcome, detail) values (?,?,?,?,?,?,?,?,?)"
+	res, err := s.db.Exec(insq, entry.CreatedAt, nullID(entry.ActorID), entry.Action, nullID(entry.TargetID),
+		entry.IP, entry.ForwardedFor, entry.UserAgent, entry.Outcome, entry.Detail)
+	if err != nil {
+		return fmt.Errorf("Error executing insert: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("Error getting last id: %v", err)
+	}
+	entry.ID = id
+	return nil
+}
+
+//Query returns a page of entries matching the query, newest first
+func (s *MySQLSink) Query(q *Query) (*Page, error) {
+	where := []string{}
+	args := []interface{}{}
+	if q.ActorID != 0 {
+		where = append(where, "actorid = ?")
+		args = append(args, q.ActorID)
+	}
+	if q.TargetID != 0 {
+		where = append(where, "targetid = ?")
+		args = append(args, q.TargetID)
+	}
+	if q.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, q.Action)
+	}
+	if q.Outcome != "" {
+		where = append(where, "outcome = ?")
+		args = append(args, q.Outcome)
+	}
+	if q.Since != nil {
+		where = append(where, "createdat >= ?")
+		args = append(args, *q.Since)
+	}
+	if q.Until != nil {
+		where = append(where, "createdat < ?")
+		args = append(args, *q.Until)
+	}
+	if q.Before != 0 {
+		where = append(where, "id < ?")
+		args = append(args, q.Before)
+	}
+
+	query := "select id, createdat, actorid, action, targetid, ip, forwardedfor, useragent, outcome, detail from audit_log"
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+	}
+	query += " order by id desc limit ?"
+	limit := q.limit()
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying audit log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []*Entry{}
+	for rows.Next() {
+		entry := &Entry{}
+		var actorID, targetID sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.CreatedAt, &actorID, &entry.Action, &targetID,
+			&entry.IP, &entry.ForwardedFor, &entry.UserAgent, &entry.Outcome, &entry.Detail); err != nil {
+			return nil, fmt.Errorf("Error scanning audit entry: %v", err)
+		}
+		entry.ActorID = actorID.Int64
+		entry.TargetID = targetID.Int64
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading audit entries: %v", err)
+	}
+	return newPage(entries, limit), nil
+}
@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMySQLSinkQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "createdat", "actorid", "action", "targetid", "ip", "forwardedfor", "useragent", "outcome", "detail"}).
+		AddRow(5, now, 1, ActionSignIn, 1, "1.2.3.4", "", "test", OutcomeSuccess, "").
+		AddRow(4, now, nil, ActionSignIn, 1, "1.2.3.4", "5.6.7.8", "test", OutcomeFailure, "wrong password")
+	query := "select id, createdat, actorid, action, targetid, ip, forwardedfor, useragent, outcome, detail from audit_log " +
+		"where targetid = ? and action = ? and id < ? order by id desc limit ?"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(1, ActionSignIn, 10, 2).WillReturnRows(rows)
+
+	sink := NewMySQLSink(db)
+	page, err := sink.Query(&Query{TargetID: 1, Action: ActionSignIn, Before: 10, Limit: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].ID != 5 {
+		t.Errorf("expected only the newest entry, got %v", page.Entries)
+	}
+	if page.NextCursor != 5 {
+		t.Errorf("expected cursor 5 but got %d", page.NextCursor)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package blocks
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrBlockSelf is returned when a user tries to block themselves
+var ErrBlockSelf = errors.New("users can't block themselves")
+
+//Block represents one user blocking or muting another. Blocked users are
+//hidden from search, and their events aren't delivered. Muted users'
+//events aren't delivered, but they are otherwise visible.
+type Block struct {
+	UserID    int64     `json:"-"`
+	BlockedID int64     `json:"userID"`
+	Mute      bool      `json:"mute"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//NewBlock represents a request to block or mute a user
+type NewBlock struct {
+	UserID int64 `json:"userID"`
+	Mute   bool  `json:"mute"`
+}
+
+//Validate returns an error if the user with the given ID may not make the new block
+func (nb *NewBlock) Validate(userID int64) error {
+	if nb.UserID <= 0 {
+		return errors.New("userID must be a valid user ID")
+	}
+	if nb.UserID == userID {
+		return ErrBlockSelf
+	}
+	return nil
+}
+
+//ToBlock converts the NewBlock to a Block made by the user at time `now`
+func (nb *NewBlock) ToBlock(userID int64, now time.Time) (*Block, error) {
+	if err := nb.Validate(userID); err != nil {
+		return nil, err
+	}
+	return &Block{
+		UserID:    userID,
+		BlockedID: nb.UserID,
+		Mute:      nb.Mute,
+		CreatedAt: now,
+	}, nil
+}
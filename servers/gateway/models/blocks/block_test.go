@@ -0,0 +1,59 @@
+package blocks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToBlock(t *testing.T) {
+	cases := []struct {
+		name        string
+		newBlock    *NewBlock
+		userID      int64
+		expectError bool
+	}{
+		{
+			"Valid Block",
+			&NewBlock{UserID: 2},
+			1,
+			false,
+		},
+		{
+			"Valid Mute",
+			&NewBlock{UserID: 2, Mute: true},
+			1,
+			false,
+		},
+		{
+			"Block Self",
+			&NewBlock{UserID: 1},
+			1,
+			true,
+		},
+		{
+			"Missing User ID",
+			&NewBlock{},
+			1,
+			true,
+		},
+	}
+
+	now := time.Now()
+	for _, c := range cases {
+		block, err := c.newBlock.ToBlock(c.userID, now)
+		if c.expectError {
+			if err == nil {
+				t.Errorf("case %s: expected error but got nothing", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if block.UserID != c.userID || block.BlockedID != c.newBlock.UserID ||
+			block.Mute != c.newBlock.Mute || !block.CreatedAt.Equal(now) {
+			t.Errorf("case %s: block doesn't match new block: %+v", c.name, block)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package blocks
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+//MySQLStore represents a blocks.Store backed by MySQL
+type MySQLStore struct {
+	db *sql.DB
+}
+
+//NewMySQLStore constructs a new MySQLStore.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{
+		db: db,
+	}
+}
+
+//Insert saves the block, replacing any existing block
+//of the same user, and returns the saved Block
+func (s *MySQLStore) Insert(block *Block) (*Block, error) {
+	insq := "insert into blocks(userid, blockedid, mute, createdat) values (?,?,?,?) " +
+		"on duplicate key update mute = values(mute)"
+	if _, err := s.db.Exec(insq, block.UserID, block.BlockedID, block.Mute, block.CreatedAt); err != nil {
+		return nil, fmt.Errorf("Error executing insert: %v", err)
+	}
+	return block, nil
+}
+
+//GetAll returns every block made by the user, newest first
+func (s *MySQLStore) GetAll(userID int64) ([]*Block, error) {
+	query := "select userid, blockedid, mute, createdat from blocks where userid = ? order by createdat desc"
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting blocks: %v", err)
+	}
+	defer rows.Close()
+
+	blocks := []*Block{}
+	for rows.Next() {
+		block := &Block{}
+		if err := rows.Scan(&block.UserID, &block.BlockedID, &block.Mute, &block.CreatedAt); err != nil {
+			return nil, fmt.Errorf("Error scanning blocks: %v", err)
+		}
+		blocks = append(blocks, block)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error getting next row: %v", err)
+	}
+	return blocks, nil
+}
+
+//Delete removes the user's block of the blocked user
+func (s *MySQLStore) Delete(userID int64, blockedID int64) error {
+	res, err := s.db.Exec("delete from blocks where userid = ? and blockedid = ?", userID, blockedID)
+	if err != nil {
+		return fmt.Errorf("Error deleting block: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %v", err)
+	}
+	if affected == 0 {
+		return ErrBlockNotFound
+	}
+	return nil
+}
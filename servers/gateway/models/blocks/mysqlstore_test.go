@@ -0,0 +1,67 @@
+package blocks
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+const sqlGetAll = "select userid, blockedid, mute, createdat from blocks where userid = ? order by createdat desc"
+const sqlDelete = "delete from blocks where userid = ? and blockedid = ?"
+
+func TestGetAll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	expected := []*Block{
+		{UserID: 1, BlockedID: 3, Mute: true, CreatedAt: now},
+		{UserID: 1, BlockedID: 2, Mute: false, CreatedAt: now.Add(-time.Hour)},
+	}
+	rows := sqlmock.NewRows([]string{"userid", "blockedid", "mute", "createdat"})
+	for _, block := range expected {
+		rows.AddRow(block.UserID, block.BlockedID, block.Mute, block.CreatedAt)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetAll)).WithArgs(1).WillReturnRows(rows)
+
+	store := NewMySQLStore(db)
+	blocks, err := store.GetAll(1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("returned blocks not equal to expected blocks")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewMySQLStore(db)
+	mock.ExpectExec(regexp.QuoteMeta(sqlDelete)).WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := store.Delete(1, 2); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlDelete)).WithArgs(1, 5).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := store.Delete(1, 5); err != ErrBlockNotFound {
+		t.Errorf("expected error %v but got %v", ErrBlockNotFound, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package blocks
+
+import "errors"
+
+//ErrBlockNotFound is returned when the block can't be found
+var ErrBlockNotFound = errors.New("block not found")
+
+//Store represents a store for Blocks
+type Store interface {
+	//Insert saves the block, replacing any existing block
+	//of the same user, and returns the saved Block
+	Insert(block *Block) (*Block, error)
+
+	//GetAll returns every block made by the user, newest first
+	GetAll(userID int64) ([]*Block, error)
+
+	//Delete removes the user's block of the blocked user
+	Delete(userID int64, blockedID int64) error
+}
@@ -0,0 +1,78 @@
+package channels
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+)
+
+//Channel represents a channel of the messaging service, as much of it as
+//channel search needs. The messaging service owns the channel table.
+type Channel struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Private     bool       `json:"private"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	EditedAt    *time.Time `json:"editedAt"`
+}
+
+//SearchFields returns the fields the channel is found by in the search index
+func (c *Channel) SearchFields() []string {
+	return []string{c.Name, c.Description}
+}
+
+//Relevance ranks of a channel search result, most relevant first
+const (
+	rankExactName = iota
+	rankNamePrefix
+	rankDescription
+)
+
+//SortByRelevance orders channel search results for the query: channels whose
+//name is the query come first, then channels whose names start with every
+//term of the query, then channels only found by their description. Results
+//keep the order they were found in within each rank.
+func SortByRelevance(channels []*Channel, query string) {
+	terms := indexes.Analyze(query)
+	ranks := make(map[*Channel]int, len(channels))
+	for _, c := range channels {
+		ranks[c] = rankChannel(c, terms)
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return ranks[channels[i]] < ranks[channels[j]]
+	})
+}
+
+//rankChannel returns the relevance rank of the channel for the analyzed query terms
+func rankChannel(c *Channel, terms []string) int {
+	name := indexes.Analyze(c.Name)
+	switch {
+	case len(terms) > 0 && reflect.DeepEqual(name, terms):
+		return rankExactName
+	case len(terms) > 0 && prefixesAll(name, terms):
+		return rankNamePrefix
+	default:
+		return rankDescription
+	}
+}
+
+//prefixesAll returns true if every term is the prefix of one of the keys
+func prefixesAll(keys []string, terms []string) bool {
+	for _, term := range terms {
+		found := false
+		for _, key := range keys {
+			if strings.HasPrefix(key, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
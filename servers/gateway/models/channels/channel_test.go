@@ -0,0 +1,51 @@
+package channels
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortByRelevance(t *testing.T) {
+	general := &Channel{ID: 1, Name: "General"}
+	generalChat := &Channel{ID: 2, Name: "General Chat"}
+	random := &Channel{ID: 3, Name: "Random", Description: "General nonsense"}
+	gophers := &Channel{ID: 4, Name: "Gophers", Description: "General gopher chat"}
+
+	cases := []struct {
+		name     string
+		channels []*Channel
+		query    string
+		expected []int64
+	}{
+		{
+			"Exact Name First",
+			[]*Channel{random, generalChat, general},
+			"general",
+			[]int64{1, 2, 3},
+		},
+		{
+			"Name Prefix Before Description",
+			[]*Channel{gophers, random, generalChat},
+			"gen ch",
+			[]int64{2, 4, 3},
+		},
+		{
+			"Found Order Kept Within Rank",
+			[]*Channel{gophers, random},
+			"gen",
+			[]int64{4, 3},
+		},
+	}
+
+	for _, c := range cases {
+		found := append([]*Channel{}, c.channels...)
+		SortByRelevance(found, c.query)
+		ids := []int64{}
+		for _, ch := range found {
+			ids = append(ids, ch.ID)
+		}
+		if !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, ids)
+		}
+	}
+}
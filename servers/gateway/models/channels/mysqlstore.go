@@ -0,0 +1,109 @@
+package channels
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+)
+
+//channelColumns are the columns scanned into a Channel, in order
+const channelColumns = "c.id, c.channelname, c.channeldescription, c.channelprivate, c.createdat, c.editedat"
+
+//sqlVisible selects the channels a user may see, checking membership
+//in the database so it is current however members were changed
+const sqlVisible = "select " + channelColumns + " from channel c " +
+	"where (c.channelprivate = false or exists " +
+	"(select 1 from channel_users cu where cu.channelid = c.id and cu.usersid = ?)) " +
+	"and c.id in "
+
+//MySQLStore represents a channels.Store backed by the channel table in MySQL
+type MySQLStore struct {
+	db *sql.DB
+}
+
+//NewMySQLStore constructs a new MySQLStore.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{
+		db: db,
+	}
+}
+
+//LoadChannels upserts every channel into the index
+func (s *MySQLStore) LoadChannels(index indexes.Index) error {
+	rows, err := s.db.Query("select " + channelColumns + " from channel c")
+	if err != nil {
+		return fmt.Errorf("Error loading channels for search: %v", err)
+	}
+	defer rows.Close()
+
+	channels, err := scanChannels(rows)
+	if err != nil {
+		return err
+	}
+	for _, c := range channels {
+		index.Upsert(c.ID, c.SearchFields())
+	}
+	return nil
+}
+
+//GetVisible gets the channels with the found IDs that the user may see,
+//which are every public channel and the private channels they are a
+//member of, keeping the order the IDs were found in
+func (s *MySQLStore) GetVisible(found []int64, userID int64) ([]*Channel, error) {
+	if len(found) == 0 {
+		return []*Channel{}, nil
+	}
+	args := []interface{}{userID}
+	for _, id := range found {
+		args = append(args, id)
+	}
+	query := sqlVisible + "(?" + strings.Repeat(",?", len(found)-1) + ")"
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting search channels: %v", err)
+	}
+	defer rows.Close()
+
+	channels, err := scanChannels(rows)
+	if err != nil {
+		return nil, err
+	}
+	return orderByIDs(channels, found), nil
+}
+
+//scanChannels scans every row into a Channel
+func scanChannels(rows *sql.Rows) ([]*Channel, error) {
+	channels := []*Channel{}
+	for rows.Next() {
+		c := &Channel{}
+		var description sql.NullString
+		if err := rows.Scan(&c.ID, &c.Name, &description, &c.Private, &c.CreatedAt, &c.EditedAt); err != nil {
+			return nil, fmt.Errorf("Error scanning channels: %v", err)
+		}
+		c.Description = description.String
+		channels = append(channels, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error getting next row: %v", err)
+	}
+	return channels, nil
+}
+
+//orderByIDs returns the channels in the order of the IDs, once each,
+//leaving out IDs that have no channel
+func orderByIDs(channels []*Channel, ids []int64) []*Channel {
+	byID := make(map[int64]*Channel, len(channels))
+	for _, c := range channels {
+		byID[c.ID] = c
+	}
+	ordered := make([]*Channel, 0, len(channels))
+	for _, id := range ids {
+		if c, ok := byID[id]; ok {
+			ordered = append(ordered, c)
+			delete(byID, id)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,115 @@
+package channels
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var columns = []string{"id", "channelname", "channeldescription", "channelprivate", "createdat", "editedat"}
+
+func TestLoadChannels(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta("select " + channelColumns + " from channel c")).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "general", "Talk about anything", false, now, nil).
+			AddRow(2, "gophers", nil, true, now, now))
+
+	index := indexes.NewTrie()
+	if err := NewMySQLStore(db).LoadChannels(index); err != nil {
+		t.Fatalf("unexpected error loading channels: %v", err)
+	}
+	if found := index.FindAll([]string{"talk", "any"}, 5, nil); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("expected channel 1 to be found by its description but got %v", found)
+	}
+	if found := index.Find("goph", 5); !reflect.DeepEqual(found, []int64{2}) {
+		t.Errorf("expected channel 2 to be found by its name but got %v", found)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("select " + channelColumns + " from channel c")).
+		WillReturnError(errors.New("query failed"))
+	if err := NewMySQLStore(db).LoadChannels(index); err == nil {
+		t.Error("expected an error when the query fails")
+	}
+}
+
+func TestGetVisible(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name        string
+		found       []int64
+		expect      func(mock sqlmock.Sqlmock)
+		expected    []int64
+		expectError bool
+	}{
+		{
+			"Found Order Kept",
+			[]int64{3, 1, 2},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(sqlVisible+"(?,?,?)")).
+					WithArgs(7, 3, 1, 2).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "general", "", false, now, nil).
+						AddRow(3, "gophers", "", true, now, nil))
+			},
+			[]int64{3, 1},
+			false,
+		},
+		{
+			"Nothing Found",
+			nil,
+			func(mock sqlmock.Sqlmock) {},
+			[]int64{},
+			false,
+		},
+		{
+			"Query Error",
+			[]int64{1},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(sqlVisible + "(?)")).
+					WillReturnError(errors.New("query failed"))
+			},
+			nil,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error creating sql mock: %v", err)
+		}
+		c.expect(mock)
+		visible, err := NewMySQLStore(db).GetVisible(c.found, 7)
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: expected error %t but got %v", c.name, c.expectError, err)
+		}
+		if !c.expectError {
+			ids := []int64{}
+			for _, ch := range visible {
+				ids = append(ids, ch.ID)
+			}
+			if !reflect.DeepEqual(ids, c.expected) {
+				t.Errorf("case %s: expected %v but got %v", c.name, c.expected, ids)
+			}
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("case %s: unmet sqlmock expectations: %v", c.name, err)
+		}
+		db.Close()
+	}
+}
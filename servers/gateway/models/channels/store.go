@@ -0,0 +1,14 @@
+package channels
+
+import "github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+
+//Store represents a read only store of channels
+type Store interface {
+	//LoadChannels upserts every channel into the index
+	LoadChannels(index indexes.Index) error
+
+	//GetVisible gets the channels with the found IDs that the user may see,
+	//which are every public channel and the private channels they are a
+	//member of, keeping the order the IDs were found in
+	GetVisible(found []int64, userID int64) ([]*Channel, error)
+}
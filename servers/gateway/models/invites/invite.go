@@ -0,0 +1,107 @@
+package invites
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//codeLength is the number of random bytes in an invite code
+const codeLength = 18
+
+//DefaultDuration is how long an invite lasts when no expiry is given
+const DefaultDuration = 7 * 24 * time.Hour
+
+//MaxDuration is the longest an invite may last
+const MaxDuration = 30 * 24 * time.Hour
+
+//ErrInviteExpired is returned when an invite code has expired
+var ErrInviteExpired = errors.New("invite code has expired")
+
+//ErrInviteUsedUp is returned when an invite code has no uses left
+var ErrInviteUsedUp = errors.New("invite code has already been used")
+
+//Invite represents an invite code that allows new users to sign up
+type Invite struct {
+	ID        int64  `json:"id"`
+	Code      string `json:"code"`
+	CreatorID int64  `json:"creatorID"`
+	//MaxUses is the number of users that may sign up with the code
+	MaxUses int `json:"maxUses"`
+	Uses    int `json:"uses"`
+	//ChannelIDs are the channels new users automatically join
+	ChannelIDs []int64   `json:"channelIDs"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+//NewInvite represents a request to create an invite
+type NewInvite struct {
+	MaxUses    int        `json:"maxUses"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	ChannelIDs []int64    `json:"channelIDs"`
+}
+
+//Validate validates the new invite and returns an error if
+//any of the validation rules fail, or nil if its valid
+func (ni *NewInvite) Validate(now time.Time) error {
+	if ni.MaxUses < 0 {
+		return fmt.Errorf("Max uses must not be negative")
+	}
+	if ni.ExpiresAt != nil {
+		if !ni.ExpiresAt.After(now) {
+			return fmt.Errorf("Expiry must be in the future")
+		}
+		if ni.ExpiresAt.Sub(now) > MaxDuration {
+			return fmt.Errorf("Expiry must be within %v", MaxDuration)
+		}
+	}
+	return nil
+}
+
+//ToInvite converts the NewInvite to an Invite created by `creatorID`,
+//generating a random code. A zero MaxUses makes a single-use invite
+//and a missing ExpiresAt expires after DefaultDuration.
+func (ni *NewInvite) ToInvite(creatorID int64, now time.Time) (*Invite, error) {
+	if err := ni.Validate(now); err != nil {
+		return nil, err
+	}
+
+	randomBytes := make([]byte, codeLength)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return nil, fmt.Errorf("Error generating invite code: %v", err)
+	}
+
+	invite := &Invite{
+		Code:       base64.URLEncoding.EncodeToString(randomBytes),
+		CreatorID:  creatorID,
+		MaxUses:    ni.MaxUses,
+		ChannelIDs: ni.ChannelIDs,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(DefaultDuration),
+	}
+	if invite.MaxUses == 0 {
+		invite.MaxUses = 1
+	}
+	if ni.ExpiresAt != nil {
+		invite.ExpiresAt = *ni.ExpiresAt
+	}
+	if invite.ChannelIDs == nil {
+		invite.ChannelIDs = []int64{}
+	}
+	return invite, nil
+}
+
+//CheckUsable returns ErrInviteExpired or ErrInviteUsedUp if the
+//invite can't be used at time `now`, or nil if it can
+func (i *Invite) CheckUsable(now time.Time) error {
+	if !now.Before(i.ExpiresAt) {
+		return ErrInviteExpired
+	}
+	if i.Uses >= i.MaxUses {
+		return ErrInviteUsedUp
+	}
+	return nil
+}
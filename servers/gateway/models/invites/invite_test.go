@@ -0,0 +1,121 @@
+package invites
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToInvite(t *testing.T) {
+	now := time.Now()
+	tomorrow := now.Add(24 * time.Hour)
+	past := now.Add(-time.Hour)
+	tooLate := now.Add(MaxDuration + time.Hour)
+
+	cases := []struct {
+		name              string
+		ni                *NewInvite
+		expectError       bool
+		expectedMaxUses   int
+		expectedExpiresAt time.Time
+	}{
+		{
+			"Valid Defaults",
+			&NewInvite{},
+			false,
+			1,
+			now.Add(DefaultDuration),
+		},
+		{
+			"Valid Multi-use Invite",
+			&NewInvite{
+				MaxUses:    10,
+				ExpiresAt:  &tomorrow,
+				ChannelIDs: []int64{1, 2},
+			},
+			false,
+			10,
+			tomorrow,
+		},
+		{
+			"Invalid Negative Max Uses",
+			&NewInvite{MaxUses: -1},
+			true,
+			0,
+			time.Time{},
+		},
+		{
+			"Invalid Expiry In The Past",
+			&NewInvite{ExpiresAt: &past},
+			true,
+			0,
+			time.Time{},
+		},
+		{
+			"Invalid Expiry Too Far Away",
+			&NewInvite{ExpiresAt: &tooLate},
+			true,
+			0,
+			time.Time{},
+		},
+	}
+
+	for _, c := range cases {
+		invite, err := c.ni.ToInvite(1, now)
+		switch {
+		case c.expectError && err == nil:
+			t.Errorf("case %s: expected error but didn't get any", c.name)
+		case !c.expectError && err != nil:
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+		case !c.expectError:
+			if len(invite.Code) == 0 {
+				t.Errorf("case %s: expected a generated code", c.name)
+			}
+			if invite.MaxUses != c.expectedMaxUses {
+				t.Errorf("case %s: incorrect max uses: expected %d but got %d", c.name, c.expectedMaxUses, invite.MaxUses)
+			}
+			if !invite.ExpiresAt.Equal(c.expectedExpiresAt) {
+				t.Errorf("case %s: incorrect expiry: expected %v but got %v", c.name, c.expectedExpiresAt, invite.ExpiresAt)
+			}
+			if invite.CreatorID != 1 {
+				t.Errorf("case %s: incorrect creator: expected 1 but got %d", c.name, invite.CreatorID)
+			}
+		}
+	}
+
+	first, _ := (&NewInvite{}).ToInvite(1, now)
+	second, _ := (&NewInvite{}).ToInvite(1, now)
+	if first.Code == second.Code {
+		t.Errorf("expected unique invite codes but got %s twice", first.Code)
+	}
+}
+
+func TestCheckUsable(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name          string
+		invite        *Invite
+		expectedError error
+	}{
+		{
+			"Usable Invite",
+			&Invite{MaxUses: 2, Uses: 1, ExpiresAt: now.Add(time.Hour)},
+			nil,
+		},
+		{
+			"Expired Invite",
+			&Invite{MaxUses: 2, Uses: 0, ExpiresAt: now},
+			ErrInviteExpired,
+		},
+		{
+			"Used Up Invite",
+			&Invite{MaxUses: 1, Uses: 1, ExpiresAt: now.Add(time.Hour)},
+			ErrInviteUsedUp,
+		},
+	}
+
+	for _, c := range cases {
+		if err := c.invite.CheckUsable(now); err != c.expectedError {
+			t.Errorf("case %s: expected error %v but got %v", c.name, c.expectedError, err)
+		}
+	}
+}
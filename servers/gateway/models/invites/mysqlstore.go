@@ -0,0 +1,212 @@
+package invites
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+//inviteColumns are the columns selected for every invite query
+const inviteColumns = "id, code, creatorid, maxuses, uses, createdat, expiresat"
+
+//MySQLStore represents an invites.Store backed by MySQL
+type MySQLStore struct {
+	db *sql.DB
+}
+
+//NewMySQLStore constructs a new MySQLStore.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{
+		db: db,
+	}
+}
+
+//Insert inserts the invite into the database, and returns
+//the newly-inserted Invite, complete with the DBMS-assigned ID
+func (s *MySQLStore) Insert(invite *Invite) (*Invite, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("Error beginning transaction: %v", err)
+	}
+
+	insq := "insert into invites(code, creatorid, maxuses, uses, createdat, expiresat) values (?,?,?,?,?,?)"
+	res, err := tx.Exec(insq, invite.Code, invite.CreatorID, invite.MaxUses, invite.Uses, invite.CreatedAt, invite.ExpiresAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Error executing insert: %v", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Error getting last id: %v", err)
+	}
+
+	chanq := "insert into invites_channels(inviteid, channelid) values (?,?)"
+	for _, channelID := range invite.ChannelIDs {
+		if _, err := tx.Exec(chanq, id, channelID); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("Error inserting invite channel: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Error committing transaction: %v", err)
+	}
+
+	invite.ID = id
+	return invite, nil
+}
+
+//GetByCode returns the Invite with the given code
+func (s *MySQLStore) GetByCode(code string) (*Invite, error) {
+	query := "select " + inviteColumns + " from invites where code = ?"
+	invite := &Invite{}
+	err := scanInvite(s.db.QueryRow(query, code), invite)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, ErrInviteNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	channelIDs, err := s.getChannelIDs(invite.ID)
+	if err != nil {
+		return nil, err
+	}
+	invite.ChannelIDs = channelIDs
+	return invite, nil
+}
+
+//GetAll returns every invite, newest first
+func (s *MySQLStore) GetAll() ([]*Invite, error) {
+	query := "select " + inviteColumns + " from invites order by createdat desc"
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting invites: %v", err)
+	}
+	defer rows.Close()
+
+	invites := []*Invite{}
+	for rows.Next() {
+		invite := &Invite{}
+		if err := scanInvite(rows, invite); err != nil {
+			return nil, fmt.Errorf("Error scanning invites: %v", err)
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error getting next row: %v", err)
+	}
+
+	for _, invite := range invites {
+		if invite.ChannelIDs, err = s.getChannelIDs(invite.ID); err != nil {
+			return nil, err
+		}
+	}
+	return invites, nil
+}
+
+//Redeem uses up one use of the invite if it is still usable at
+//time `now`, and returns the updated Invite
+func (s *MySQLStore) Redeem(code string, now time.Time) (*Invite, error) {
+	updateq := "update invites set uses = uses + 1 where code = ? and uses < maxuses and expiresat > ?"
+	res, err := s.db.Exec(updateq, code, now)
+	if err != nil {
+		return nil, fmt.Errorf("Error redeeming invite: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("getting rows affected: %v", err)
+	}
+
+	invite, err := s.GetByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		if err := invite.CheckUsable(now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInviteUsedUp
+	}
+	return invite, nil
+}
+
+//Release gives back a use of the invite taken by Redeem
+func (s *MySQLStore) Release(code string) error {
+	updateq := "update invites set uses = uses - 1 where code = ? and uses > 0"
+	if _, err := s.db.Exec(updateq, code); err != nil {
+		return fmt.Errorf("Error releasing invite: %v", err)
+	}
+	return nil
+}
+
+//Delete deletes the invite with the given code
+func (s *MySQLStore) Delete(code string) error {
+	invite, err := s.GetByCode(code)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error beginning transaction: %v", err)
+	}
+	if _, err := tx.Exec("delete from invites_channels where inviteid = ?", invite.ID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error deleting invite channels: %v", err)
+	}
+	if _, err := tx.Exec("delete from invites where id = ?", invite.ID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error deleting invite: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Error committing transaction: %v", err)
+	}
+	return nil
+}
+
+//JoinChannels adds the user to the given channels
+func (s *MySQLStore) JoinChannels(userID int64, channelIDs []int64) error {
+	insq := "insert ignore into channel_users(channelid, usersid) values (?,?)"
+	for _, channelID := range channelIDs {
+		if _, err := s.db.Exec(insq, channelID, userID); err != nil {
+			return fmt.Errorf("Error joining channel %d: %v", channelID, err)
+		}
+	}
+	return nil
+}
+
+//getChannelIDs returns the IDs of the channels attached to an invite
+func (s *MySQLStore) getChannelIDs(inviteID int64) ([]int64, error) {
+	rows, err := s.db.Query("select channelid from invites_channels where inviteid = ?", inviteID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting invite channels: %v", err)
+	}
+	defer rows.Close()
+
+	channelIDs := []int64{}
+	for rows.Next() {
+		var channelID int64
+		if err := rows.Scan(&channelID); err != nil {
+			return nil, fmt.Errorf("Error scanning invite channels: %v", err)
+		}
+		channelIDs = append(channelIDs, channelID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error getting next row: %v", err)
+	}
+	return channelIDs, nil
+}
+
+//rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//scanInvite scans the columns listed in inviteColumns into the invite
+func scanInvite(row rowScanner, invite *Invite) error {
+	return row.Scan(&invite.ID, &invite.Code, &invite.CreatorID, &invite.MaxUses,
+		&invite.Uses, &invite.CreatedAt, &invite.ExpiresAt)
+}
@@ -0,0 +1,112 @@
+package invites
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+const sqlGetByCode = "select id, code, creatorid, maxuses, uses, createdat, expiresat from invites where code = ?"
+const sqlGetChannels = "select channelid from invites_channels where inviteid = ?"
+const sqlRedeem = "update invites set uses = uses + 1 where code = ? and uses < maxuses and expiresat > ?"
+
+func createInviteRows(invite *Invite) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "code", "creatorid", "maxuses", "uses", "createdat", "expiresat"})
+	rows.AddRow(invite.ID, invite.Code, invite.CreatorID, invite.MaxUses, invite.Uses, invite.CreatedAt, invite.ExpiresAt)
+	return rows
+}
+
+func createChannelRows(channelIDs []int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"channelid"})
+	for _, id := range channelIDs {
+		rows.AddRow(id)
+	}
+	return rows
+}
+
+func TestGetByCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	expected := &Invite{
+		ID:         1,
+		Code:       "abc",
+		CreatorID:  2,
+		MaxUses:    5,
+		Uses:       1,
+		ChannelIDs: []int64{1, 3},
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetByCode)).WithArgs("abc").WillReturnRows(createInviteRows(expected))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetChannels)).WithArgs(1).WillReturnRows(createChannelRows(expected.ChannelIDs))
+
+	store := NewMySQLStore(db)
+	invite, err := store.GetByCode("abc")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(invite, expected) {
+		t.Errorf("returned invite not equal to expected invite")
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetByCode)).WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "creatorid", "maxuses", "uses", "createdat", "expiresat"}))
+	if _, err = store.GetByCode("missing"); err != ErrInviteNotFound {
+		t.Errorf("expected error %v but got %v", ErrInviteNotFound, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	usedUp := &Invite{
+		ID:         1,
+		Code:       "abc",
+		CreatorID:  2,
+		MaxUses:    1,
+		Uses:       1,
+		ChannelIDs: []int64{},
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlRedeem)).WithArgs("abc", now).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetByCode)).WithArgs("abc").WillReturnRows(createInviteRows(usedUp))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetChannels)).WithArgs(1).WillReturnRows(createChannelRows(nil))
+
+	store := NewMySQLStore(db)
+	invite, err := store.Redeem("abc", now)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(invite, usedUp) {
+		t.Errorf("returned invite not equal to expected invite")
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlRedeem)).WithArgs("abc", now).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetByCode)).WithArgs("abc").WillReturnRows(createInviteRows(usedUp))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetChannels)).WithArgs(1).WillReturnRows(createChannelRows(nil))
+	if _, err = store.Redeem("abc", now); err != ErrInviteUsedUp {
+		t.Errorf("expected error %v but got %v", ErrInviteUsedUp, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
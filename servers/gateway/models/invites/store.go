@@ -0,0 +1,35 @@
+package invites
+
+import (
+	"errors"
+	"time"
+)
+
+//ErrInviteNotFound is returned when the invite can't be found
+var ErrInviteNotFound = errors.New("invite not found")
+
+//Store represents a store for Invites
+type Store interface {
+	//Insert inserts the invite into the database, and returns
+	//the newly-inserted Invite, complete with the DBMS-assigned ID
+	Insert(invite *Invite) (*Invite, error)
+
+	//GetByCode returns the Invite with the given code
+	GetByCode(code string) (*Invite, error)
+
+	//GetAll returns every invite, newest first
+	GetAll() ([]*Invite, error)
+
+	//Redeem uses up one use of the invite if it is still usable at
+	//time `now`, and returns the updated Invite
+	Redeem(code string, now time.Time) (*Invite, error)
+
+	//Release gives back a use of the invite taken by Redeem
+	Release(code string) error
+
+	//Delete deletes the invite with the given code
+	Delete(code string) error
+
+	//JoinChannels adds the user to the given channels
+	JoinChannels(userID int64, channelIDs []int64) error
+}
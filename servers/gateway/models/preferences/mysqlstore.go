@@ -0,0 +1,108 @@
+package preferences
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//MySQLStore represents a preferences.Store backed by MySQL,
+//which keeps each user's preferences as a JSON document
+type MySQLStore struct {
+	db *sql.DB
+}
+
+//NewMySQLStore constructs a new MySQLStore.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{
+		db: db,
+	}
+}
+
+//Get returns the user's preferences, or the defaults
+//if the user hasn't saved any
+func (s *MySQLStore) Get(userID int64) (*Preferences, error) {
+	var doc []byte
+	err := s.db.QueryRow("select doc from preferences where userid = ?", userID).Scan(&doc)
+	switch {
+	case err == sql.ErrNoRows:
+		return Defaults(), nil
+	case err != nil:
+		return nil, fmt.Errorf("Error getting preferences: %v", err)
+	}
+
+	return decode(doc)
+}
+
+//decode decodes a preferences document over the defaults
+func decode(doc []byte) (*Preferences, error) {
+	prefs := Defaults()
+	if err := json.Unmarshal(doc, prefs); err != nil {
+		return nil, fmt.Errorf("Error decoding preferences: %v", err)
+	}
+	return prefs, nil
+}
+
+//Save saves the user's preferences
+func (s *MySQLStore) Save(userID int64, prefs *Preferences) error {
+	doc, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("Error encoding preferences: %v", err)
+	}
+	insq := "insert into preferences(userid, doc, updatedat) values (?,?,?) " +
+		"on duplicate key update doc = values(doc), updatedat = values(updatedat)"
+	if _, err := s.db.Exec(insq, userID, doc, time.Now()); err != nil {
+		return fmt.Errorf("Error saving preferences: %v", err)
+	}
+	return nil
+}
+
+//Patch applies a JSON merge patch to the user's preferences and saves them.
+//The user's row is locked while the patch is applied, so patches made
+//at the same time are applied one after another.
+func (s *MySQLStore) Patch(userID int64, patch []byte) (*Preferences, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("Error beginning transaction: %v", err)
+	}
+
+	//make sure there is a row to lock for users without saved preferences
+	insq := "insert ignore into preferences(userid, doc, updatedat) values (?,?,?)"
+	if _, err := tx.Exec(insq, userID, []byte("{}"), time.Now()); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Error inserting preferences: %v", err)
+	}
+
+	var doc []byte
+	if err := tx.QueryRow("select doc from preferences where userid = ? for update", userID).Scan(&doc); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Error getting preferences: %v", err)
+	}
+	prefs, err := decode(doc)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	patched, err := prefs.ApplyPatch(patch)
+	if err != nil {
+		tx.Rollback()
+		return nil, &InvalidPatchError{err}
+	}
+	patchedDoc, err := json.Marshal(patched)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Error encoding preferences: %v", err)
+	}
+	updq := "update preferences set doc = ?, updatedat = ? where userid = ?"
+	if _, err := tx.Exec(updq, patchedDoc, time.Now(), userID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("Error saving preferences: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Error committing transaction: %v", err)
+	}
+	return patched, nil
+}
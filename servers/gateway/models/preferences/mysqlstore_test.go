@@ -0,0 +1,140 @@
+package preferences
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+const sqlGetPreferences = "select doc from preferences where userid = ?"
+const sqlSavePreferences = "insert into preferences(userid, doc, updatedat) values (?,?,?) " +
+	"on duplicate key update doc = values(doc), updatedat = values(updatedat)"
+const sqlInsertEmptyPreferences = "insert ignore into preferences(userid, doc, updatedat) values (?,?,?)"
+const sqlLockPreferences = "select doc from preferences where userid = ? for update"
+const sqlUpdatePreferences = "update preferences set doc = ?, updatedat = ? where userid = ?"
+
+func TestMySQLStoreGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewMySQLStore(db)
+
+	rows := sqlmock.NewRows([]string{"doc"}).AddRow([]byte(`{"theme":"dark","mutedChannels":[3]}`))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetPreferences)).WithArgs(1).WillReturnRows(rows)
+	expected := Defaults()
+	expected.Theme = ThemeDark
+	expected.MutedChannels = []int64{3}
+	prefs, err := store.Get(1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(prefs, expected) {
+		t.Errorf("expected %+v but got %+v", expected, prefs)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetPreferences)).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"doc"}))
+	prefs, err = store.Get(2)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(prefs, Defaults()) {
+		t.Errorf("expected defaults for user without preferences but got %+v", prefs)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetPreferences)).WithArgs(3).WillReturnError(fmt.Errorf("some error"))
+	if _, err = store.Get(3); err == nil {
+		t.Errorf("expected error but got nothing")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestMySQLStoreSave(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewMySQLStore(db)
+	prefs := Defaults()
+	doc := `{"theme":"system","defaultNotifications":"all","channelNotifications":{},"mutedChannels":[],"emailDigest":"never"}`
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlSavePreferences)).WithArgs(1, []byte(doc), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := store.Save(1, prefs); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlSavePreferences)).WithArgs(2, []byte(doc), sqlmock.AnyArg()).
+		WillReturnError(fmt.Errorf("some error"))
+	if err := store.Save(2, prefs); err == nil {
+		t.Errorf("expected error but got nothing")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestMySQLStorePatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	store := NewMySQLStore(db)
+	expectLock := func(userID int64, doc string) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(sqlInsertEmptyPreferences)).WithArgs(userID, []byte("{}"), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(sqlLockPreferences)).WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"doc"}).AddRow([]byte(doc)))
+	}
+
+	//the patch is applied to the locked preferences
+	expectLock(1, `{"theme":"dark","mutedChannels":[3]}`)
+	doc := `{"theme":"dark","defaultNotifications":"all","channelNotifications":{},"mutedChannels":[3,4],"emailDigest":"never"}`
+	mock.ExpectExec(regexp.QuoteMeta(sqlUpdatePreferences)).WithArgs([]byte(doc), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expected := Defaults()
+	expected.Theme = ThemeDark
+	expected.MutedChannels = []int64{3, 4}
+	prefs, err := store.Patch(1, []byte(`{"mutedChannels":[3,4]}`))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(prefs, expected) {
+		t.Errorf("expected %+v but got %+v", expected, prefs)
+	}
+
+	//invalid patches roll back
+	expectLock(2, `{}`)
+	mock.ExpectRollback()
+	if _, err := store.Patch(2, []byte(`{"theme":"purple"}`)); err == nil {
+		t.Errorf("expected error but got nothing")
+	} else if _, ok := err.(*InvalidPatchError); !ok {
+		t.Errorf("expected an InvalidPatchError but got %T: %v", err, err)
+	}
+
+	//database errors aren't reported as invalid patches
+	expectLock(3, `{}`)
+	mock.ExpectExec(regexp.QuoteMeta(sqlUpdatePreferences)).WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
+	if _, err := store.Patch(3, []byte(`{"theme":"light"}`)); err == nil {
+		t.Errorf("expected error but got nothing")
+	} else if _, ok := err.(*InvalidPatchError); ok {
+		t.Errorf("expected a database error but got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
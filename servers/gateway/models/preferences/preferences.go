@@ -0,0 +1,167 @@
+package preferences
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+//Themes the web client can be shown in
+const (
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+//Notification levels for a channel
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
+)
+
+//Email digest frequencies
+const (
+	DigestNever  = "never"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+//MaxChannels is the most channels that may have their own
+//notification level, or be muted
+const MaxChannels = 1000
+
+//Preferences holds a user's client settings, so they follow the user between devices
+type Preferences struct {
+	Theme string `json:"theme"`
+	//DefaultNotifications is the notification level for channels
+	//that aren't in ChannelNotifications
+	DefaultNotifications string `json:"defaultNotifications"`
+	//ChannelNotifications maps channel IDs to their notification level
+	ChannelNotifications map[string]string `json:"channelNotifications"`
+	MutedChannels        []int64           `json:"mutedChannels"`
+	EmailDigest          string            `json:"emailDigest"`
+}
+
+//Defaults returns the preferences of a user who hasn't changed any
+func Defaults() *Preferences {
+	return &Preferences{
+		Theme:                ThemeSystem,
+		DefaultNotifications: NotifyAll,
+		ChannelNotifications: map[string]string{},
+		MutedChannels:        []int64{},
+		EmailDigest:          DigestNever,
+	}
+}
+
+//Validate returns an error if the preferences don't match the schema
+func (p *Preferences) Validate() error {
+	switch p.Theme {
+	case ThemeSystem, ThemeLight, ThemeDark:
+	default:
+		return fmt.Errorf("theme must be one of %s, %s or %s", ThemeSystem, ThemeLight, ThemeDark)
+	}
+	if !isNotificationLevel(p.DefaultNotifications) {
+		return fmt.Errorf("defaultNotifications must be one of %s, %s or %s", NotifyAll, NotifyMentions, NotifyNone)
+	}
+	if len(p.ChannelNotifications) > MaxChannels {
+		return fmt.Errorf("channelNotifications may have at most %d channels", MaxChannels)
+	}
+	for channelID, level := range p.ChannelNotifications {
+		if id, err := strconv.ParseInt(channelID, 10, 64); err != nil || id <= 0 {
+			return fmt.Errorf("channelNotifications key %q is not a channel ID", channelID)
+		}
+		if !isNotificationLevel(level) {
+			return fmt.Errorf("channelNotifications level for channel %s must be one of %s, %s or %s",
+				channelID, NotifyAll, NotifyMentions, NotifyNone)
+		}
+	}
+	if len(p.MutedChannels) > MaxChannels {
+		return fmt.Errorf("mutedChannels may have at most %d channels", MaxChannels)
+	}
+	muted := make(map[int64]bool, len(p.MutedChannels))
+	for _, channelID := range p.MutedChannels {
+		if channelID <= 0 {
+			return fmt.Errorf("mutedChannels contains invalid channel ID %d", channelID)
+		}
+		if muted[channelID] {
+			return fmt.Errorf("mutedChannels contains channel %d more than once", channelID)
+		}
+		muted[channelID] = true
+	}
+	switch p.EmailDigest {
+	case DigestNever, DigestDaily, DigestWeekly:
+	default:
+		return fmt.Errorf("emailDigest must be one of %s, %s or %s", DigestNever, DigestDaily, DigestWeekly)
+	}
+	return nil
+}
+
+//isNotificationLevel returns true if the level is a known notification level
+func isNotificationLevel(level string) bool {
+	switch level {
+	case NotifyAll, NotifyMentions, NotifyNone:
+		return true
+	}
+	return false
+}
+
+//ApplyPatch applies a JSON merge patch (RFC 7396) to the preferences and
+//returns the patched preferences. Fields set to null are reset to their
+//default. An error is returned if the patched preferences are invalid.
+func (p *Preferences) ApplyPatch(patch []byte) (*Preferences, error) {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, fmt.Errorf("patch is not valid JSON: %v", err)
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("patch must be a JSON object")
+	}
+
+	current, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("encoding preferences: %v", err)
+	}
+	var currentDoc interface{}
+	if err := json.Unmarshal(current, &currentDoc); err != nil {
+		return nil, fmt.Errorf("decoding preferences: %v", err)
+	}
+	merged, err := json.Marshal(mergePatch(currentDoc, patchDoc))
+	if err != nil {
+		return nil, fmt.Errorf("encoding patched preferences: %v", err)
+	}
+
+	//start from the defaults so removed fields are reset, and
+	//reject fields that aren't part of the preferences schema
+	patched := Defaults()
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(patched); err != nil {
+		return nil, fmt.Errorf("patch doesn't match preferences: %v", err)
+	}
+	if err := patched.Validate(); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+//mergePatch merges the patch into the target as described in RFC 7396
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
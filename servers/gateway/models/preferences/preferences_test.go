@@ -0,0 +1,138 @@
+package preferences
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	current := Defaults()
+	current.Theme = ThemeDark
+	current.ChannelNotifications = map[string]string{"1": NotifyMentions, "2": NotifyNone}
+	current.MutedChannels = []int64{2}
+
+	cases := []struct {
+		name        string
+		patch       string
+		expected    *Preferences
+		expectError bool
+	}{
+		{
+			"Change Theme Only",
+			`{"theme": "light"}`,
+			&Preferences{
+				Theme:                ThemeLight,
+				DefaultNotifications: NotifyAll,
+				ChannelNotifications: map[string]string{"1": NotifyMentions, "2": NotifyNone},
+				MutedChannels:        []int64{2},
+				EmailDigest:          DigestNever,
+			},
+			false,
+		},
+		{
+			"Merge Channel Notifications",
+			`{"channelNotifications": {"2": null, "3": "all"}}`,
+			&Preferences{
+				Theme:                ThemeDark,
+				DefaultNotifications: NotifyAll,
+				ChannelNotifications: map[string]string{"1": NotifyMentions, "3": NotifyAll},
+				MutedChannels:        []int64{2},
+				EmailDigest:          DigestNever,
+			},
+			false,
+		},
+		{
+			"Replace Muted Channels",
+			`{"mutedChannels": [4, 5], "emailDigest": "weekly"}`,
+			&Preferences{
+				Theme:                ThemeDark,
+				DefaultNotifications: NotifyAll,
+				ChannelNotifications: map[string]string{"1": NotifyMentions, "2": NotifyNone},
+				MutedChannels:        []int64{4, 5},
+				EmailDigest:          DigestWeekly,
+			},
+			false,
+		},
+		{
+			"Null Resets To Default",
+			`{"theme": null, "mutedChannels": null}`,
+			&Preferences{
+				Theme:                ThemeSystem,
+				DefaultNotifications: NotifyAll,
+				ChannelNotifications: map[string]string{"1": NotifyMentions, "2": NotifyNone},
+				MutedChannels:        []int64{},
+				EmailDigest:          DigestNever,
+			},
+			false,
+		},
+		{
+			"Unknown Theme",
+			`{"theme": "solarized"}`,
+			nil,
+			true,
+		},
+		{
+			"Unknown Field",
+			`{"fontSize": 12}`,
+			nil,
+			true,
+		},
+		{
+			"Wrong Type",
+			`{"mutedChannels": "general"}`,
+			nil,
+			true,
+		},
+		{
+			"Channel Key Not An ID",
+			`{"channelNotifications": {"general": "all"}}`,
+			nil,
+			true,
+		},
+		{
+			"Unknown Notification Level",
+			`{"channelNotifications": {"4": "sometimes"}}`,
+			nil,
+			true,
+		},
+		{
+			"Duplicate Muted Channel",
+			`{"mutedChannels": [4, 4]}`,
+			nil,
+			true,
+		},
+		{
+			"Patch Not An Object",
+			`["theme"]`,
+			nil,
+			true,
+		},
+		{
+			"Invalid JSON",
+			`{"theme": `,
+			nil,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		patched, err := current.ApplyPatch([]byte(c.patch))
+		if c.expectError {
+			if err == nil {
+				t.Errorf("case %s: expected error but got nothing", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(patched, c.expected) {
+			t.Errorf("case %s: expected %+v but got %+v", c.name, c.expected, patched)
+		}
+	}
+
+	if current.Theme != ThemeDark || len(current.ChannelNotifications) != 2 {
+		t.Errorf("ApplyPatch should not change the current preferences")
+	}
+}
@@ -0,0 +1,93 @@
+package preferences
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//RedisCache is a preferences.Store that caches the preferences
+//held in another Store in redis
+type RedisCache struct {
+	//Redis client used to talk to redis server.
+	Client *redis.Client
+	//Store holds the preferences being cached
+	Store Store
+	//Used for key expiry time on redis.
+	CacheDuration time.Duration
+}
+
+//NewRedisCache constructs a new RedisCache
+func NewRedisCache(client *redis.Client, store Store, cacheDuration time.Duration) *RedisCache {
+	return &RedisCache{
+		Client:        client,
+		Store:         store,
+		CacheDuration: cacheDuration,
+	}
+}
+
+//Get returns the user's preferences from the cache,
+//or from the Store if they aren't cached
+func (rc *RedisCache) Get(userID int64) (*Preferences, error) {
+	cached, err := rc.Client.Get(getRedisKey(userID)).Bytes()
+	if err == nil {
+		prefs := Defaults()
+		if err := json.Unmarshal(cached, prefs); err == nil {
+			return prefs, nil
+		}
+	} else if err != redis.Nil {
+		log.Printf("error getting cached preferences for user %d: %v", userID, err)
+	}
+
+	prefs, err := rc.Store.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	rc.cache(userID, prefs)
+	return prefs, nil
+}
+
+//Save saves the user's preferences to the Store, then caches them
+func (rc *RedisCache) Save(userID int64, prefs *Preferences) error {
+	if err := rc.Store.Save(userID, prefs); err != nil {
+		//don't leave preferences cached that may no longer match the store
+		rc.Client.Del(getRedisKey(userID))
+		return err
+	}
+	rc.cache(userID, prefs)
+	return nil
+}
+
+//Patch patches the user's preferences in the Store, then removes them
+//from the cache so the next Get reads the patched preferences
+func (rc *RedisCache) Patch(userID int64, patch []byte) (*Preferences, error) {
+	patched, err := rc.Store.Patch(userID, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := rc.Client.Del(getRedisKey(userID)).Err(); err != nil {
+		log.Printf("error removing cached preferences for user %d: %v", userID, err)
+	}
+	return patched, nil
+}
+
+//cache saves the preferences in redis. Errors are only logged,
+//as the Store still holds the preferences.
+func (rc *RedisCache) cache(userID int64, prefs *Preferences) {
+	j, err := json.Marshal(prefs)
+	if err != nil {
+		log.Printf("error encoding preferences for user %d: %v", userID, err)
+		return
+	}
+	if err := rc.Client.Set(getRedisKey(userID), j, rc.CacheDuration).Err(); err != nil {
+		log.Printf("error caching preferences for user %d: %v", userID, err)
+	}
+}
+
+//getRedisKey returns the redis key for the user's cached preferences
+func getRedisKey(userID int64) string {
+	return "prefs:" + strconv.FormatInt(userID, 10)
+}
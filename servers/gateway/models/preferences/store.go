@@ -0,0 +1,26 @@
+package preferences
+
+//Store represents a store for user Preferences
+type Store interface {
+	//Get returns the user's preferences, or the defaults
+	//if the user hasn't saved any
+	Get(userID int64) (*Preferences, error)
+
+	//Save saves the user's preferences
+	Save(userID int64, prefs *Preferences) error
+
+	//Patch applies a JSON merge patch to the user's preferences and
+	//saves them, so concurrent patches don't overwrite each other.
+	//An *InvalidPatchError is returned if the patch can't be applied.
+	Patch(userID int64, patch []byte) (*Preferences, error)
+}
+
+//InvalidPatchError is returned by Patch when the patch can't be
+//applied to the user's preferences
+type InvalidPatchError struct {
+	Err error
+}
+
+func (e *InvalidPatchError) Error() string {
+	return e.Err.Error()
+}
@@ -0,0 +1,154 @@
+package users
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+//Orders the user directory can be sorted in. Users are numbered
+//as they sign up, so SortJoined orders by ID.
+const (
+	SortName     = "name"
+	SortUserName = "username"
+	SortJoined   = "joined"
+)
+
+//Filters on whether directory users are deactivated
+const (
+	FilterActive      = "active"
+	FilterDeactivated = "deactivated"
+	FilterAll         = "all"
+)
+
+//DefaultDirectoryLimit is the number of users in a page when the query doesn't set a limit
+const DefaultDirectoryLimit = 50
+
+//MaxDirectoryLimit is the most users in a page
+const MaxDirectoryLimit = 200
+
+//ErrInvalidCursor is returned when a directory cursor can't be decoded
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+//DirectoryQuery selects a page of the user directory
+type DirectoryQuery struct {
+	Sort       string
+	Descending bool
+	Status     string
+	//Role is optional, users of every role are listed when it is empty
+	Role string
+	//Cursor is the NextCursor of the previous page, or empty for the first page
+	Cursor string
+	Limit  int
+}
+
+//DirectoryPage is one page of the user directory
+type DirectoryPage struct {
+	Users []*User `json:"users"`
+	//NextCursor is passed as the Cursor of the next query, and is empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+//directoryCursor is the position in the directory after the last user of a page
+type directoryCursor struct {
+	Sort string   `json:"s"`
+	Keys []string `json:"k"`
+	ID   int64    `json:"id"`
+}
+
+//Validate fills in defaults and returns an error if the query is invalid
+func (q *DirectoryQuery) Validate() error {
+	switch q.Sort {
+	case "":
+		q.Sort = SortName
+	case SortName, SortUserName, SortJoined:
+	default:
+		return fmt.Errorf("sort must be one of %s, %s or %s", SortName, SortUserName, SortJoined)
+	}
+	switch q.Status {
+	case "":
+		q.Status = FilterActive
+	case FilterActive, FilterDeactivated, FilterAll:
+	default:
+		return fmt.Errorf("status must be one of %s, %s or %s", FilterActive, FilterDeactivated, FilterAll)
+	}
+	switch q.Role {
+	case "", RoleMember, RoleAdmin:
+	default:
+		return fmt.Errorf("role must be %s or %s", RoleMember, RoleAdmin)
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultDirectoryLimit
+	case q.Limit < 0 || q.Limit > MaxDirectoryLimit:
+		return fmt.Errorf("limit must be between 1 and %d", MaxDirectoryLimit)
+	}
+	if _, err := q.cursor(); err != nil {
+		return err
+	}
+	return nil
+}
+
+//cursor decodes the query's cursor, returning nil for the first page
+func (q *DirectoryQuery) cursor() (*directoryCursor, error) {
+	if len(q.Cursor) == 0 {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &directoryCursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.Sort != q.Sort ||
+		len(c.Keys) != len(sortColumns(q.Sort))-1 {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+//sortColumns returns the columns the directory is ordered by for the sort,
+//which always end with id so that every user has a distinct position
+func sortColumns(sort string) []string {
+	switch sort {
+	case SortUserName:
+		return []string{"username", "id"}
+	case SortJoined:
+		return []string{"id"}
+	default:
+		return []string{"lastname", "firstname", "id"}
+	}
+}
+
+//sortKeys returns the user's values for the sort columns other than id
+func sortKeys(user *User, sort string) []string {
+	switch sort {
+	case SortUserName:
+		return []string{user.UserName}
+	case SortJoined:
+		return []string{}
+	default:
+		return []string{user.LastName, user.FirstName}
+	}
+}
+
+//encodeCursor returns the cursor for the page after the user
+func encodeCursor(user *User, sort string) string {
+	data, _ := json.Marshal(&directoryCursor{
+		Sort: sort,
+		Keys: sortKeys(user, sort),
+		ID:   user.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+//newDirectoryPage builds a page from up to limit+1 users,
+//setting the cursor if there are more users than the limit
+func newDirectoryPage(users []*User, q *DirectoryQuery) *DirectoryPage {
+	page := &DirectoryPage{Users: users}
+	if len(users) > q.Limit {
+		page.Users = users[:q.Limit]
+		page.NextCursor = encodeCursor(users[q.Limit-1], q.Sort)
+	}
+	return page
+}
@@ -0,0 +1,66 @@
+package users
+
+import "testing"
+
+func TestDirectoryQueryValidate(t *testing.T) {
+	user := &User{ID: 3, UserName: "gopher", FirstName: "Competent", LastName: "Gopher"}
+	cases := []struct {
+		name        string
+		query       *DirectoryQuery
+		expectError bool
+	}{
+		{
+			"Defaults",
+			&DirectoryQuery{},
+			false,
+		},
+		{
+			"Valid Cursor",
+			&DirectoryQuery{Sort: SortUserName, Cursor: encodeCursor(user, SortUserName)},
+			false,
+		},
+		{
+			"Cursor For Another Sort",
+			&DirectoryQuery{Sort: SortJoined, Cursor: encodeCursor(user, SortUserName)},
+			true,
+		},
+		{
+			"Garbage Cursor",
+			&DirectoryQuery{Cursor: "not a cursor"},
+			true,
+		},
+		{
+			"Unknown Sort",
+			&DirectoryQuery{Sort: "email"},
+			true,
+		},
+		{
+			"Unknown Status",
+			&DirectoryQuery{Status: "suspended"},
+			true,
+		},
+		{
+			"Unknown Role",
+			&DirectoryQuery{Role: "owner"},
+			true,
+		},
+		{
+			"Limit Too Large",
+			&DirectoryQuery{Limit: MaxDirectoryLimit + 1},
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		err := c.query.Validate()
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: unexpected error result: %v", c.name, err)
+		}
+	}
+
+	q := &DirectoryQuery{}
+	q.Validate()
+	if q.Sort != SortName || q.Status != FilterActive || q.Limit != DefaultDirectoryLimit {
+		t.Errorf("expected defaults to be filled in, got %+v", q)
+	}
+}
@@ -2,6 +2,7 @@ package users
 
 import (
 	"errors"
+	"time"
 
 	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
 )
@@ -88,12 +89,71 @@ func (m *MockStore) UpdatePassword(id int64, passHash []byte) (*User, error) {
 	return nil, nil
 }
 
-//LoadUsers gets all users to add to the trie
-func (m *MockStore) LoadUsers() (*indexes.Trie, error) {
+//UpdateStatus sets the deactivated and suspended status of a user
+func (m *MockStore) UpdateStatus(id int64, status *Status) (*User, error) {
+	if m.TriggerError {
+		return nil, errors.New("Error with UpdateStatus")
+	}
+	return m.Result, nil
+}
+
+//GetContactIDs gets the IDs of the users who share a channel with the given user
+func (m *MockStore) GetContactIDs(id int64) ([]int64, error) {
+	if m.TriggerError {
+		return nil, errors.New("Error with GetContactIDs")
+	}
 	return nil, nil
 }
 
+//LoadUsers adds the Result to the search index if it is active
+func (m *MockStore) LoadUsers(index indexes.Index) error {
+	if m.TriggerError {
+		return errors.New("Error with LoadUsers")
+	}
+	if m.Result != nil && !m.Result.Deactivated {
+		index.Upsert(m.Result.ID, m.Result.SearchFields())
+	}
+	return nil
+}
+
+//GetUpdatedSince returns the Result as the only updated user
+func (m *MockStore) GetUpdatedSince(since time.Time) ([]*User, error) {
+	if m.TriggerError {
+		return nil, errors.New("Error with GetUpdatedSince")
+	}
+	if m.Result == nil {
+		return []*User{}, nil
+	}
+	return []*User{m.Result}, nil
+}
+
+//GetByIDs returns the users with the given IDs in the same order
+func (m *MockStore) GetByIDs(ids []int64) ([]*User, error) {
+	if m.TriggerError {
+		return nil, errors.New("Error with GetByIDs")
+	}
+	users := []*User{}
+	for _, id := range ids {
+		if m.Result != nil && m.Result.ID == id {
+			users = append(users, m.Result)
+		}
+	}
+	return users, nil
+}
+
+//GetDirectory returns a page of the user directory
+func (m *MockStore) GetDirectory(q *DirectoryQuery) (*DirectoryPage, error) {
+	if m.TriggerError {
+		return nil, errors.New("Error with GetDirectory")
+	}
+	users := []*User{}
+	if m.Result != nil {
+		users = append(users, m.Result)
+	}
+	return &DirectoryPage{Users: users}, nil
+}
+
 //GetSearchUsers gets all users based on the found Ids
-func (m *MockStore) GetSearchUsers(found []int64) (*[]User, error) {
-	return nil, nil
+func (m *MockStore) GetSearchUsers(found []int64) ([]*User, error) {
+	return m.GetByIDs(found)
 }
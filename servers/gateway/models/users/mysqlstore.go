@@ -3,10 +3,16 @@ package users
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
 )
 
+//userColumns are the columns selected for every user query
+const userColumns = "id, email, passhash, username, firstname, lastname, photourl, role, deactivated, suspendeduntil, " +
+	"title, bio, timezone, pronouns, statusemoji, statustext, statusexpiresat"
+
 //MySQLStore represents a users.Store backed by MySQL
 type MySQLStore struct {
 	db *sql.DB
@@ -21,11 +27,10 @@ func NewMySQLStore(db *sql.DB) *MySQLStore {
 
 //getBase performs all select statements
 func (s *MySQLStore) getBase(param string, value interface{}) (*User, error) {
-	query := fmt.Sprintf("select id, email, passhash, username, firstname, lastname, photourl from users where %v=?", param)
+	query := fmt.Sprintf("select %s from users where %v=?", userColumns, param)
 	user := &User{}
 
-	err := s.db.QueryRow(query, value).Scan(&user.ID, &user.Email, &user.PassHash,
-		&user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL)
+	err := scanUser(s.db.QueryRow(query, value), user)
 	switch {
 	case err == sql.ErrNoRows:
 		return nil, ErrUserNotFound
@@ -80,8 +85,9 @@ func (s *MySQLStore) Insert(user *User) (*User, error) {
 //Update applies UserUpdates to the given user ID
 //and returns the newly-updated user
 func (s *MySQLStore) Update(id int64, updates *Updates) (*User, error) {
-	updateq := "update users set firstname = ?, lastname = ? where id = ?"
-	updated, err := s.db.Exec(updateq, updates.FirstName, updates.LastName, id)
+	assignments, args := updateAssignments(updates)
+	updateq := "update users set " + assignments + " where id = ?"
+	updated, err := s.db.Exec(updateq, append(args, id)...)
 	if err != nil {
 		return nil, fmt.Errorf("updating: %v", err)
 	}
@@ -162,44 +168,190 @@ func (s *MySQLStore) UpdatePassword(id int64, passHash []byte) (*User, error) {
 	return s.GetByID(id)
 }
 
-//LoadUsers gets all users to add to the trie
-func (s *MySQLStore) LoadUsers() (*indexes.Trie, error) {
-	query := "select * from users"
+//UpdateStatus sets the deactivated and suspended status of a user
+//and returns the newly-updated user
+func (s *MySQLStore) UpdateStatus(id int64, status *Status) (*User, error) {
+	updateq := "update users set deactivated = ?, suspendeduntil = ? where id = ?"
+	updated, err := s.db.Exec(updateq, status.Deactivated, status.SuspendedUntil, id)
+	if err != nil {
+		return nil, fmt.Errorf("updating status: %v", err)
+	}
+
+	if err := checkRowsAffected(updated); err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(id)
+}
+
+//GetContactIDs gets the IDs of the users who share a channel with the given user
+func (s *MySQLStore) GetContactIDs(id int64) ([]int64, error) {
+	selectq := "select distinct other.usersid from channel_users mine " +
+		"join channel_users other on other.channelid = mine.channelid " +
+		"where mine.usersid = ? and other.usersid <> ?"
+	rows, err := s.db.Query(selectq, id, id)
+	if err != nil {
+		return nil, fmt.Errorf("getting contacts: %v", err)
+	}
+	defer rows.Close()
+
+	contactIDs := []int64{}
+	for rows.Next() {
+		var contactID int64
+		if err := rows.Scan(&contactID); err != nil {
+			return nil, fmt.Errorf("scanning contact: %v", err)
+		}
+		contactIDs = append(contactIDs, contactID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getting next row: %v", err)
+	}
+	return contactIDs, nil
+}
+
+//LoadUsers adds every active user to the search index
+func (s *MySQLStore) LoadUsers(index indexes.Index) error {
+	query := "select " + userColumns + " from users where deactivated = false"
 	rows, err := s.db.Query(query)
 	if err != nil {
-		return nil, fmt.Errorf("Error loading users for trie: %v", err)
+		return fmt.Errorf("Error loading users for trie: %v", err)
 	}
 	defer rows.Close()
 
-	users, err := extractUserRows(rows)
+	users, err := scanUsers(rows)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	for _, u := range users {
+		index.Upsert(u.ID, u.SearchFields())
 	}
+	return nil
+}
 
-	trie := indexes.NewTrie()
-	for _, u := range *users {
-		trie.AddConvertedUsers(u.FirstName, u.LastName, u.UserName, u.ID)
+//GetUpdatedSince returns the users created or changed at or after the time,
+//including deactivated users
+func (s *MySQLStore) GetUpdatedSince(since time.Time) ([]*User, error) {
+	rows, err := s.db.Query("select "+userColumns+" from users where updatedat >= ?", since)
+	if err != nil {
+		return nil, fmt.Errorf("getting updated users: %v", err)
 	}
-	return trie, nil
+	defer rows.Close()
+	return scanUsers(rows)
 }
 
-//GetSearchUsers gets all users based on the found Ids
-func (s *MySQLStore) GetSearchUsers(found []int64) (*[]User, error) {
+//GetSearchUsers gets the active users with the found IDs, keeping the order
+//the IDs were found in and leaving out repeated IDs
+func (s *MySQLStore) GetSearchUsers(found []int64) ([]*User, error) {
 	if len(found) < 1 {
-		return nil, nil
+		return []*User{}, nil
+	}
+	selectq := "select " + userColumns + " from users where deactivated = false and id in " + queryForSearch(found)
+	rows, err := s.db.Query(selectq, makeInterface(found)...)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting search users: %v", err)
+	}
+	defer rows.Close()
+
+	users, err := scanUsers(rows)
+	if err != nil {
+		return nil, err
+	}
+	return orderByIDs(users, found), nil
+}
+
+//GetByIDs returns the users with the given IDs in the same order, including
+//deactivated users. IDs without a user are left out.
+func (s *MySQLStore) GetByIDs(ids []int64) ([]*User, error) {
+	if len(ids) == 0 {
+		return []*User{}, nil
+	}
+	selectq := "select " + userColumns + " from users where id in " + queryForSearch(ids)
+	rows, err := s.db.Query(selectq, makeInterface(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting users: %v", err)
+	}
+	defer rows.Close()
+
+	users, err := scanUsers(rows)
+	if err != nil {
+		return nil, err
+	}
+	return orderByIDs(users, ids), nil
+}
+
+//GetDirectory returns a page of the user directory
+func (s *MySQLStore) GetDirectory(q *DirectoryQuery) (*DirectoryPage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	cursor, _ := q.cursor()
+
+	where := []string{}
+	args := []interface{}{}
+	switch q.Status {
+	case FilterActive:
+		where = append(where, "deactivated = false")
+	case FilterDeactivated:
+		where = append(where, "deactivated = true")
+	}
+	if len(q.Role) > 0 {
+		where = append(where, "role = ?")
+		args = append(args, q.Role)
+	}
+
+	columns := sortColumns(q.Sort)
+	direction, comparison := "asc", ">"
+	if q.Descending {
+		direction, comparison = "desc", "<"
+	}
+	if cursor != nil {
+		placeholders := "?" + strings.Repeat(",?", len(columns)-1)
+		where = append(where, fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ","), comparison, placeholders))
+		for _, key := range cursor.Keys {
+			args = append(args, key)
+		}
+		args = append(args, cursor.ID)
+	}
+	orderBy := make([]string, len(columns))
+	for i, column := range columns {
+		orderBy[i] = column + " " + direction
 	}
-	query := queryForSearch(found)
-	selectq := "select id, email, passhash, username, firstname, lastname, photourl from users where id in " + query
-	args := makeInterface(found)
+
+	selectq := "select " + userColumns + " from users"
+	if len(where) > 0 {
+		selectq += " where " + strings.Join(where, " and ")
+	}
+	selectq += " order by " + strings.Join(orderBy, ", ") + " limit ?"
+	args = append(args, q.Limit+1)
+
 	rows, err := s.db.Query(selectq, args...)
 	if err != nil {
-		return nil, fmt.Errorf("Error loading users for trie: %v", err)
+		return nil, fmt.Errorf("Error getting directory: %v", err)
 	}
 	defer rows.Close()
 
-	users, err := extractUserRows(rows)
+	users, err := scanUsers(rows)
+	if err != nil {
+		return nil, err
+	}
+	return newDirectoryPage(users, q), nil
+}
 
-	return users, err
+//scanUsers scans every row into a user
+func scanUsers(rows *sql.Rows) ([]*User, error) {
+	users := []*User{}
+	for rows.Next() {
+		user := &User{}
+		if err := scanUser(rows, user); err != nil {
+			return nil, fmt.Errorf("Error scanning user: %v", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error getting next row: %v", err)
+	}
+	return users, nil
 }
 
 //makeInterface makes the interface to be passed in to the select
@@ -212,30 +364,66 @@ func makeInterface(found []int64) []interface{} {
 	return args
 }
 
-//iterates through rows and returns array of users
-func extractUserRows(rows *sql.Rows) (*[]User, error) {
-	users := &[]User{}
-	for rows.Next() {
-		user := User{}
-		if err := rows.Scan(&user.ID, &user.Email, &user.PassHash,
-			&user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL); err != nil {
-			return nil, fmt.Errorf("Error scanning users for trie: %v", err)
-		}
-		*users = append(*users, user)
+//rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//scanUser scans the columns listed in userColumns into the user.
+//Custom statuses that have expired are left out.
+func scanUser(row rowScanner, user *User) error {
+	status := &CustomStatus{}
+	if err := row.Scan(&user.ID, &user.Email, &user.PassHash, &user.UserName, &user.FirstName,
+		&user.LastName, &user.PhotoURL, &user.Role, &user.Deactivated, &user.SuspendedUntil,
+		&user.Title, &user.Bio, &user.TimeZone, &user.Pronouns,
+		&status.Emoji, &status.Text, &status.ExpiresAt); err != nil {
+		return err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("Error getting next row: %v", err)
+	if !status.IsEmpty() && !status.IsExpired(time.Now()) {
+		user.CustomStatus = status
 	}
-	return users, nil
+	return nil
+}
+
+//updateAssignments returns the "column = ?" assignments, and their
+//arguments, for only the fields being changed by the updates
+func updateAssignments(updates *Updates) (string, []interface{}) {
+	columns := []string{}
+	args := []interface{}{}
+	set := func(column string, value interface{}) {
+		columns = append(columns, column+" = ?")
+		args = append(args, value)
+	}
+
+	if updates.hasNames() {
+		set("firstname", updates.FirstName)
+		set("lastname", updates.LastName)
+	}
+	if updates.Title != nil {
+		set("title", *updates.Title)
+	}
+	if updates.Bio != nil {
+		set("bio", *updates.Bio)
+	}
+	if updates.TimeZone != nil {
+		set("timezone", *updates.TimeZone)
+	}
+	if updates.Pronouns != nil {
+		set("pronouns", *updates.Pronouns)
+	}
+	if status := updates.CustomStatus; status != nil {
+		if status.IsEmpty() {
+			status = &CustomStatus{}
+		}
+		set("statusemoji", status.Emoji)
+		set("statustext", status.Text)
+		set("statusexpiresat", status.ExpiresAt)
+	}
+	return strings.Join(columns, ", "), args
 }
 
 //queryForSearch is a function for creating (?,?..) based on the length of
 //input ids for the select query
 func queryForSearch(found []int64) string {
-	query := "(?"
-	for i := 1; i < len(found); i++ {
-		query += ",?"
-	}
-	query += ") order by username asc"
-	return query
+	return "(?" + strings.Repeat(",?", len(found)-1) + ")"
 }
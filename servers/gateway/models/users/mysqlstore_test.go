@@ -7,16 +7,20 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
-const sqlGet = "select id, email, passhash, username, firstname, lastname, photourl from users where id=?"
-const sqlGetEmail = "select id, email, passhash, username, firstname, lastname, photourl from users where email=?"
-const sqlGetUserName = "select id, email, passhash, username, firstname, lastname, photourl from users where username=?"
+const sqlGet = "select id, email, passhash, username, firstname, lastname, photourl, role, deactivated, suspendeduntil, title, bio, timezone, pronouns, statusemoji, statustext, statusexpiresat from users where id=?"
+const sqlGetEmail = "select id, email, passhash, username, firstname, lastname, photourl, role, deactivated, suspendeduntil, title, bio, timezone, pronouns, statusemoji, statustext, statusexpiresat from users where email=?"
+const sqlGetUserName = "select id, email, passhash, username, firstname, lastname, photourl, role, deactivated, suspendeduntil, title, bio, timezone, pronouns, statusemoji, statustext, statusexpiresat from users where username=?"
 const sqlInsert = "insert into users(email, passhash, username, firstname, lastname, photourl) values (?,?,?,?,?,?)"
 const sqlUpdate = "update users set firstname = ?, lastname = ? where id = ?"
 const sqlDelete = "delete from users where id = ?"
+const sqlUpdateStatus = "update users set deactivated = ?, suspendeduntil = ? where id = ?"
+const sqlUpdateProfile = "update users set title = ?, timezone = ?, statusemoji = ?, statustext = ?, statusexpiresat = ? where id = ?"
+const sqlGetContactIDs = "select distinct other.usersid from channel_users mine join channel_users other on other.channelid = mine.channelid where mine.usersid = ? and other.usersid <> ?"
 
 func createMock() (*sql.DB, sqlmock.Sqlmock, error) {
 	db, mock, err := sqlmock.New()
@@ -41,6 +45,19 @@ func createTestUser(userType string) *User {
 			FirstName: "Competent",
 			LastName:  "Gopher",
 			PhotoURL:  "https://www.gravatar.com/avatar/9ed8dc990d56d07d330e5a057254cca9",
+			Role:      RoleMember,
+		}
+	case "deactivated":
+		expectedUser = &User{
+			ID:          1,
+			Email:       "test123@uw.edu",
+			PassHash:    []byte{36, 50, 97, 36, 49, 51, 36, 66, 78, 100},
+			UserName:    "competentGopher",
+			FirstName:   "Competent",
+			LastName:    "Gopher",
+			PhotoURL:    "https://www.gravatar.com/avatar/9ed8dc990d56d07d330e5a057254cca9",
+			Role:        RoleMember,
+			Deactivated: true,
 		}
 	case "insertError":
 		expectedUser = &User{
@@ -69,9 +86,26 @@ func createTestUser(userType string) *User {
 }
 
 func createRows(expectedUser *User) *sqlmock.Rows {
-	rows := sqlmock.NewRows([]string{"id", "email", "passhash", "username", "firstname", "lastname", "photourl"})
+	rows := sqlmock.NewRows([]string{"id", "email", "passhash", "username", "firstname", "lastname", "photourl",
+		"role", "deactivated", "suspendeduntil", "title", "bio", "timezone", "pronouns",
+		"statusemoji", "statustext", "statusexpiresat"})
+	var suspendedUntil driver.Value
+	if expectedUser.SuspendedUntil != nil {
+		suspendedUntil = *expectedUser.SuspendedUntil
+	}
+	status := &CustomStatus{}
+	if expectedUser.CustomStatus != nil {
+		status = expectedUser.CustomStatus
+	}
+	var statusExpiresAt driver.Value
+	if status.ExpiresAt != nil {
+		statusExpiresAt = *status.ExpiresAt
+	}
 	rows.AddRow(expectedUser.ID, expectedUser.Email, expectedUser.PassHash, expectedUser.UserName,
-		expectedUser.FirstName, expectedUser.LastName, expectedUser.PhotoURL)
+		expectedUser.FirstName, expectedUser.LastName, expectedUser.PhotoURL,
+		expectedUser.Role, expectedUser.Deactivated, suspendedUntil,
+		expectedUser.Title, expectedUser.Bio, expectedUser.TimeZone, expectedUser.Pronouns,
+		status.Emoji, status.Text, statusExpiresAt)
 	return rows
 }
 
@@ -299,6 +333,55 @@ func TestUpdate(t *testing.T) {
 	checkMockExpectations(t, mock)
 }
 
+func TestUpdateProfile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewMySQLStore(db)
+	title := "Gopher Wrangler"
+	timeZone := "America/Los_Angeles"
+	expiresAt := time.Now().Add(time.Hour).Round(time.Second)
+	updates := &Updates{
+		Title:        &title,
+		TimeZone:     &timeZone,
+		CustomStatus: &CustomStatus{Emoji: ":coffee:", Text: "Brewing", ExpiresAt: &expiresAt},
+	}
+	expectedUser := createTestUser("normal")
+	expectedUser.Title = title
+	expectedUser.TimeZone = timeZone
+	expectedUser.CustomStatus = updates.CustomStatus
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlUpdateProfile)).
+		WithArgs(title, timeZone, ":coffee:", "Brewing", expiresAt, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGet)).WithArgs(1).WillReturnRows(createRows(expectedUser))
+
+	updated, err := store.Update(1, updates)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if !reflect.DeepEqual(updated, expectedUser) {
+		t.Errorf("Returned user not equal to expected user")
+	}
+
+	//expired statuses are left out when reading users
+	expired := time.Now().Add(-time.Hour).Round(time.Second)
+	expiredUser := createTestUser("normal")
+	expiredUser.CustomStatus = &CustomStatus{Emoji: ":coffee:", ExpiresAt: &expired}
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGet)).WithArgs(1).WillReturnRows(createRows(expiredUser))
+	if user, err := store.GetByID(1); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if user.CustomStatus != nil {
+		t.Errorf("Expected expired custom status to be left out")
+	}
+
+	checkMockExpectations(t, mock)
+}
+
 func TestDelete(t *testing.T) {
 	db, mock, err := sqlmock.New()
 
@@ -334,3 +417,163 @@ func TestDelete(t *testing.T) {
 
 	checkMockExpectations(t, mock)
 }
+
+func TestUpdateStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewMySQLStore(db)
+	expectedUser := createTestUser("deactivated")
+	status := &Status{Deactivated: true}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlUpdateStatus)).WithArgs(true, nil, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	rows := createRows(expectedUser)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGet)).WithArgs(1).WillReturnRows(rows)
+
+	updated, err := store.UpdateStatus(1, status)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if !reflect.DeepEqual(updated, expectedUser) {
+		t.Errorf("Returned user not equal to expected user")
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(sqlUpdateStatus)).WithArgs(true, nil, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err = store.UpdateStatus(3, status); err != ErrUserNotFound {
+		t.Errorf("Expected error: %v but got %v", ErrUserNotFound, err)
+	}
+
+	checkMockExpectations(t, mock)
+}
+
+func TestGetContactIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+
+	defer db.Close()
+
+	store := NewMySQLStore(db)
+	rows := sqlmock.NewRows([]string{"usersid"}).AddRow(2).AddRow(5)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetContactIDs)).WithArgs(1, 1).WillReturnRows(rows)
+
+	contactIDs, err := store.GetContactIDs(1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if !reflect.DeepEqual(contactIDs, []int64{2, 5}) {
+		t.Errorf("Expected contacts %v but got %v", []int64{2, 5}, contactIDs)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlGetContactIDs)).WithArgs(1, 1).WillReturnError(fmt.Errorf("some error"))
+	if _, err = store.GetContactIDs(1); err == nil {
+		t.Errorf("Expected error but got none")
+	}
+
+	checkMockExpectations(t, mock)
+}
+
+func TestGetDirectory(t *testing.T) {
+	db, mock, err := createMock()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+	store := NewMySQLStore(db)
+
+	expectedUser := createTestUser("normal")
+	sqlDirectory := "select " + userColumns + " from users where deactivated = false and role = ? " +
+		"order by lastname asc, firstname asc, id asc limit ?"
+	mock.ExpectQuery(regexp.QuoteMeta(sqlDirectory)).WithArgs(RoleMember, 2).WillReturnRows(createRows(expectedUser))
+	page, err := store.GetDirectory(&DirectoryQuery{Role: RoleMember, Limit: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Users) != 1 || !reflect.DeepEqual(page.Users[0], expectedUser) {
+		t.Errorf("expected directory to contain the user, got %v", page.Users)
+	}
+	if len(page.NextCursor) != 0 {
+		t.Errorf("expected no next cursor on the last page, got %s", page.NextCursor)
+	}
+
+	cursor := encodeCursor(expectedUser, SortUserName)
+	sqlNextPage := "select " + userColumns + " from users " +
+		"where (username,id) < (?,?) order by username desc, id desc limit ?"
+	mock.ExpectQuery(regexp.QuoteMeta(sqlNextPage)).WithArgs(expectedUser.UserName, expectedUser.ID, DefaultDirectoryLimit+1).
+		WillReturnRows(createRows(expectedUser))
+	if _, err := store.GetDirectory(&DirectoryQuery{Sort: SortUserName, Descending: true,
+		Status: FilterAll, Cursor: cursor}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checkMockExpectations(t, mock)
+}
+
+func TestGetSearchUsers(t *testing.T) {
+	db, mock, err := createMock()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+	store := NewMySQLStore(db)
+
+	first := createTestUser("normal")
+	second := createTestUser("normal")
+	second.ID = 2
+	second.UserName = "anotherGopher"
+	rows := createRows(first)
+	rows.AddRow(second.ID, second.Email, second.PassHash, second.UserName, second.FirstName, second.LastName,
+		second.PhotoURL, second.Role, second.Deactivated, nil, second.Title, second.Bio, second.TimeZone,
+		second.Pronouns, "", "", nil)
+	sqlSearch := "select " + userColumns + " from users where deactivated = false and id in (?,?,?)"
+	mock.ExpectQuery(regexp.QuoteMeta(sqlSearch)).WithArgs(2, 1, 2).WillReturnRows(rows)
+
+	found, err := store.GetSearchUsers([]int64{2, 1, 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 2 || found[0].ID != 2 || found[1].ID != 1 {
+		t.Errorf("expected users in the order found without repeats, got %v", found)
+	}
+
+	empty, err := store.GetSearchUsers(nil)
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("expected an empty result for no IDs, got %v, %v", empty, err)
+	}
+
+	checkMockExpectations(t, mock)
+}
+
+func TestGetUpdatedSince(t *testing.T) {
+	db, mock, err := createMock()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+	store := NewMySQLStore(db)
+
+	since := time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC)
+	expected := createTestUser("normal")
+	sqlUpdated := "select " + userColumns + " from users where updatedat >= ?"
+	mock.ExpectQuery(regexp.QuoteMeta(sqlUpdated)).WithArgs(since).WillReturnRows(createRows(expected))
+
+	updated, err := store.GetUpdatedSince(since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated) != 1 || !reflect.DeepEqual(updated[0], expected) {
+		t.Errorf("expected %v but got %v", expected, updated)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlUpdated)).WithArgs(since).WillReturnError(fmt.Errorf("some error"))
+	if _, err := store.GetUpdatedSince(since); err == nil {
+		t.Errorf("expected error but got none")
+	}
+
+	checkMockExpectations(t, mock)
+}
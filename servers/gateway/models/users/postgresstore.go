@@ -3,6 +3,7 @@ package users
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
 )
@@ -20,11 +21,10 @@ func NewMyPostGressStore(db *sql.DB) *MyPostGressStore {
 }
 
 func (s *MyPostGressStore) getBase(param string, value interface{}) (*User, error) {
-	query := fmt.Sprintf("select id, email, passhash, username, firstname, lastname, photourl from users where %v=?", param)
+	query := fmt.Sprintf("select %s from users where %v=?", userColumns, param)
 	user := &User{}
 
-	err := s.db.QueryRow(query, value).Scan(&user.ID, &user.Email, &user.PassHash,
-		&user.UserName, &user.FirstName, &user.LastName, &user.PhotoURL)
+	err := scanUser(s.db.QueryRow(query, value), user)
 	switch {
 	case err == sql.ErrNoRows:
 		return nil, ErrUserNotFound
@@ -69,8 +69,9 @@ func (s *MyPostGressStore) Insert(user *User) (*User, error) {
 //Update applies UserUpdates to the given user ID
 //and returns the newly-updated user
 func (s *MyPostGressStore) Update(id int64, updates *Updates) (*User, error) {
-	updateq := "update users set firstname = ?, lastname = ? where id = ?;"
-	updated, err := s.db.Exec(updateq, updates.FirstName, updates.LastName, id)
+	assignments, args := updateAssignments(updates)
+	updateq := "update users set " + assignments + " where id = ?;"
+	updated, err := s.db.Exec(updateq, append(args, id)...)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
@@ -116,12 +117,45 @@ func (s *MyPostGressStore) UpdatePassword(id int64, passHash []byte) (*User, err
 	return nil, nil
 }
 
-//LoadUsers gets all users to add to the trie
-func (s *MyPostGressStore) LoadUsers() (*indexes.Trie, error) {
+//UpdateStatus sets the deactivated and suspended status of a user
+func (s *MyPostGressStore) UpdateStatus(id int64, status *Status) (*User, error) {
+	updateq := "update users set deactivated = ?, suspendeduntil = ? where id = ?;"
+	updated, err := s.db.Exec(updateq, status.Deactivated, status.SuspendedUntil, id)
+	if err != nil {
+		return nil, fmt.Errorf("updating status: %v", err)
+	}
+	if err := checkRowsAffected(updated); err != nil {
+		return nil, err
+	}
+	return s.GetByID(id)
+}
+
+//GetContactIDs gets the IDs of the users who share a channel with the given user
+func (s *MyPostGressStore) GetContactIDs(id int64) ([]int64, error) {
 	return nil, nil
 }
 
+//LoadUsers adds every active user to the search index
+func (s *MyPostGressStore) LoadUsers(index indexes.Index) error {
+	return nil
+}
+
+//GetUpdatedSince returns the users created or changed at or after the time
+func (s *MyPostGressStore) GetUpdatedSince(since time.Time) ([]*User, error) {
+	return []*User{}, nil
+}
+
+//GetByIDs returns the users with the given IDs in the same order
+func (s *MyPostGressStore) GetByIDs(ids []int64) ([]*User, error) {
+	return []*User{}, nil
+}
+
+//GetDirectory returns a page of the user directory
+func (s *MyPostGressStore) GetDirectory(q *DirectoryQuery) (*DirectoryPage, error) {
+	return &DirectoryPage{Users: []*User{}}, nil
+}
+
 //GetSearchUsers gets all users based on the found Ids
-func (s *MyPostGressStore) GetSearchUsers(found []int64) (*[]User, error) {
-	return nil, nil
+func (s *MyPostGressStore) GetSearchUsers(found []int64) ([]*User, error) {
+	return []*User{}, nil
 }
@@ -0,0 +1,133 @@
+package users
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+)
+
+//Relevance ranks of a search result, most relevant first
+const (
+	rankExactUserName = iota
+	rankNamePrefix
+	rankOther
+	//rankFuzzy is for users found by a fuzzy search,
+	//with no field starting with a term of the query
+	rankFuzzy
+)
+
+//searchScore is how relevant a user is to a search
+type searchScore struct {
+	rank int
+	//boost is how closely the searching user is connected to the user
+	boost float64
+	//fields is the number of the user's first name, last name
+	//and username that start with a term of the query
+	fields int
+}
+
+//less returns true if the score should be ranked before the other
+func (s searchScore) less(other searchScore) bool {
+	if s.rank != other.rank {
+		return s.rank < other.rank
+	}
+	if s.boost != other.boost {
+		return s.boost > other.boost
+	}
+	return s.fields > other.fields
+}
+
+//SortByRelevance orders search results for the query: users whose username
+//is the query come first, then users whose first or last names start with
+//every term of the query, then everyone else with a field starting with a
+//term, and last users that were only found by a fuzzy search. Within each rank, users with
+//more fields matching a term come first, and otherwise results keep the
+//order they were found in. The query and names are compared after analysis,
+//so case, accents and punctuation don't affect the rank.
+func SortByRelevance(users []*User, query string) {
+	SortByRelevanceWithBoosts(users, query, nil)
+}
+
+//SortByRelevanceWithBoosts orders search results like SortByRelevance, but
+//within each rank users with a higher boost come first, before comparing how
+//many fields matched. Boosts are keyed by user ID, users without one have none.
+func SortByRelevanceWithBoosts(users []*User, query string, boosts map[int64]float64) {
+	terms := indexes.Analyze(query)
+	scores := make(map[*User]searchScore, len(users))
+	for _, user := range users {
+		score := scoreUser(user, terms)
+		score.boost = boosts[user.ID]
+		scores[user] = score
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		return scores[users[i]].less(scores[users[j]])
+	})
+}
+
+//scoreUser returns the relevance of the user for the analyzed query terms
+func scoreUser(user *User, terms []string) searchScore {
+	score := searchScore{rank: rankOther}
+	if len(terms) == 0 {
+		return score
+	}
+	userName := indexes.Analyze(user.UserName)
+	firstName := indexes.Analyze(user.FirstName)
+	lastName := indexes.Analyze(user.LastName)
+	for _, field := range [][]string{firstName, lastName, userName} {
+		for _, term := range terms {
+			if hasPrefixedKey(field, term) {
+				score.fields++
+				break
+			}
+		}
+	}
+
+	switch {
+	case reflect.DeepEqual(userName, terms):
+		score.rank = rankExactUserName
+	case prefixesAll(append(firstName, lastName...), terms):
+		score.rank = rankNamePrefix
+	case score.fields == 0:
+		score.rank = rankFuzzy
+	}
+	return score
+}
+
+//prefixesAll returns true if every term is the prefix of one of the keys
+func prefixesAll(keys []string, terms []string) bool {
+	for _, term := range terms {
+		if !hasPrefixedKey(keys, term) {
+			return false
+		}
+	}
+	return true
+}
+
+//hasPrefixedKey returns true if one of the keys starts with the term
+func hasPrefixedKey(keys []string, term string) bool {
+	for _, key := range keys {
+		if strings.HasPrefix(key, term) {
+			return true
+		}
+	}
+	return false
+}
+
+//orderByIDs returns the users in the order of the IDs, once each,
+//leaving out IDs that have no user
+func orderByIDs(users []*User, ids []int64) []*User {
+	byID := make(map[int64]*User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	ordered := make([]*User, 0, len(users))
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			ordered = append(ordered, user)
+			delete(byID, id)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,152 @@
+package users
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortByRelevance(t *testing.T) {
+	cases := []struct {
+		name        string
+		query       string
+		users       []*User
+		expectedIDs []int64
+	}{
+		{
+			"Exact Username First",
+			"gopher",
+			[]*User{
+				{ID: 1, UserName: "gophers", FirstName: "Competent", LastName: "Gopher"},
+				{ID: 2, UserName: "Gopher", FirstName: "Go", LastName: "Pher"},
+			},
+			[]int64{2, 1},
+		},
+		{
+			"Name Prefix Before Username Prefix",
+			"comp",
+			[]*User{
+				{ID: 1, UserName: "computer", FirstName: "Some", LastName: "One"},
+				{ID: 2, UserName: "gopher", FirstName: "Mary Competent", LastName: "Gopher"},
+				{ID: 3, UserName: "whale", FirstName: "Blue", LastName: "Compton"},
+			},
+			[]int64{2, 3, 1},
+		},
+		{
+			"Found Order Kept Within Rank",
+			"a",
+			[]*User{
+				{ID: 3, UserName: "x", FirstName: "Ann"},
+				{ID: 1, UserName: "y", FirstName: "Amy"},
+				{ID: 2, UserName: "z", FirstName: "Al"},
+			},
+			[]int64{3, 1, 2},
+		},
+		{
+			"Accents And Case Ignored",
+			"JOSE",
+			[]*User{
+				{ID: 1, UserName: "jb", FirstName: "Ana", LastName: "Josephs"},
+				{ID: 2, UserName: "José", FirstName: "Some", LastName: "One"},
+				{ID: 3, UserName: "x", FirstName: "José", LastName: "Núñez"},
+			},
+			[]int64{2, 1, 3},
+		},
+		{
+			"More Fields Matched First",
+			"john smi",
+			[]*User{
+				{ID: 1, UserName: "xyz", FirstName: "John", LastName: "Smith"},
+				{ID: 2, UserName: "smithy", FirstName: "John", LastName: "Doe"},
+				{ID: 3, UserName: "johnsmith", FirstName: "Johnny", LastName: "Smithers"},
+			},
+			[]int64{3, 1, 2},
+		},
+		{
+			"Fuzzy Matches Last",
+			"jsm",
+			[]*User{
+				{ID: 1, UserName: "xsmith", FirstName: "Xavier", LastName: "Smith"},
+				{ID: 2, UserName: "jsmith", FirstName: "John", LastName: "Smith"},
+			},
+			[]int64{2, 1},
+		},
+		{
+			"No Results",
+			"nobody",
+			[]*User{},
+			[]int64{},
+		},
+	}
+
+	for _, c := range cases {
+		SortByRelevance(c.users, c.query)
+		ids := []int64{}
+		for _, user := range c.users {
+			ids = append(ids, user.ID)
+		}
+		if !reflect.DeepEqual(ids, c.expectedIDs) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expectedIDs, ids)
+		}
+	}
+}
+
+func TestSortByRelevanceWithBoosts(t *testing.T) {
+	sams := []*User{
+		{ID: 1, UserName: "sam1", FirstName: "Sam", LastName: "Adams"},
+		{ID: 2, UserName: "sam2", FirstName: "Sam", LastName: "Brown"},
+		{ID: 3, UserName: "sam", FirstName: "Samuel", LastName: "Clark"},
+		{ID: 4, UserName: "sam4", FirstName: "Sam", LastName: "Davis"},
+		{ID: 5, UserName: "other", FirstName: "Alex", LastName: "Samson"},
+	}
+	cases := []struct {
+		name        string
+		boosts      map[int64]float64
+		expectedIDs []int64
+	}{
+		{"No Boosts", nil, []int64{3, 1, 2, 4, 5}},
+		{"Boosted Within Rank", map[int64]float64{4: 1, 2: 3}, []int64{3, 2, 4, 1, 5}},
+		{"Boost Doesn't Beat Exact Username", map[int64]float64{1: 100}, []int64{3, 1, 2, 4, 5}},
+		{"Boost Beats More Fields Matched", map[int64]float64{5: 1}, []int64{3, 5, 1, 2, 4}},
+	}
+
+	for _, c := range cases {
+		users := append([]*User{}, sams...)
+		SortByRelevanceWithBoosts(users, "sam", c.boosts)
+		ids := []int64{}
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+		if !reflect.DeepEqual(ids, c.expectedIDs) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expectedIDs, ids)
+		}
+	}
+}
+
+func TestOrderByIDs(t *testing.T) {
+	users := []*User{{ID: 1}, {ID: 2}, {ID: 3}}
+	ordered := orderByIDs(users, []int64{3, 5, 1, 3})
+	ids := []int64{}
+	for _, user := range ordered {
+		ids = append(ids, user.ID)
+	}
+	if !reflect.DeepEqual(ids, []int64{3, 1}) {
+		t.Errorf("expected users in ID order without missing or repeated IDs, got %v", ids)
+	}
+}
+
+func TestSortByRelevanceWithBoostsFuzzy(t *testing.T) {
+	users := []*User{
+		{ID: 1, UserName: "xsmith", FirstName: "Xavier", LastName: "Smith"},
+		{ID: 2, UserName: "jsmith", FirstName: "John", LastName: "Smith"},
+		{ID: 3, UserName: "jsmart", FirstName: "Jo", LastName: "Smart"},
+	}
+	//a boost doesn't put a fuzzy match before a username prefix match
+	SortByRelevanceWithBoosts(users, "jsm", map[int64]float64{1: 1, 3: 2})
+	ids := []int64{}
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	if expected := []int64{3, 2, 1}; !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v but got %v", expected, ids)
+	}
+}
@@ -0,0 +1,89 @@
+package users
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+)
+
+//SyncSkew is how far before the last sync catching up starts from, so that
+//users changed during the sync, or stamped by a database clock a little
+//behind the gateway's, aren't missed. Catching up a user twice is harmless.
+const SyncSkew = time.Minute
+
+//LoadSearchIndex loads a search index made by newIndex from the snapshot at
+//path and catches up with the users changed since it was taken. If path is
+//empty, or there is no usable snapshot, the index is filled from every user
+//instead. It returns the index and the time it was up to date with the store,
+//which should be passed to the next CatchUp.
+func LoadSearchIndex(store Store, newIndex func() indexes.Index, path string) (indexes.Index, time.Time, error) {
+	if len(path) > 0 {
+		index := newIndex()
+		takenAt, err := indexes.LoadFile(path, index)
+		switch {
+		case err == nil:
+			syncedAt, err := CatchUp(store, index, takenAt)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			return index, syncedAt, nil
+		case os.IsNotExist(err), err == indexes.ErrInvalidSnapshot:
+			//fill a new index, the next snapshot will replace the unusable one
+		default:
+			return nil, time.Time{}, fmt.Errorf("reading search snapshot: %v", err)
+		}
+	}
+
+	index := newIndex()
+	syncedAt := time.Now()
+	if err := store.LoadUsers(index); err != nil {
+		return nil, time.Time{}, err
+	}
+	return index, syncedAt, nil
+}
+
+//OpenSharedSearchIndex prepares a search index that every gateway shares,
+//like an indexes.RedisIndex. The index outlives any one gateway and is kept up
+//to date by all of them, so it is only filled from every user when it is empty.
+//It returns the time to pass to the first CatchUp.
+func OpenSharedSearchIndex(store Store, index indexes.Index) (time.Time, error) {
+	syncedAt := time.Now()
+	if index.Len() > 0 {
+		return syncedAt, nil
+	}
+	if err := store.LoadUsers(index); err != nil {
+		return time.Time{}, err
+	}
+	return syncedAt, nil
+}
+
+//CatchUp updates the index with the users changed since it was last
+//up to date with the store, re-indexing their names and removing
+//deactivated users. It returns the time the index is now up to date at.
+func CatchUp(store Store, index indexes.Index, since time.Time) (time.Time, error) {
+	syncedAt := time.Now()
+	updated, err := store.GetUpdatedSince(since.Add(-SyncSkew))
+	if err != nil {
+		return since, fmt.Errorf("catching up search index: %v", err)
+	}
+	for _, u := range updated {
+		if u.Deactivated {
+			index.Delete(u.ID)
+		} else {
+			index.Upsert(u.ID, u.SearchFields())
+		}
+	}
+	return syncedAt, nil
+}
+
+//Reconcile makes the index match the store, re-indexing every active user
+//and removing anyone else. This repairs anything CatchUp can't see, such
+//as lost events or users deleted from the store.
+func Reconcile(store Store, index indexes.Index) error {
+	if err := indexes.Reconcile(index, store.LoadUsers); err != nil {
+		return fmt.Errorf("reconciling search index: %v", err)
+	}
+	return nil
+}
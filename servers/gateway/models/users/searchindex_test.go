@@ -0,0 +1,227 @@
+package users
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+)
+
+func TestLoadSearchIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "searchindex")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	renamed := &User{ID: 1, UserName: "gopher", FirstName: "Competent", LastName: "Gopher"}
+	deactivated := &User{ID: 2, UserName: "whale", FirstName: "Blue", LastName: "Whale", Deactivated: true}
+
+	//the snapshot has the users before the rename and deactivation
+	snapshot := indexes.NewTrie()
+	snapshot.AddConvertedUsers("Old", "Name", "gopher", 1)
+	snapshot.AddConvertedUsers("Blue", "Whale", "whale", 2)
+	snapshot.AddConvertedUsers("Other", "Person", "other", 3)
+	snapshotPath := filepath.Join(dir, "search.snapshot")
+	if err := indexes.SaveFile(snapshot, snapshotPath, time.Now()); err != nil {
+		t.Fatalf("error saving snapshot: %v", err)
+	}
+	corruptPath := filepath.Join(dir, "corrupt.snapshot")
+	if err := ioutil.WriteFile(corruptPath, []byte("not a snapshot"), 0600); err != nil {
+		t.Fatalf("error writing corrupt snapshot: %v", err)
+	}
+
+	cases := []struct {
+		name        string
+		store       *MockStore
+		path        string
+		expectError bool
+		queries     map[string][]int64
+	}{
+		{
+			"Snapshot Caught Up With Renamed User",
+			NewMockStore(false, renamed),
+			snapshotPath,
+			false,
+			map[string][]int64{"comp": {1}, "old": nil, "other": {3}},
+		},
+		{
+			"Snapshot Caught Up With Deactivated User",
+			NewMockStore(false, deactivated),
+			snapshotPath,
+			false,
+			map[string][]int64{"whale": nil, "old": {1}},
+		},
+		{
+			"No Snapshot",
+			NewMockStore(false, renamed),
+			filepath.Join(dir, "missing.snapshot"),
+			false,
+			map[string][]int64{"comp": {1}, "other": nil},
+		},
+		{
+			"Corrupt Snapshot Rebuilt",
+			NewMockStore(false, renamed),
+			corruptPath,
+			false,
+			map[string][]int64{"comp": {1}},
+		},
+		{
+			"No Snapshot Path",
+			NewMockStore(false, renamed),
+			"",
+			false,
+			map[string][]int64{"comp": {1}},
+		},
+		{
+			"Store Error Surfaced",
+			NewMockStore(true, nil),
+			snapshotPath,
+			true,
+			nil,
+		},
+		{
+			"Store Error Surfaced Without Snapshot",
+			NewMockStore(true, nil),
+			"",
+			true,
+			nil,
+		},
+	}
+
+	newIndex := func() indexes.Index { return indexes.NewRadixTree() }
+	for _, c := range cases {
+		index, syncedAt, err := LoadSearchIndex(c.store, newIndex, c.path)
+		if c.expectError {
+			if err == nil {
+				t.Errorf("case %s: expected error but got none", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if syncedAt.IsZero() {
+			t.Errorf("case %s: expected the sync time to be set", c.name)
+		}
+		for query, expected := range c.queries {
+			if found := index.Find(query, 5); !reflect.DeepEqual(found, expected) {
+				t.Errorf("case %s: expected %q to find %v but got %v", c.name, query, expected, found)
+			}
+		}
+	}
+}
+
+func TestOpenSharedSearchIndex(t *testing.T) {
+	user := &User{ID: 1, UserName: "gopher", FirstName: "Competent", LastName: "Gopher"}
+	cases := []struct {
+		name        string
+		store       *MockStore
+		indexed     bool
+		expectError bool
+		queries     map[string][]int64
+	}{
+		{
+			"Empty Index Filled",
+			NewMockStore(false, user),
+			false,
+			false,
+			map[string][]int64{"comp": {1}},
+		},
+		{
+			"Filled Index Left Alone",
+			NewMockStore(false, user),
+			true,
+			false,
+			map[string][]int64{"comp": nil, "whale": {2}},
+		},
+		{
+			"Store Error Surfaced",
+			NewMockStore(true, nil),
+			false,
+			true,
+			nil,
+		},
+		{
+			"Store Not Needed For Filled Index",
+			NewMockStore(true, nil),
+			true,
+			false,
+			map[string][]int64{"whale": {2}},
+		},
+	}
+
+	for _, c := range cases {
+		index := indexes.NewTrie()
+		if c.indexed {
+			index.Upsert(2, []string{"Blue", "Whale", "whale"})
+		}
+		syncedAt, err := OpenSharedSearchIndex(c.store, index)
+		if c.expectError {
+			if err == nil {
+				t.Errorf("case %s: expected error but got none", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if syncedAt.IsZero() {
+			t.Errorf("case %s: expected the sync time to be set", c.name)
+		}
+		for query, expected := range c.queries {
+			if found := index.Find(query, 5); !reflect.DeepEqual(found, expected) {
+				t.Errorf("case %s: expected %q to find %v but got %v", c.name, query, expected, found)
+			}
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name        string
+		store       *MockStore
+		expectError bool
+		queries     map[string][]int64
+	}{
+		{
+			"Stale Users Removed And Active User Reindexed",
+			NewMockStore(false, &User{ID: 1, UserName: "gopher", FirstName: "Mary", LastName: "Gopher"}),
+			false,
+			map[string][]int64{"mary": {1}, "old": nil, "whale": nil},
+		},
+		{
+			"Deactivated User Removed",
+			NewMockStore(false, &User{ID: 1, UserName: "gopher", Deactivated: true}),
+			false,
+			map[string][]int64{"old": nil, "gopher": nil, "whale": nil},
+		},
+		{
+			"Store Error Leaves Index",
+			NewMockStore(true, nil),
+			true,
+			map[string][]int64{"old": {1}, "whale": {2}},
+		},
+	}
+
+	for _, c := range cases {
+		index := indexes.NewTrie()
+		index.Upsert(1, []string{"Old", "Name", "gopher"})
+		index.Upsert(2, []string{"Blue", "Whale", "whale"})
+		err := Reconcile(c.store, index)
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: expected error %t but got %v", c.name, c.expectError, err)
+		}
+		for query, expected := range c.queries {
+			if found := index.FindAll([]string{query}, 5, nil); !reflect.DeepEqual(found, expected) {
+				t.Errorf("case %s: expected %q to find %v but got %v", c.name, query, expected, found)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package users
 
 import (
 	"errors"
+	"time"
 
 	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
 )
@@ -40,9 +41,28 @@ type Store interface {
 	//UpdatePassword updates password after resetting it.
 	UpdatePassword(id int64, passHash []byte) (*User, error)
 
-	//LoadUsers gets all users to add to the trie
-	LoadUsers() (*indexes.Trie, error)
+	//UpdateStatus sets the deactivated and suspended status of a user
+	//and returns the newly-updated user
+	UpdateStatus(id int64, status *Status) (*User, error)
+
+	//GetContactIDs gets the IDs of the users who share a channel with the given user
+	GetContactIDs(id int64) ([]int64, error)
+
+	//LoadUsers adds every active user to the search index
+	LoadUsers(index indexes.Index) error
+
+	//GetUpdatedSince returns the users created or changed at or after the time,
+	//including deactivated users
+	GetUpdatedSince(since time.Time) ([]*User, error)
+
+	//GetByIDs returns the users with the given IDs in the same order,
+	//including deactivated users. IDs without a user are left out.
+	GetByIDs(ids []int64) ([]*User, error)
+
+	//GetDirectory returns a page of the user directory
+	GetDirectory(q *DirectoryQuery) (*DirectoryPage, error)
 
-	//GetSearchUsers gets all users based on the found Ids
-	GetSearchUsers(found []int64) (*[]User, error)
+	//GetSearchUsers gets the active users with the found IDs, keeping the order
+	//the IDs were found in and leaving out repeated IDs
+	GetSearchUsers(found []int64) ([]*User, error)
 }
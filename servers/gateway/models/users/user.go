@@ -8,6 +8,7 @@ import (
 	"net/mail"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -19,6 +20,28 @@ const gravatarBasePhotoURL = "https://www.gravatar.com/avatar/"
 //bcryptCost is the default bcrypt cost to use when hashing passwords
 var bcryptCost = 13
 
+//RoleMember is the role given to every new user
+const RoleMember = "member"
+
+//RoleAdmin is the role for users allowed to manage other accounts
+const RoleAdmin = "admin"
+
+//ErrUserDeactivated is returned when a deactivated user tries to sign in
+var ErrUserDeactivated = errors.New("user account has been deactivated")
+
+//ErrUserSuspended is returned when a suspended user tries to sign in
+var ErrUserSuspended = errors.New("user account is suspended")
+
+//Maximum lengths, in characters, of the extended profile fields
+const (
+	MaxTitleLength        = 100
+	MaxBioLength          = 500
+	MaxPronounsLength     = 30
+	MaxStatusEmojiLength  = 32
+	MaxStatusTextLength   = 100
+	maxTimeZoneNameLength = 64
+)
+
 //User represents a user account in the database
 type User struct {
 	ID        int64  `json:"id"`
@@ -28,6 +51,25 @@ type User struct {
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
 	PhotoURL  string `json:"photoURL"`
+	Role      string `json:"role"`
+	//Deactivated users can't sign in, but their profiles stay readable
+	Deactivated bool `json:"deactivated"`
+	//SuspendedUntil is the time a suspension is lifted, or nil if the user isn't suspended
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+	Title          string     `json:"title"`
+	Bio            string     `json:"bio"`
+	//TimeZone is an IANA time zone name, such as "America/Los_Angeles"
+	TimeZone     string        `json:"timeZone"`
+	Pronouns     string        `json:"pronouns"`
+	CustomStatus *CustomStatus `json:"customStatus,omitempty"`
+}
+
+//CustomStatus is a short status message a user can set on their profile
+type CustomStatus struct {
+	Emoji string `json:"emoji"`
+	Text  string `json:"text"`
+	//ExpiresAt is when the status is cleared, or nil if it never expires
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
 
 //Credentials represents user sign-in credentials
@@ -44,12 +86,28 @@ type NewUser struct {
 	UserName     string `json:"userName"`
 	FirstName    string `json:"firstName"`
 	LastName     string `json:"lastName"`
+	//InviteCode is required when registration is invite-only
+	InviteCode string `json:"inviteCode,omitempty"`
 }
 
-//Updates represents allowed updates to a user profile
+//Updates represents allowed updates to a user profile.
+//First and last name must be updated together, all other
+//fields are only updated when present, and set to an empty
+//value to clear them. An empty CustomStatus clears the status.
 type Updates struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
+	FirstName    string        `json:"firstName"`
+	LastName     string        `json:"lastName"`
+	Title        *string       `json:"title,omitempty"`
+	Bio          *string       `json:"bio,omitempty"`
+	TimeZone     *string       `json:"timeZone,omitempty"`
+	Pronouns     *string       `json:"pronouns,omitempty"`
+	CustomStatus *CustomStatus `json:"customStatus,omitempty"`
+}
+
+//Status represents the account status of a user
+type Status struct {
+	Deactivated    bool       `json:"deactivated"`
+	SuspendedUntil *time.Time `json:"suspendedUntil"`
 }
 
 //PassReset holds an email for password reset
@@ -123,6 +181,7 @@ func (nu *NewUser) ToUser() (*User, error) {
 		FirstName: nu.FirstName,
 		LastName:  nu.LastName,
 		PhotoURL:  getPhotoURL(nu.Email),
+		Role:      RoleMember,
 	}
 
 	if err := user.SetPassword(nu.Password); err != nil {
@@ -156,6 +215,11 @@ func (u *User) FullName() string {
 
 }
 
+//SearchFields returns the fields the user is found by in search
+func (u *User) SearchFields() []string {
+	return []string{u.FirstName, u.LastName, u.UserName}
+}
+
 //SetPassword hashes the password and stores it in the PassHash field
 func (u *User) SetPassword(password string) error {
 	//TODO: use the bcrypt package to generate a new hash of the password
@@ -187,11 +251,115 @@ func (u *User) ApplyUpdates(updates *Updates) error {
 	//TODO: set the fields of `u` to the values of the related
 	//field in the `updates` struct
 
-	if updates.FirstName == "" || updates.LastName == "" {
+	if err := updates.Validate(time.Now()); err != nil {
+		return err
+	}
+
+	if updates.hasNames() {
+		u.FirstName = updates.FirstName
+		u.LastName = updates.LastName
+	}
+	if updates.Title != nil {
+		u.Title = *updates.Title
+	}
+	if updates.Bio != nil {
+		u.Bio = *updates.Bio
+	}
+	if updates.TimeZone != nil {
+		u.TimeZone = *updates.TimeZone
+	}
+	if updates.Pronouns != nil {
+		u.Pronouns = *updates.Pronouns
+	}
+	if updates.CustomStatus != nil {
+		u.CustomStatus = updates.CustomStatus
+		if updates.CustomStatus.IsEmpty() {
+			u.CustomStatus = nil
+		}
+	}
+	return nil
+}
+
+//hasNames returns true if the updates change the first or last name
+func (up *Updates) hasNames() bool {
+	return up.FirstName != "" || up.LastName != ""
+}
+
+//hasProfile returns true if the updates change any extended profile field
+func (up *Updates) hasProfile() bool {
+	return up.Title != nil || up.Bio != nil || up.TimeZone != nil ||
+		up.Pronouns != nil || up.CustomStatus != nil
+}
+
+//Validate returns an error if the updates are invalid at time `now`
+func (up *Updates) Validate(now time.Time) error {
+	if !up.hasNames() && !up.hasProfile() {
+		return errors.New("Invalid update to user, no fields provided")
+	}
+	if up.hasNames() && (up.FirstName == "" || up.LastName == "") {
 		return errors.New("Invalid update to user, first name or last name not provided")
 	}
+	if up.Title != nil && utf8.RuneCountInString(*up.Title) > MaxTitleLength {
+		return fmt.Errorf("Title must be at most %d characters", MaxTitleLength)
+	}
+	if up.Bio != nil && utf8.RuneCountInString(*up.Bio) > MaxBioLength {
+		return fmt.Errorf("Bio must be at most %d characters", MaxBioLength)
+	}
+	if up.Pronouns != nil && utf8.RuneCountInString(*up.Pronouns) > MaxPronounsLength {
+		return fmt.Errorf("Pronouns must be at most %d characters", MaxPronounsLength)
+	}
+	if up.TimeZone != nil && *up.TimeZone != "" {
+		//time.LoadLocation treats "Local" as the server's zone, which means nothing to other users
+		if *up.TimeZone == "Local" || len(*up.TimeZone) > maxTimeZoneNameLength {
+			return fmt.Errorf("Unknown time zone %s", *up.TimeZone)
+		}
+		if _, err := time.LoadLocation(*up.TimeZone); err != nil {
+			return fmt.Errorf("Unknown time zone %s", *up.TimeZone)
+		}
+	}
+	if up.CustomStatus != nil {
+		return up.CustomStatus.Validate(now)
+	}
+	return nil
+}
+
+//IsEmpty returns true if the status has neither an emoji nor text
+func (cs *CustomStatus) IsEmpty() bool {
+	return cs.Emoji == "" && cs.Text == ""
+}
 
-	u.FirstName = updates.FirstName
-	u.LastName = updates.LastName
+//IsExpired returns true if the status has expired at time `now`
+func (cs *CustomStatus) IsExpired(now time.Time) bool {
+	return cs.ExpiresAt != nil && !now.Before(*cs.ExpiresAt)
+}
+
+//Validate returns an error if the custom status is invalid at time `now`
+func (cs *CustomStatus) Validate(now time.Time) error {
+	if utf8.RuneCountInString(cs.Emoji) > MaxStatusEmojiLength {
+		return fmt.Errorf("Status emoji must be at most %d characters", MaxStatusEmojiLength)
+	}
+	if utf8.RuneCountInString(cs.Text) > MaxStatusTextLength {
+		return fmt.Errorf("Status text must be at most %d characters", MaxStatusTextLength)
+	}
+	if !cs.IsEmpty() && cs.IsExpired(now) {
+		return errors.New("Status expiry must be in the future")
+	}
+	return nil
+}
+
+//IsAdmin returns true if the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+//CheckActive returns ErrUserDeactivated or ErrUserSuspended if the
+//user isn't allowed to sign in at time `now`, or nil if they are
+func (u *User) CheckActive(now time.Time) error {
+	if u.Deactivated {
+		return ErrUserDeactivated
+	}
+	if u.SuspendedUntil != nil && now.Before(*u.SuspendedUntil) {
+		return ErrUserSuspended
+	}
 	return nil
 }
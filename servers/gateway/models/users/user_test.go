@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -411,3 +412,130 @@ func TestApplyUpdates(t *testing.T) {
 
 	}
 }
+
+func TestCheckActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	cases := []struct {
+		name          string
+		u             *User
+		expectedError error
+	}{
+		{
+			"Active User",
+			&User{},
+			nil,
+		},
+		{
+			"Deactivated User",
+			&User{Deactivated: true},
+			ErrUserDeactivated,
+		},
+		{
+			"Suspended User",
+			&User{SuspendedUntil: &future},
+			ErrUserSuspended,
+		},
+		{
+			"Suspension Lifted",
+			&User{SuspendedUntil: &past},
+			nil,
+		},
+		{
+			"Deactivated And Suspended User",
+			&User{Deactivated: true, SuspendedUntil: &future},
+			ErrUserDeactivated,
+		},
+	}
+
+	for _, c := range cases {
+		if err := c.u.CheckActive(now); err != c.expectedError {
+			t.Errorf("case %s: expected error %v but got %v", c.name, c.expectedError, err)
+		}
+	}
+}
+
+func TestUpdatesValidate(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	stringPtr := func(s string) *string { return &s }
+
+	cases := []struct {
+		name        string
+		updates     *Updates
+		expectError bool
+	}{
+		{
+			"Only Profile Fields",
+			&Updates{Title: stringPtr("Engineer"), Pronouns: stringPtr("they/them")},
+			false,
+		},
+		{
+			"No Fields",
+			&Updates{},
+			true,
+		},
+		{
+			"Clear Title",
+			&Updates{Title: stringPtr("")},
+			false,
+		},
+		{
+			"Title Too Long",
+			&Updates{Title: stringPtr(strings.Repeat("a", MaxTitleLength+1))},
+			true,
+		},
+		{
+			"Bio Counts Characters Not Bytes",
+			&Updates{Bio: stringPtr(strings.Repeat("é", MaxBioLength))},
+			false,
+		},
+		{
+			"Valid Time Zone",
+			&Updates{TimeZone: stringPtr("Asia/Tokyo")},
+			false,
+		},
+		{
+			"Unknown Time Zone",
+			&Updates{TimeZone: stringPtr("Mars/Olympus_Mons")},
+			true,
+		},
+		{
+			"Local Time Zone",
+			&Updates{TimeZone: stringPtr("Local")},
+			true,
+		},
+		{
+			"Custom Status",
+			&Updates{CustomStatus: &CustomStatus{Emoji: "🌴", Text: "On vacation", ExpiresAt: &future}},
+			false,
+		},
+		{
+			"Custom Status Already Expired",
+			&Updates{CustomStatus: &CustomStatus{Text: "In a meeting", ExpiresAt: &past}},
+			true,
+		},
+		{
+			"Clear Custom Status",
+			&Updates{CustomStatus: &CustomStatus{}},
+			false,
+		},
+		{
+			"Only First Name",
+			&Updates{FirstName: "Competent", Bio: stringPtr("Hi")},
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		err := c.updates.Validate(now)
+		if c.expectError && err == nil {
+			t.Errorf("case %s: expected error but got nothing", c.name)
+		}
+		if !c.expectError && err != nil {
+			t.Errorf("case %s: unexpected error: %v", c.name, err)
+		}
+	}
+}
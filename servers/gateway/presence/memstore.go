@@ -0,0 +1,131 @@
+package presence
+
+import (
+	"sync"
+	"time"
+)
+
+//memConn is the status of a single connection held in a MemStore
+type memConn struct {
+	status  string
+	expires time.Time
+}
+
+//MemStore represents an in-process memory presence store.
+//This should be used only for testing and prototyping, as presence
+//is not shared with other gateway instances.
+type MemStore struct {
+	connectionTTL time.Duration
+	conns         map[int64]map[string]*memConn
+	statuses      map[int64]string
+	lastSeen      map[int64]time.Time
+	subscribers   []chan []byte
+	mx            sync.Mutex
+}
+
+//NewMemStore constructs and returns a new MemStore. Connections that
+//aren't updated again within the connectionTTL are considered gone.
+func NewMemStore(connectionTTL time.Duration) *MemStore {
+	return &MemStore{
+		connectionTTL: connectionTTL,
+		conns:         make(map[int64]map[string]*memConn),
+		statuses:      make(map[int64]string),
+		lastSeen:      make(map[int64]time.Time),
+	}
+}
+
+//Update sets the status of one of the user's connections.
+//Setting a connection to StatusOffline removes it.
+//Update returns the user's overall presence, and whether it changed.
+func (ms *MemStore) Update(userID int64, connID string, status string) (*Presence, bool, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	now := time.Now()
+	if status == StatusOffline {
+		delete(ms.conns[userID], connID)
+	} else {
+		if ms.conns[userID] == nil {
+			ms.conns[userID] = make(map[string]*memConn)
+		}
+		ms.conns[userID][connID] = &memConn{status, now.Add(ms.connectionTTL)}
+	}
+	ms.lastSeen[userID] = now
+
+	presence := ms.get(userID, now)
+	prev, found := ms.statuses[userID]
+	if !found {
+		prev = StatusOffline
+	}
+	ms.statuses[userID] = presence.Status
+	return presence, prev != presence.Status, nil
+}
+
+//Get returns the presence of each user, in the same order as the user IDs
+func (ms *MemStore) Get(userIDs ...int64) ([]*Presence, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	now := time.Now()
+	presences := make([]*Presence, len(userIDs))
+	for i, userID := range userIDs {
+		presences[i] = ms.get(userID, now)
+	}
+	return presences, nil
+}
+
+//Sweep finds the users whose presence changed because their connections
+//expired, and returns their new presence
+func (ms *MemStore) Sweep() ([]*Presence, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	now := time.Now()
+	changed := []*Presence{}
+	for userID, prev := range ms.statuses {
+		presence := ms.get(userID, now)
+		if presence.Status != prev {
+			ms.statuses[userID] = presence.Status
+			changed = append(changed, presence)
+		}
+	}
+	return changed, nil
+}
+
+//get returns the presence of the user, dropping expired connections.
+//The caller must hold the lock.
+func (ms *MemStore) get(userID int64, now time.Time) *Presence {
+	statuses := []string{}
+	for connID, conn := range ms.conns[userID] {
+		if now.After(conn.expires) {
+			delete(ms.conns[userID], connID)
+			continue
+		}
+		statuses = append(statuses, conn.status)
+	}
+	presence := &Presence{UserID: userID, Status: combine(statuses)}
+	if lastSeen, found := ms.lastSeen[userID]; found {
+		presence.LastSeen = &lastSeen
+	}
+	return presence
+}
+
+//Publish shares an encoded event with every subscriber
+func (ms *MemStore) Publish(event []byte) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for _, subscriber := range ms.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			//drop the event rather than block publishers on a slow subscriber
+		}
+	}
+	return nil
+}
+
+//Subscribe returns a channel of the events published to this store
+func (ms *MemStore) Subscribe() (<-chan []byte, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	subscriber := make(chan []byte, 64)
+	ms.subscribers = append(ms.subscribers, subscriber)
+	return subscriber, nil
+}
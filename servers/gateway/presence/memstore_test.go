@@ -0,0 +1,168 @@
+package presence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCombine(t *testing.T) {
+	cases := []struct {
+		name     string
+		statuses []string
+		expected string
+	}{
+		{
+			"No Connections",
+			[]string{},
+			StatusOffline,
+		},
+		{
+			"All Away",
+			[]string{StatusAway, StatusAway},
+			StatusAway,
+		},
+		{
+			"Any Online",
+			[]string{StatusAway, StatusOnline},
+			StatusOnline,
+		},
+	}
+
+	for _, c := range cases {
+		if got := combine(c.statuses); got != c.expected {
+			t.Errorf("case %s: expected %s but got %s", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestMemStoreUpdate(t *testing.T) {
+	store := NewMemStore(time.Minute)
+
+	cases := []struct {
+		name            string
+		connID          string
+		status          string
+		expectedStatus  string
+		expectedChanged bool
+	}{
+		{
+			"First Connection",
+			"a",
+			StatusOnline,
+			StatusOnline,
+			true,
+		},
+		{
+			"Second Connection",
+			"b",
+			StatusAway,
+			StatusOnline,
+			false,
+		},
+		{
+			"Online Connection Goes Away",
+			"a",
+			StatusAway,
+			StatusAway,
+			true,
+		},
+		{
+			"Away Connection Closes",
+			"b",
+			StatusOffline,
+			StatusAway,
+			false,
+		},
+		{
+			"Last Connection Closes",
+			"a",
+			StatusOffline,
+			StatusOffline,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		presence, changed, err := store.Update(1, c.connID, c.status)
+		if err != nil {
+			t.Fatalf("case %s: unexpected error: %v", c.name, err)
+		}
+		if presence.Status != c.expectedStatus {
+			t.Errorf("case %s: expected status %s but got %s", c.name, c.expectedStatus, presence.Status)
+		}
+		if changed != c.expectedChanged {
+			t.Errorf("case %s: expected changed to be %t but got %t", c.name, c.expectedChanged, changed)
+		}
+		if presence.LastSeen == nil {
+			t.Errorf("case %s: expected last seen to be set", c.name)
+		}
+	}
+}
+
+func TestMemStoreGet(t *testing.T) {
+	store := NewMemStore(time.Millisecond)
+	if _, _, err := store.Update(1, "a", StatusOnline); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	presences, err := store.Get(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(presences) != 2 || presences[0].UserID != 1 || presences[1].UserID != 2 {
+		t.Fatalf("expected presence of users 1 and 2 in order, got %v", presences)
+	}
+	if presences[0].Status != StatusOnline {
+		t.Errorf("expected user 1 to be %s but got %s", StatusOnline, presences[0].Status)
+	}
+	if presences[1].Status != StatusOffline || presences[1].LastSeen != nil {
+		t.Errorf("expected unknown user to be offline and never seen")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	presences, _ = store.Get(1)
+	if presences[0].Status != StatusOffline {
+		t.Errorf("expected expired connection to be offline but got %s", presences[0].Status)
+	}
+}
+
+func TestMemStorePublish(t *testing.T) {
+	store := NewMemStore(time.Minute)
+	events, err := store.Subscribe()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Publish([]byte("event")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case event := <-events:
+		if string(event) != "event" {
+			t.Errorf("expected event but got %s", event)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for published event")
+	}
+}
+
+func TestMemStoreSweep(t *testing.T) {
+	store := NewMemStore(time.Millisecond)
+	if _, _, err := store.Update(1, "a", StatusOnline); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed, _ := store.Sweep(); len(changed) != 0 {
+		t.Errorf("expected no changes before the connection expires, got %v", changed)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	changed, err := store.Sweep()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changed) != 1 || changed[0].UserID != 1 || changed[0].Status != StatusOffline {
+		t.Errorf("expected user 1 to go offline, got %v", changed)
+	}
+	if changed, _ = store.Sweep(); len(changed) != 0 {
+		t.Errorf("expected each change to be found once, got %v", changed)
+	}
+}
@@ -0,0 +1,56 @@
+package presence
+
+import (
+	"time"
+)
+
+//StatusOnline means the user has at least one active connection
+const StatusOnline = "online"
+
+//StatusAway means the user is connected, but idle on every connection
+const StatusAway = "away"
+
+//StatusOffline means the user has no connections
+const StatusOffline = "offline"
+
+//EventPresenceChange is the type of event sent to clients when a user's presence changes
+const EventPresenceChange = "presence-change"
+
+//Presence represents whether a user is online, away or offline
+type Presence struct {
+	UserID   int64      `json:"userID"`
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+}
+
+//Event is sent to interested users when a user's presence changes.
+//UserIDs are who it is sent to, and are left out of what they are sent.
+type Event struct {
+	Type     string    `json:"type"`
+	Presence *Presence `json:"presence"`
+	UserIDs  []int64   `json:"userIDs,omitempty"`
+}
+
+//IsValidStatus returns true if the status is one a connection can be set to
+func IsValidStatus(status string) bool {
+	switch status {
+	case StatusOnline, StatusAway, StatusOffline:
+		return true
+	}
+	return false
+}
+
+//combine returns the overall status of a user from the status of each
+//of their connections. The user is online if any connection is online.
+func combine(statuses []string) string {
+	combined := StatusOffline
+	for _, status := range statuses {
+		switch status {
+		case StatusOnline:
+			return StatusOnline
+		case StatusAway:
+			combined = StatusAway
+		}
+	}
+	return combined
+}
@@ -0,0 +1,219 @@
+package presence
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//redisChannel is the redis pub/sub channel presence events are published to
+const redisChannel = "presence"
+
+//usersRedisKey is the redis key for the set of users who aren't offline
+const usersRedisKey = "presence:users"
+
+//RedisStore represents a presence.Store backed by redis,
+//which shares presence between every gateway instance.
+type RedisStore struct {
+	//Redis client used to talk to redis server.
+	Client *redis.Client
+	//Connections that aren't updated again within this duration expire,
+	//so connections held by a gateway instance that went away don't
+	//keep users online forever.
+	ConnectionTTL time.Duration
+}
+
+//NewRedisStore constructs a new RedisStore
+func NewRedisStore(client *redis.Client, connectionTTL time.Duration) *RedisStore {
+	return &RedisStore{
+		Client:        client,
+		ConnectionTTL: connectionTTL,
+	}
+}
+
+//Update sets the status of one of the user's connections.
+//Setting a connection to StatusOffline removes it.
+//Update returns the user's overall presence, and whether it changed.
+func (rs *RedisStore) Update(userID int64, connID string, status string) (*Presence, bool, error) {
+	pipe := rs.Client.TxPipeline()
+	if status == StatusOffline {
+		pipe.Del(getConnRedisKey(userID, connID))
+		pipe.SRem(getConnsRedisKey(userID), connID)
+	} else {
+		pipe.Set(getConnRedisKey(userID, connID), status, rs.ConnectionTTL)
+		pipe.SAdd(getConnsRedisKey(userID), connID)
+	}
+	pipe.Set(getLastSeenRedisKey(userID), time.Now().Unix(), 0)
+	if _, err := pipe.Exec(); err != nil {
+		return nil, false, fmt.Errorf("Error updating presence in redis: %v", err)
+	}
+
+	presence, err := rs.get(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	changed, err := rs.saveStatus(presence)
+	if err != nil {
+		return nil, false, err
+	}
+	return presence, changed, nil
+}
+
+//Sweep finds the users whose presence changed because their connections
+//expired, and returns their new presence. Every gateway instance may sweep,
+//since saving the status with GETSET means only one of them sees each change.
+func (rs *RedisStore) Sweep() ([]*Presence, error) {
+	members, err := rs.Client.SMembers(usersRedisKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting present users from redis: %v", err)
+	}
+	changed := []*Presence{}
+	for _, member := range members {
+		userID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			rs.Client.SRem(usersRedisKey, member)
+			continue
+		}
+		presence, err := rs.get(userID)
+		if err != nil {
+			return nil, err
+		}
+		found, err := rs.saveStatus(presence)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			changed = append(changed, presence)
+		}
+	}
+	return changed, nil
+}
+
+//saveStatus saves the user's overall status, and returns whether it is
+//different from the status saved before. Users who aren't offline are
+//kept in a set, so that Sweep can find them once their connections expire.
+func (rs *RedisStore) saveStatus(presence *Presence) (bool, error) {
+	prev, err := rs.Client.GetSet(getStatusRedisKey(presence.UserID), presence.Status).Result()
+	if err == redis.Nil {
+		prev = StatusOffline
+	} else if err != nil {
+		return false, fmt.Errorf("Error saving presence status in redis: %v", err)
+	}
+	if presence.Status == StatusOffline {
+		err = rs.Client.SRem(usersRedisKey, presence.UserID).Err()
+	} else {
+		err = rs.Client.SAdd(usersRedisKey, presence.UserID).Err()
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error saving present users in redis: %v", err)
+	}
+	return prev != presence.Status, nil
+}
+
+//Get returns the presence of each user, in the same order as the user IDs
+func (rs *RedisStore) Get(userIDs ...int64) ([]*Presence, error) {
+	presences := make([]*Presence, len(userIDs))
+	for i, userID := range userIDs {
+		presence, err := rs.get(userID)
+		if err != nil {
+			return nil, err
+		}
+		presences[i] = presence
+	}
+	return presences, nil
+}
+
+//get returns the presence of the user, and forgets connections that have expired
+func (rs *RedisStore) get(userID int64) (*Presence, error) {
+	presence := &Presence{UserID: userID, Status: StatusOffline}
+	lastSeen, err := rs.Client.Get(getLastSeenRedisKey(userID)).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("Error getting last seen from redis: %v", err)
+	}
+	if err == nil {
+		seen := time.Unix(lastSeen, 0)
+		presence.LastSeen = &seen
+	}
+
+	connIDs, err := rs.Client.SMembers(getConnsRedisKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting connections from redis: %v", err)
+	}
+	if len(connIDs) == 0 {
+		return presence, nil
+	}
+	keys := make([]string, len(connIDs))
+	for i, connID := range connIDs {
+		keys[i] = getConnRedisKey(userID, connID)
+	}
+	values, err := rs.Client.MGet(keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting connection status from redis: %v", err)
+	}
+
+	statuses := []string{}
+	expired := []interface{}{}
+	for i, value := range values {
+		status, ok := value.(string)
+		if !ok {
+			expired = append(expired, connIDs[i])
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	if len(expired) > 0 {
+		if err := rs.Client.SRem(getConnsRedisKey(userID), expired...).Err(); err != nil {
+			log.Printf("error removing expired connections for user %d: %v", userID, err)
+		}
+	}
+	presence.Status = combine(statuses)
+	return presence, nil
+}
+
+//Publish shares an encoded event with every gateway instance
+func (rs *RedisStore) Publish(event []byte) error {
+	if err := rs.Client.Publish(redisChannel, string(event)).Err(); err != nil {
+		return fmt.Errorf("Error publishing presence event: %v", err)
+	}
+	return nil
+}
+
+//Subscribe returns a channel of the events published by every gateway instance
+func (rs *RedisStore) Subscribe() (<-chan []byte, error) {
+	pubsub := rs.Client.Subscribe(redisChannel)
+	if _, err := pubsub.Receive(); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("Error subscribing to presence events: %v", err)
+	}
+	events := make(chan []byte)
+	go func() {
+		defer close(events)
+		for message := range pubsub.Channel() {
+			events <- []byte(message.Payload)
+		}
+	}()
+	return events, nil
+}
+
+//getConnRedisKey returns the redis key holding the status of a single connection
+func getConnRedisKey(userID int64, connID string) string {
+	return "presence:" + strconv.FormatInt(userID, 10) + ":conn:" + connID
+}
+
+//getConnsRedisKey returns the redis key for the set of a user's connection IDs
+func getConnsRedisKey(userID int64) string {
+	return "presence:" + strconv.FormatInt(userID, 10) + ":conns"
+}
+
+//getStatusRedisKey returns the redis key holding the last known overall status of a user
+func getStatusRedisKey(userID int64) string {
+	return "presence:" + strconv.FormatInt(userID, 10) + ":status"
+}
+
+//getLastSeenRedisKey returns the redis key holding when a user was last seen
+func getLastSeenRedisKey(userID int64) string {
+	return "presence:" + strconv.FormatInt(userID, 10) + ":lastseen"
+}
@@ -0,0 +1,48 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+)
+
+func TestRedisStoreSweep(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("error starting redis server: %v", err)
+	}
+	defer server.Close()
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr()}), time.Minute)
+
+	if _, changed, err := store.Update(1, "a", StatusOnline); err != nil || !changed {
+		t.Fatalf("expected user 1 to come online, got %v, %v", changed, err)
+	}
+	if _, _, err := store.Update(2, "b", StatusAway); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed, err := store.Sweep(); err != nil || len(changed) != 0 {
+		t.Errorf("expected no changes before the connections expire, got %v, %v", changed, err)
+	}
+
+	//user 2 keeps their connection, user 1's gateway goes away
+	server.FastForward(45 * time.Second)
+	if _, _, err := store.Update(2, "b", StatusAway); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.FastForward(30 * time.Second)
+	changed, err := store.Sweep()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changed) != 1 || changed[0].UserID != 1 || changed[0].Status != StatusOffline {
+		t.Errorf("expected user 1 to go offline, got %v", changed)
+	}
+	if changed, _ = store.Sweep(); len(changed) != 0 {
+		t.Errorf("expected each change to be found once, got %v", changed)
+	}
+	if members, _ := server.Members(usersRedisKey); len(members) != 1 || members[0] != "2" {
+		t.Errorf("expected only user 2 to be kept for sweeping, got %v", members)
+	}
+}
@@ -0,0 +1,23 @@
+package presence
+
+//Store tracks the presence of users across every gateway instance
+type Store interface {
+	//Update sets the status of one of the user's connections.
+	//Setting a connection to StatusOffline removes it.
+	//Update returns the user's overall presence, and whether it changed.
+	Update(userID int64, connID string, status string) (*Presence, bool, error)
+
+	//Get returns the presence of each user, in the same order as the user IDs
+	Get(userIDs ...int64) ([]*Presence, error)
+
+	//Sweep finds the users whose presence changed because their connections
+	//expired, such as when the gateway instance holding them went away,
+	//and returns their new presence. Each change is only returned once.
+	Sweep() ([]*Presence, error)
+
+	//Publish shares an encoded event with every gateway instance
+	Publish(event []byte) error
+
+	//Subscribe returns a channel of the events published by every gateway instance
+	Subscribe() (<-chan []byte, error)
+}
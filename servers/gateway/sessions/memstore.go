@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -11,13 +12,16 @@ import (
 //This should be used only for testing and prototyping.
 //Production systems should use a shared server store like redis
 type MemStore struct {
-	entries *cache.Cache
+	entries      *cache.Cache
+	userSessions map[int64][]SessionID
+	mx           sync.Mutex
 }
 
 //NewMemStore constructs and returns a new MemStore
 func NewMemStore(sessionDuration time.Duration, purgeInterval time.Duration) *MemStore {
 	return &MemStore{
-		entries: cache.New(sessionDuration, purgeInterval),
+		entries:      cache.New(sessionDuration, purgeInterval),
+		userSessions: make(map[int64][]SessionID),
 	}
 }
 
@@ -70,3 +74,22 @@ func (ms *MemStore) SavePass(email string, resetPass string) error {
 func (ms *MemStore) GetReset(email string) (string, error) {
 	return "", nil
 }
+
+//SaveUserSession records that the SessionID belongs to the given user
+func (ms *MemStore) SaveUserSession(userID int64, sid SessionID) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.userSessions[userID] = append(ms.userSessions[userID], sid)
+	return nil
+}
+
+//DeleteUserSessions deletes the state of every session belonging to the given user
+func (ms *MemStore) DeleteUserSessions(userID int64) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for _, sid := range ms.userSessions[userID] {
+		ms.entries.Delete(sid.String())
+	}
+	delete(ms.userSessions, userID)
+	return nil
+}
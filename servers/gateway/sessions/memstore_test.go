@@ -75,3 +75,48 @@ func TestMemStoreSaveUnmarshalble(t *testing.T) {
 		t.Error("expected error when attempting to save a session state with an unmarshalable field")
 	}
 }
+
+func TestMemStoreDeleteUserSessions(t *testing.T) {
+	store := NewMemStore(time.Hour, time.Minute)
+	state := map[string]string{"user": "test"}
+	stateRet := map[string]string{}
+
+	userSids := []SessionID{}
+	for i := 0; i < 2; i++ {
+		sid, err := NewSessionID("test key")
+		if err != nil {
+			t.Fatalf("error generating new SessionID: %v", err)
+		}
+		if err := store.Save(sid, state); err != nil {
+			t.Fatalf("error saving state: %v", err)
+		}
+		if err := store.SaveUserSession(1, sid); err != nil {
+			t.Fatalf("error saving user session: %v", err)
+		}
+		userSids = append(userSids, sid)
+	}
+
+	otherSid, err := NewSessionID("test key")
+	if err != nil {
+		t.Fatalf("error generating new SessionID: %v", err)
+	}
+	if err := store.Save(otherSid, state); err != nil {
+		t.Fatalf("error saving state: %v", err)
+	}
+	if err := store.SaveUserSession(2, otherSid); err != nil {
+		t.Fatalf("error saving user session: %v", err)
+	}
+
+	if err := store.DeleteUserSessions(1); err != nil {
+		t.Fatalf("error deleting user sessions: %v", err)
+	}
+
+	for _, sid := range userSids {
+		if err := store.Get(sid, &stateRet); err != ErrStateNotFound {
+			t.Errorf("incorrect error when getting state of deleted user session: expected %v but got %v", ErrStateNotFound, err)
+		}
+	}
+	if err := store.Get(otherSid, &stateRet); err != nil {
+		t.Errorf("unexpected error getting state of another user's session: %v", err)
+	}
+}
@@ -130,6 +130,64 @@ func (rs *RedisStore) GetReset(email string) (string, error) {
 	return rs.Client.Get(email).Result()
 }
 
+//SaveUserSession records that the SessionID belongs to the given user, and
+//forgets the user's sessions that have since expired so the set doesn't grow
+//with every sign in
+func (rs *RedisStore) SaveUserSession(userID int64, sid SessionID) error {
+	userKey := getUserRedisKey(userID)
+	sids, err := rs.Client.SMembers(userKey).Result()
+	if err != nil {
+		return fmt.Errorf("Error getting user sessions from redis: %v", err)
+	}
+	pipeline := rs.Client.Pipeline()
+	exists := make([]*redis.IntCmd, len(sids))
+	for i, member := range sids {
+		exists[i] = pipeline.Exists(SessionID(member).getRedisKey())
+	}
+	if len(sids) > 0 {
+		if _, err := pipeline.Exec(); err != nil {
+			return fmt.Errorf("Error checking user sessions in redis: %v", err)
+		}
+	}
+	expired := []interface{}{}
+	for i, member := range sids {
+		if exists[i].Val() == 0 {
+			expired = append(expired, member)
+		}
+	}
+	pipeline = rs.Client.TxPipeline()
+	if len(expired) > 0 {
+		pipeline.SRem(userKey, expired...)
+	}
+	pipeline.SAdd(userKey, sid.String())
+	if _, err := pipeline.Exec(); err != nil {
+		return fmt.Errorf("Error saving user session in redis: %v", err)
+	}
+	return nil
+}
+
+//DeleteUserSessions deletes the state of every session belonging to the given user
+func (rs *RedisStore) DeleteUserSessions(userID int64) error {
+	userKey := getUserRedisKey(userID)
+	sids, err := rs.Client.SMembers(userKey).Result()
+	if err != nil {
+		return fmt.Errorf("Error getting user sessions from redis: %v", err)
+	}
+	keys := []string{userKey}
+	for _, sid := range sids {
+		keys = append(keys, SessionID(sid).getRedisKey())
+	}
+	if err := rs.Client.Del(keys...).Err(); err != nil {
+		return fmt.Errorf("Error deleting user sessions: %v", err)
+	}
+	return nil
+}
+
+//getUserRedisKey returns the redis key for the set of SessionIDs belonging to a user
+func getUserRedisKey(userID int64) string {
+	return "usid:" + strconv.FormatInt(userID, 10)
+}
+
 //getRedisKey() returns the redis key to use for the SessionID
 func (sid SessionID) getRedisKey() string {
 	//convert the SessionID to a string and add the prefix "sid:" to keep
@@ -8,6 +8,7 @@ import (
 
 	"os"
 
+	"github.com/alicebob/miniredis"
 	"github.com/go-redis/redis"
 )
 
@@ -81,3 +82,52 @@ func TestRedisStore(t *testing.T) {
 		t.Fatalf("incorrect error when getting state that was deleted: expected %v but got %v", ErrStateNotFound, err)
 	}
 }
+
+func TestRedisStoreUserSessions(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("error starting redis server: %v", err)
+	}
+	defer server.Close()
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr()}), time.Hour)
+
+	expired, _ := NewSessionID("test key")
+	live, _ := NewSessionID("test key")
+	for _, sid := range []SessionID{expired, live} {
+		if err := store.Save(sid, "state"); err != nil {
+			t.Fatalf("error saving state: %v", err)
+		}
+		if err := store.SaveUserSession(1, sid); err != nil {
+			t.Fatalf("error saving user session: %v", err)
+		}
+	}
+
+	//sessions that expired are forgotten the next time the user signs in
+	server.Del(expired.getRedisKey())
+	next, _ := NewSessionID("test key")
+	if err := store.Save(next, "state"); err != nil {
+		t.Fatalf("error saving state: %v", err)
+	}
+	if err := store.SaveUserSession(1, next); err != nil {
+		t.Fatalf("error saving user session: %v", err)
+	}
+	members, err := server.Members(getUserRedisKey(1))
+	if err != nil {
+		t.Fatalf("error getting user sessions: %v", err)
+	}
+	if len(members) != 2 {
+		t.Errorf("expected only the live sessions to be kept, got %v", members)
+	}
+	for _, member := range members {
+		if member == expired.String() {
+			t.Errorf("expected the expired session to be removed, got %v", members)
+		}
+	}
+
+	if err := store.DeleteUserSessions(1); err != nil {
+		t.Fatalf("error deleting user sessions: %v", err)
+	}
+	if server.Exists(live.getRedisKey()) || server.Exists(next.getRedisKey()) || server.Exists(getUserRedisKey(1)) {
+		t.Error("expected every session of the user to be deleted")
+	}
+}
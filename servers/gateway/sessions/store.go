@@ -40,4 +40,10 @@ type Store interface {
 
 	//GetReset gets the reset password for an email
 	GetReset(email string) (string, error)
+
+	//SaveUserSession records that the SessionID belongs to the given user
+	SaveUserSession(userID int64, sid SessionID) error
+
+	//DeleteUserSessions deletes the state of every session belonging to the given user
+	DeleteUserSessions(userID int64) error
 }
@@ -6,15 +6,22 @@ export MYSQL_ADDR=usersdb:3306
 export REDISADDR=sessionServer:6379
 export SUMMARYADDR=summary:80
 export MESSAGESADDR=messages:80
+export SEARCHADDR=search:80
 export SESSIONKEY=$(openssl rand -hex 32)
 
 export MQADDR=messagequeue:5672
 export MQNAME=messagequeue
 
+export REGISTRATIONMODE=open
+export ALLOWEDDOMAINS=
+export EXPORTDIR=/exports
+export SEARCHSNAPSHOT=/search/users.snapshot
+
 export DSN="root:$MYSQL_ROOT_PASSWORD@tcp($MYSQL_ADDR)/$MYSQL_DATABASE?parseTime=true"
 
 docker rm -f summary
 docker rm -f messages
+docker rm -f search
 docker rm -f gateway
 docker rm -f usersdb
 docker rm -f sessionServer
@@ -51,6 +58,8 @@ docker run -d \
 --name gateway \
 -p 443:443 \
 -v /etc/letsencrypt:/etc/letsencrypt:ro \
+-v /gateway/exports:$EXPORTDIR \
+-v /gateway/search:/search \
 -e TLSKEY=/etc/letsencrypt/live/api.ask710.me/privkey.pem \
 -e TLSCERT=/etc/letsencrypt/live/api.ask710.me/fullchain.pem \
 -e DSN=$DSN \
@@ -58,8 +67,13 @@ docker run -d \
 -e REDISADDR=$REDISADDR \
 -e SUMMARYADDR=$SUMMARYADDR \
 -e MESSAGESADDR=$MESSAGESADDR \
+-e SEARCHADDR=$SEARCHADDR \
 -e MQADDR=$MQADDR \
 -e MQNAME=$MQNAME \
+-e REGISTRATIONMODE=$REGISTRATIONMODE \
+-e ALLOWEDDOMAINS=$ALLOWEDDOMAINS \
+-e EXPORTDIR=$EXPORTDIR \
+-e SEARCHSNAPSHOT=$SEARCHSNAPSHOT \
 ask710/gateway
 
 
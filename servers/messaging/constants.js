@@ -43,6 +43,9 @@ module.exports = {
                     "join users u on u.id = m.creatorid " +
                     "where sm.userid = ?",
     SQL_DELETE_STAR: "delete from starred_messages where userid = ? and messageid = ?;",
+    MESSAGE_EVENTS_EXCHANGE: "message-events",
+    CHANNEL_EVENTS_EXCHANGE: "channel-events",
+    MQ_DEAD_LETTER_EXCHANGE: "dead-letters",
     CONTENT_TYPE: "Content-Type",
     CONTENT_JSON: "application/json",
     CONTENT_TEXT: "text/plain",
@@ -36,7 +36,12 @@ function connectToMQ(mqAddr){
         if(err === null){
             console.log("successfully connected"); 
             conn.createChannel(function(err, ch) {
-                ch.assertQueue(mqName, {durable: false});    
+                //must match the queue the gateway declares, which dead-letters
+                //the messages it can't decode
+                ch.assertExchange(Constants.MQ_DEAD_LETTER_EXCHANGE, "fanout", {durable: true});
+                ch.assertQueue(mqName, {durable: true, deadLetterExchange: Constants.MQ_DEAD_LETTER_EXCHANGE});    
+                ch.assertExchange(Constants.MESSAGE_EVENTS_EXCHANGE, "fanout", {durable: true});
+                ch.assertExchange(Constants.CHANNEL_EVENTS_EXCHANGE, "fanout", {durable: true});
                 mqChannel = ch;    
             });          
             clearInterval(tryConn);
@@ -518,7 +523,8 @@ function getChannelMembers(channelID, authResult) {
 //notification for new channel and update channel
 function mqChannelNotification(type, newChannel){
     let mqResult = {type: type, channel: newChannel, userIDs: newChannel.getUserIDs()};
-    mqChannel.publish("", mqName, Buffer.from(JSON.stringify(mqResult)));
+    mqChannel.publish("", mqName, Buffer.from(JSON.stringify(mqResult)), {persistent: true});
+    publishChannelEvent(mqResult);
 }
 
 //Handles DELETE /v1/channels/{channelID}
@@ -550,7 +556,9 @@ app.delete("/v1/channels/:channelID", async (req, res, next) => {
                 });
 
                 let mqResult = {type: "channel-delete", channelID: channel.getId(), userIDs: channel.getUserIDs()};
-                mqChannel.publish('', mqName, Buffer.from(JSON.stringify(mqResult)));
+                mqChannel.publish('', mqName, Buffer.from(JSON.stringify(mqResult)), {persistent: true});
+                publishMessageEvent(mqResult);
+                publishChannelEvent(mqResult);
 
                 res.setHeader(Constants.CONTENT_TYPE, Constants.CONTENT_TEXT);
                 res.status(200).send("Channel deleted");
@@ -733,7 +741,8 @@ app.delete("/v1/messages/:messageID", async (req, res, next) => {
                 }  
                 
                 let mqResult = {type: "message-delete", messageID: message.getId(), userIDs: channel.getUserIDs()};
-                mqChannel.publish('', mqName, Buffer.from(JSON.stringify(mqResult)));
+                mqChannel.publish('', mqName, Buffer.from(JSON.stringify(mqResult)), {persistent: true});
+                publishMessageEvent(mqResult);
 
                 res.setHeader(Constants.CONTENT_TYPE, Constants.CONTENT_TEXT);
                 res.status(200).send("Message deleted");
@@ -859,7 +868,7 @@ app.delete("/v1/users/me/starred/messages/:messageID", async (req, res, next) =>
                 }
 
                 let mqResult = {type: "remove-star", messageID: req.params.messageID,  userIDs: authResult.id};
-                mqChannel.publish('', mqName, Buffer.from(JSON.stringify(mqResult)));
+                mqChannel.publish('', mqName, Buffer.from(JSON.stringify(mqResult)), {persistent: true});
 
                 res.setHeader(Constants.CONTENT_TYPE, Constants.CONTENT_TEXT);
                 return res.status(200).send("Removed message from starred messages");
@@ -934,7 +943,20 @@ function checkMessageCreator(req, authResult){
 //notification for new message and update message
 function mqMessageNotification(type, newMessage, userIDs){
     let mqResult = {type: type, message: newMessage, userIDs: userIDs};
-    mqChannel.publish("", mqName, Buffer.from(JSON.stringify(mqResult)));
+    mqChannel.publish("", mqName, Buffer.from(JSON.stringify(mqResult)), {persistent: true});
+    publishMessageEvent(mqResult);
+}
+
+//publishes events that change messages to the fanout exchange,
+//so that every search service gets a copy
+function publishMessageEvent(mqResult){
+    mqChannel.publish(Constants.MESSAGE_EVENTS_EXCHANGE, "", Buffer.from(JSON.stringify(mqResult)), {persistent: true});
+}
+
+//publishes events that change channels to the fanout exchange,
+//so that every gateway gets a copy for its channel search index
+function publishChannelEvent(mqResult){
+    mqChannel.publish(Constants.CHANNEL_EVENTS_EXCHANGE, "", Buffer.from(JSON.stringify(mqResult)), {persistent: true});
 }
 
 app.use((err, req, res, next) => {
@@ -0,0 +1,8 @@
+#! /usr/bin/env bash
+echo "Building Linux Executable"
+GOOS=linux go build
+echo "Building Docker Container Image..."
+docker build -t ask710/search .
+echo  "Cleaning Up..."
+go clean
+docker image prune -f 
\ No newline at end of file
@@ -0,0 +1,4 @@
+#! /usr/bin/env bash
+./build.sh
+docker push ask710/search
+ssh root@api.ask710.me 'bash -s' < update.sh 
\ No newline at end of file
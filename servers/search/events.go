@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+//Types of event the messaging service publishes that change what can be found
+const (
+	EventMessageNew    = "message-new"
+	EventMessageUpdate = "message-update"
+	EventMessageDelete = "message-delete"
+	EventChannelDelete = "channel-delete"
+)
+
+//MessageEventsExchange is the fanout exchange the messaging service publishes
+//message events to, so that every search service gets a copy
+const MessageEventsExchange = "message-events"
+
+//eventTimeLayouts are the layouts the messaging service writes times in.
+//New messages have the local time of the server, and messages read back
+//from the database have an ISO time.
+var eventTimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339Nano}
+
+//eventID is an ID that the messaging service writes as either a number or a string
+type eventID int64
+
+//UnmarshalJSON decodes the ID from a number or a string of a number
+func (id *eventID) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("decoding ID %s: %v", data, err)
+	}
+	*id = eventID(v)
+	return nil
+}
+
+//messageEvent is an event published by the messaging service.
+//Only the fields search needs are decoded.
+type messageEvent struct {
+	Type    string `json:"type"`
+	Message *struct {
+		ID        eventID `json:"id"`
+		ChannelID eventID `json:"channelID"`
+		Body      string  `json:"body"`
+		CreatedAt string  `json:"createdAt"`
+		EditedAt  string  `json:"editedAt"`
+		Creator   *struct {
+			ID int64 `json:"id"`
+		} `json:"creator"`
+	} `json:"message"`
+	MessageID eventID `json:"messageID"`
+	ChannelID eventID `json:"channelID"`
+}
+
+//ConsumeMessageEvents declares the message events exchange and a queue bound
+//to it for this service alone, and returns the events delivered to the queue.
+//The queue is deleted when the service disconnects.
+func ConsumeMessageEvents(channel *amqp.Channel) (<-chan amqp.Delivery, error) {
+	if err := channel.ExchangeDeclare(MessageEventsExchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("declaring message events exchange: %v", err)
+	}
+	q, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("declaring message events queue: %v", err)
+	}
+	if err := channel.QueueBind(q.Name, "", MessageEventsExchange, false, nil); err != nil {
+		return nil, fmt.Errorf("binding message events queue: %v", err)
+	}
+	return channel.Consume(q.Name, "", true, true, false, false, nil)
+}
+
+//SyncIndex returns a Setup function for an MQSupervisor that consumes the
+//message events, reloads every message into the index, and then applies the
+//events to it. The index is reloaded on every connection since the queue,
+//and every event published while disconnected, is lost with the last one.
+func SyncIndex(store Store, index *Index) func(channel *amqp.Channel) error {
+	return func(channel *amqp.Channel) error {
+		//events are consumed before the reload and applied after it,
+		//so that nothing that changes while the reload runs is missed
+		events, err := ConsumeMessageEvents(channel)
+		if err != nil {
+			return err
+		}
+		if err := reloadIndex(store, index); err != nil {
+			return err
+		}
+		log.Printf("indexed %d messages", index.Len())
+		go SyncMessageEvents(index, events)
+		return nil
+	}
+}
+
+//reloadIndex loads every message into a new index and replaces the index
+//with it, so messages deleted while events were missed are dropped too
+func reloadIndex(store Store, index *Index) error {
+	loaded := NewIndex()
+	if err := store.LoadMessages(loaded); err != nil {
+		return err
+	}
+	index.Replace(loaded)
+	return nil
+}
+
+//SyncMessageEvents applies the events to the index until
+//the events close, which happens when the connection is lost
+func SyncMessageEvents(index *Index, events <-chan amqp.Delivery) {
+	for event := range events {
+		if err := applyMessageEvent(index, event.Body); err != nil {
+			log.Printf("error applying message event: %v", err)
+		}
+	}
+}
+
+//applyMessageEvent updates the index for a message event, ignoring
+//events that don't change what can be found
+func applyMessageEvent(index *Index, body []byte) error {
+	event := &messageEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return fmt.Errorf("decoding message event: %v", err)
+	}
+	switch event.Type {
+	case EventMessageNew, EventMessageUpdate:
+		m := event.Message
+		if m == nil || m.Creator == nil {
+			return fmt.Errorf("%s event has no message or creator", event.Type)
+		}
+		createdAt, err := parseEventTime(m.CreatedAt)
+		if err != nil {
+			return err
+		}
+		message := &Message{
+			ID:        int64(m.ID),
+			ChannelID: int64(m.ChannelID),
+			CreatorID: m.Creator.ID,
+			Body:      m.Body,
+			CreatedAt: createdAt,
+		}
+		//new messages are written with an edit time, but only updates are edits
+		if event.Type == EventMessageUpdate && len(m.EditedAt) > 0 {
+			editedAt, err := parseEventTime(m.EditedAt)
+			if err != nil {
+				return err
+			}
+			message.EditedAt = &editedAt
+		}
+		index.Upsert(message)
+	case EventMessageDelete:
+		index.Delete(int64(event.MessageID))
+	case EventChannelDelete:
+		index.DeleteChannel(int64(event.ChannelID))
+	}
+	return nil
+}
+
+//parseEventTime parses a time written by the messaging service
+func parseEventTime(value string) (time.Time, error) {
+	for _, layout := range eventTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("parsing event time %q", value)
+}
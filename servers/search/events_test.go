@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyMessageEvent(t *testing.T) {
+	index := NewIndex()
+
+	//new messages have the channel ID of the request path as a string
+	if err := applyMessageEvent(index, []byte(`{"type":"message-new","message":{"id":1,"channelID":"5",
+		"body":"Hello gophers","createdAt":"2018-05-01 10:00:00","creator":{"id":7},"editedAt":"2018-05-01 10:00:00"},
+		"userIDs":[7]}`)); err != nil {
+		t.Fatalf("unexpected error applying new message: %v", err)
+	}
+	q, _ := ParseQuery("gophers")
+	found := index.Search(q, 10, keepAll)
+	expected := &Message{ID: 1, ChannelID: 5, CreatorID: 7, Body: "Hello gophers",
+		CreatedAt: time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)}
+	if len(found) != 1 || !reflect.DeepEqual(found[0], expected) {
+		t.Fatalf("expected %+v but found %v", expected, found)
+	}
+
+	if err := applyMessageEvent(index, []byte(`{"type":"message-update","message":{"id":1,"channelID":5,
+		"body":"Hello gopher","createdAt":"2018-05-01T10:00:00.000Z","creator":{"id":7},"editedAt":"2018-05-02T08:30:00.000Z"},
+		"userIDs":[7]}`)); err != nil {
+		t.Fatalf("unexpected error applying updated message: %v", err)
+	}
+	if found := index.Search(q, 10, keepAll); len(found) != 0 {
+		t.Errorf("expected the old body to be gone but found %v", ids(found))
+	}
+	q, _ = ParseQuery("gopher")
+	found = index.Search(q, 10, keepAll)
+	if len(found) != 1 || found[0].EditedAt == nil || !found[0].EditedAt.Equal(time.Date(2018, 5, 2, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("expected the edited message to be found but found %v", found)
+	}
+
+	//other events are ignored
+	if err := applyMessageEvent(index, []byte(`{"type":"remove-star","messageID":"1","userIDs":7}`)); err != nil {
+		t.Errorf("unexpected error ignoring event: %v", err)
+	}
+	if err := applyMessageEvent(index, []byte(`{"type":"message-delete","messageID":1,"userIDs":[7]}`)); err != nil {
+		t.Errorf("unexpected error deleting message: %v", err)
+	}
+	if index.Len() != 0 {
+		t.Errorf("expected the deleted message to be removed but the index has %d", index.Len())
+	}
+
+	index.Upsert(&Message{ID: 2, ChannelID: 5, Body: "gopher"})
+	index.Upsert(&Message{ID: 3, ChannelID: 6, Body: "gopher"})
+	if err := applyMessageEvent(index, []byte(`{"type":"channel-delete","channelID":5,"userIDs":[]}`)); err != nil {
+		t.Errorf("unexpected error deleting channel: %v", err)
+	}
+	if found := ids(index.Search(q, 10, keepAll)); !reflect.DeepEqual(found, []int64{3}) {
+		t.Errorf("expected only the message of the other channel to be left but got %v", found)
+	}
+
+	for _, bad := range []string{
+		`not json`,
+		`{"type":"message-new"}`,
+		`{"type":"message-new","message":{"id":4,"channelID":5,"body":"x","createdAt":"yesterday","creator":{"id":7}}}`,
+		`{"type":"message-delete","messageID":"four"}`,
+	} {
+		if err := applyMessageEvent(index, []byte(bad)); err == nil {
+			t.Errorf("expected an error applying %s", bad)
+		}
+	}
+}
+
+func TestReloadIndex(t *testing.T) {
+	index := NewIndex()
+	index.Upsert(&Message{ID: 1, ChannelID: 1, Body: "deleted while disconnected"})
+	store := &fakeStore{messages: []*Message{{ID: 2, ChannelID: 1, Body: "sent while disconnected"}}}
+
+	if err := reloadIndex(store, index); err != nil {
+		t.Fatalf("unexpected error reloading: %v", err)
+	}
+	q, _ := ParseQuery("disconnected")
+	if found := ids(index.Search(q, 10, keepAll)); !reflect.DeepEqual(found, []int64{2}) {
+		t.Errorf("expected only the loaded message to be found but got %v", found)
+	}
+
+	//the index is left alone when loading fails
+	store.err = errors.New("query failed")
+	if err := reloadIndex(store, index); err == nil {
+		t.Error("expected an error when loading fails")
+	}
+	if index.Len() != 1 {
+		t.Errorf("expected the index to be kept, got %d messages", index.Len())
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/handlers"
+)
+
+//MaxResults is the most messages a search returns
+const MaxResults = 50
+
+//SearchContext holds the index and store the search handlers use
+type SearchContext struct {
+	Index *Index
+	Store Store
+}
+
+//forwardedUser is the part of the user the gateway sends in the X-User header that search needs
+type forwardedUser struct {
+	ID             int64   `json:"id"`
+	BlockedUserIDs []int64 `json:"blockedUserIDs"`
+}
+
+//SearchResult is a message found by a search, with its body as HTML in which
+//the words that matched the query are wrapped in <mark> elements
+type SearchResult struct {
+	*Message
+	Highlight string `json:"highlight"`
+}
+
+//MessageSearchHandler handles requests for the "/v1/search/messages" resource,
+//responding to GET with the newest messages matching the q query string
+//parameter, from the channels the user in the X-User header may read
+func (ctx *SearchContext) MessageSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handlers.WriteError(w, r, handlers.NewHTTPError(http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed,
+			"Method must be GET"))
+		return
+	}
+	user := &forwardedUser{}
+	if err := json.Unmarshal([]byte(r.Header.Get(handlers.HeaderUser)), user); err != nil || user.ID == 0 {
+		handlers.WriteError(w, r, handlers.NewHTTPError(http.StatusUnauthorized, handlers.CodeUnauthenticated,
+			"Please sign in"))
+		return
+	}
+
+	text := r.URL.Query().Get("q")
+	if len(text) == 0 {
+		handlers.WriteError(w, r, handlers.NewHTTPError(http.StatusBadRequest, handlers.CodeMissingQuery,
+			"Missing 'q' query string parameter"))
+		return
+	}
+	q, err := ParseQuery(text)
+	if err != nil {
+		handlers.WriteError(w, r, handlers.NewHTTPError(http.StatusBadRequest, handlers.CodeInvalidQuery, err.Error()))
+		return
+	}
+	results := []*SearchResult{}
+	ok, err := q.Resolve(ctx.Store, user.ID)
+	if err != nil {
+		handlers.WriteError(w, r, fmt.Errorf("resolving search filters: %v", err))
+		return
+	}
+	if ok {
+		visible, err := ctx.Store.VisibleChannels(user.ID)
+		if err != nil {
+			handlers.WriteError(w, r, fmt.Errorf("getting visible channels: %v", err))
+			return
+		}
+		blocked := map[int64]bool{}
+		for _, id := range user.BlockedUserIDs {
+			blocked[id] = true
+		}
+		terms := q.Terms()
+		for _, m := range ctx.Index.Search(q, MaxResults, func(m *Message) bool {
+			return visible[m.ChannelID] && !blocked[m.CreatorID]
+		}) {
+			results = append(results, &SearchResult{Message: m, Highlight: highlight(m.Body, terms, html.EscapeString, mark)})
+		}
+	}
+
+	w.Header().Add(handlers.HeaderContentType, handlers.ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		log.Printf("Error encoding search results to JSON: %v", err)
+	}
+}
+
+//mark wraps the escaped text in a <mark> element
+func mark(escaped string) string {
+	return "<mark>" + escaped + "</mark>"
+}
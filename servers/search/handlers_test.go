@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/handlers"
+)
+
+func TestMessageSearchHandler(t *testing.T) {
+	store := &fakeStore{
+		users:    map[string]int64{"mary": 11},
+		channels: map[string]int64{"general": 1, "random": 2},
+		visible:  map[int64]bool{1: true},
+	}
+	cases := []struct {
+		name               string
+		method             string
+		user               string
+		query              string
+		store              *fakeStore
+		expectedStatusCode int
+		expectedIDs        []int64
+	}{
+		{
+			"Only Visible Channels",
+			http.MethodGet,
+			`{"id":10}`,
+			"quick",
+			store,
+			http.StatusOK,
+			[]int64{2, 1},
+		},
+		{
+			"Blocked Users Left Out",
+			http.MethodGet,
+			`{"id":10,"blockedUserIDs":[11]}`,
+			"quick",
+			store,
+			http.StatusOK,
+			[]int64{1},
+		},
+		{
+			"Filters",
+			http.MethodGet,
+			`{"id":10}`,
+			"from:mary in:general",
+			store,
+			http.StatusOK,
+			[]int64{2},
+		},
+		{
+			"Channel Not Visible",
+			http.MethodGet,
+			`{"id":10}`,
+			"quick in:random",
+			store,
+			http.StatusOK,
+			[]int64{},
+		},
+		{
+			"Unknown User",
+			http.MethodGet,
+			`{"id":10}`,
+			"from:nobody",
+			store,
+			http.StatusOK,
+			[]int64{},
+		},
+		{
+			"Missing Query",
+			http.MethodGet,
+			`{"id":10}`,
+			"",
+			store,
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"Invalid Query",
+			http.MethodGet,
+			`{"id":10}`,
+			"before:tomorrow",
+			store,
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"Not Signed In",
+			http.MethodGet,
+			"",
+			"quick",
+			store,
+			http.StatusUnauthorized,
+			nil,
+		},
+		{
+			"Wrong Method",
+			http.MethodPost,
+			`{"id":10}`,
+			"quick",
+			store,
+			http.StatusMethodNotAllowed,
+			nil,
+		},
+		{
+			"Store Error",
+			http.MethodGet,
+			`{"id":10}`,
+			"quick",
+			&fakeStore{err: errors.New("query failed")},
+			http.StatusInternalServerError,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		ctx := &SearchContext{Index: newTestIndex(), Store: c.store}
+		req := httptest.NewRequest(c.method, "/v1/search/messages?q="+url.QueryEscape(c.query), nil)
+		if len(c.user) > 0 {
+			req.Header.Set(handlers.HeaderUser, c.user)
+		}
+		respRec := httptest.NewRecorder()
+
+		ctx.MessageSearchHandler(respRec, req)
+		resp := respRec.Result()
+		if resp.StatusCode != c.expectedStatusCode {
+			t.Errorf("case %s: incorrect status code: expected %d but got %d: %s",
+				c.name, c.expectedStatusCode, resp.StatusCode, respRec.Body.String())
+			continue
+		}
+		if c.expectedIDs == nil {
+			continue
+		}
+		results := []*SearchResult{}
+		if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+			t.Errorf("case %s: error decoding response: %v", c.name, err)
+			continue
+		}
+		found := []int64{}
+		for _, r := range results {
+			found = append(found, r.ID)
+		}
+		if !reflect.DeepEqual(found, c.expectedIDs) {
+			t.Errorf("case %s: expected messages %v but got %v", c.name, c.expectedIDs, found)
+		}
+	}
+}
+
+func TestMessageSearchHandlerHighlights(t *testing.T) {
+	ctx := &SearchContext{Index: newTestIndex(), Store: &fakeStore{visible: map[int64]bool{1: true, 2: true}}}
+	req := httptest.NewRequest(http.MethodGet, "/v1/search/messages?q="+url.QueryEscape(`"quick brown" fox`), nil)
+	req.Header.Set(handlers.HeaderUser, `{"id":10}`)
+	respRec := httptest.NewRecorder()
+
+	ctx.MessageSearchHandler(respRec, req)
+	results := []*SearchResult{}
+	if err := json.NewDecoder(respRec.Body).Decode(&results); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+	if len(results) != 1 || results[0].Highlight != "The <mark>quick</mark> <mark>brown</mark> <mark>fox</mark>" {
+		t.Errorf("expected message 1 with its matches highlighted but got %+v", results)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+)
+
+//Message is a message of the messaging service, as much of it as search needs.
+//The messaging service owns the messages table.
+type Message struct {
+	ID        int64      `json:"id"`
+	ChannelID int64      `json:"channelID"`
+	CreatorID int64      `json:"creatorID"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt"`
+}
+
+//token is a term of a message body and where it was in the body
+type token struct {
+	term string
+	//start and end are the byte offsets of the word the term came from
+	start int
+	end   int
+}
+
+//tokenize splits the text into words and analyzes each word into terms
+//the same way the gateway's search does. A word may make more than one term,
+//so terms are numbered by their place in the returned slice, not by word.
+func tokenize(text string) []*token {
+	tokens := []*token{}
+	start := -1
+	for i, r := range text + " " {
+		inWord := unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsNumber(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			for _, term := range indexes.Analyze(text[start:i]) {
+				tokens = append(tokens, &token{term: term, start: start, end: i})
+			}
+			start = -1
+		}
+	}
+	return tokens
+}
+
+//Index is an inverted index of message bodies, recording the positions of
+//each term in each message so that phrases can be matched. It is safe for
+//concurrent use.
+type Index struct {
+	mx sync.RWMutex
+	//postings are the positions of each term in each message
+	postings map[string]map[int64][]int
+	messages map[int64]*indexedMessage
+	//byChannel are the IDs of the messages in each channel
+	byChannel map[int64]map[int64]bool
+}
+
+//indexedMessage is a message and the distinct terms it is indexed under
+type indexedMessage struct {
+	*Message
+	terms []string
+}
+
+//NewIndex constructs a new, empty Index
+func NewIndex() *Index {
+	return &Index{
+		postings:  map[string]map[int64][]int{},
+		messages:  map[int64]*indexedMessage{},
+		byChannel: map[int64]map[int64]bool{},
+	}
+}
+
+//Len returns the number of messages in the index
+func (idx *Index) Len() int {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return len(idx.messages)
+}
+
+//Replace replaces the contents of the index with the contents of
+//the other index, which must not be used afterwards
+func (idx *Index) Replace(other *Index) {
+	other.mx.RLock()
+	defer other.mx.RUnlock()
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+	idx.postings = other.postings
+	idx.messages = other.messages
+	idx.byChannel = other.byChannel
+}
+
+//Upsert adds the message to the index, replacing any older version of it
+func (idx *Index) Upsert(m *Message) {
+	positions := map[string][]int{}
+	terms := []string{}
+	for i, t := range tokenize(m.Body) {
+		if _, ok := positions[t.term]; !ok {
+			terms = append(terms, t.term)
+		}
+		positions[t.term] = append(positions[t.term], i)
+	}
+
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+	idx.remove(m.ID)
+	for term, p := range positions {
+		if idx.postings[term] == nil {
+			idx.postings[term] = map[int64][]int{}
+		}
+		idx.postings[term][m.ID] = p
+	}
+	idx.messages[m.ID] = &indexedMessage{Message: m, terms: terms}
+	if idx.byChannel[m.ChannelID] == nil {
+		idx.byChannel[m.ChannelID] = map[int64]bool{}
+	}
+	idx.byChannel[m.ChannelID][m.ID] = true
+}
+
+//Delete removes the message from the index
+func (idx *Index) Delete(id int64) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+	idx.remove(id)
+}
+
+//DeleteChannel removes every message in the channel from the index
+func (idx *Index) DeleteChannel(channelID int64) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+	for id := range idx.byChannel[channelID] {
+		idx.remove(id)
+	}
+}
+
+//remove removes the message while the index is locked
+func (idx *Index) remove(id int64) {
+	m, ok := idx.messages[id]
+	if !ok {
+		return
+	}
+	for _, term := range m.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.byChannel[m.ChannelID], id)
+	if len(idx.byChannel[m.ChannelID]) == 0 {
+		delete(idx.byChannel, m.ChannelID)
+	}
+	delete(idx.messages, id)
+}
+
+//Search returns up to `n` of the messages matching the query, newest
+//first. Only messages that `keep` returns true for are returned.
+func (idx *Index) Search(q *Query, n int, keep func(m *Message) bool) []*Message {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	found := []*Message{}
+	for _, m := range idx.candidates(q.Terms()) {
+		if q.matches(m) && idx.hasPhrases(m.ID, q.Phrases) && keep(m) {
+			found = append(found, m)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].CreatedAt.Equal(found[j].CreatedAt) {
+			return found[i].CreatedAt.After(found[j].CreatedAt)
+		}
+		return found[i].ID > found[j].ID
+	})
+	if len(found) > n {
+		found = found[:n]
+	}
+	return found
+}
+
+//candidates returns the messages that contain every one of the terms,
+//or every message if there are no terms
+func (idx *Index) candidates(terms []string) []*Message {
+	candidates := []*Message{}
+	if len(terms) == 0 {
+		for _, m := range idx.messages {
+			candidates = append(candidates, m.Message)
+		}
+		return candidates
+	}
+	//intersect starting from the rarest term, which has the fewest messages to check
+	postings := make([]map[int64][]int, len(terms))
+	for i, term := range terms {
+		if postings[i] = idx.postings[term]; len(postings[i]) == 0 {
+			return candidates
+		}
+	}
+	sort.Slice(postings, func(i, j int) bool { return len(postings[i]) < len(postings[j]) })
+	for id := range postings[0] {
+		inAll := true
+		for _, p := range postings[1:] {
+			if _, ok := p[id]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			candidates = append(candidates, idx.messages[id].Message)
+		}
+	}
+	return candidates
+}
+
+//hasPhrases returns true if the message has the terms of every phrase next to each other
+func (idx *Index) hasPhrases(id int64, phrases [][]string) bool {
+	for _, phrase := range phrases {
+		if !idx.hasPhrase(id, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+//hasPhrase returns true if the terms of the phrase are next to each other in the message
+func (idx *Index) hasPhrase(id int64, phrase []string) bool {
+	next := map[int]bool{}
+	for _, p := range idx.postings[phrase[0]][id] {
+		next[p+1] = true
+	}
+	for _, term := range phrase[1:] {
+		matched := map[int]bool{}
+		for _, p := range idx.postings[term][id] {
+			if next[p] {
+				matched[p+1] = true
+			}
+		}
+		if len(matched) == 0 {
+			return false
+		}
+		next = matched
+	}
+	return len(next) > 0
+}
+
+//highlight returns the body with the words that made any of the terms wrapped
+//by `mark`, and every other part of the body passed through `escape`
+func highlight(body string, terms []string, escape func(string) string, mark func(string) string) string {
+	wanted := map[string]bool{}
+	for _, term := range terms {
+		wanted[term] = true
+	}
+	var b strings.Builder
+	last := 0
+	for _, t := range tokenize(body) {
+		//a word that made several terms is marked once
+		if !wanted[t.term] || t.start < last {
+			continue
+		}
+		b.WriteString(escape(body[last:t.start]))
+		b.WriteString(mark(escape(body[t.start:t.end])))
+		last = t.end
+	}
+	b.WriteString(escape(body[last:]))
+	return b.String()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"html"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//newTestIndex returns an index of a few messages in channels 1 and 2
+func newTestIndex() *Index {
+	day := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	index := NewIndex()
+	index.Upsert(&Message{ID: 1, ChannelID: 1, CreatorID: 10, Body: "The quick brown fox", CreatedAt: day})
+	index.Upsert(&Message{ID: 2, ChannelID: 1, CreatorID: 11, Body: "A brown dog, not a quick one", CreatedAt: day.AddDate(0, 0, 1)})
+	index.Upsert(&Message{ID: 3, ChannelID: 2, CreatorID: 10, Body: "Quick! Brown foxes everywhere", CreatedAt: day.AddDate(0, 0, 2)})
+	index.Upsert(&Message{ID: 4, ChannelID: 2, CreatorID: 11, Body: "Ünïcode QUICK bröwn", CreatedAt: day.AddDate(0, 0, 3)})
+	return index
+}
+
+//ids returns the IDs of the messages
+func ids(messages []*Message) []int64 {
+	result := []int64{}
+	for _, m := range messages {
+		result = append(result, m.ID)
+	}
+	return result
+}
+
+func keepAll(m *Message) bool {
+	return true
+}
+
+func TestIndexSearch(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		expected []int64
+	}{
+		{
+			"Every Word Newest First",
+			"quick brown",
+			[]int64{4, 3, 2, 1},
+		},
+		{
+			"Phrase",
+			`"quick brown"`,
+			[]int64{4, 3, 1},
+		},
+		{
+			"Phrase And Word",
+			`"brown fox" quick`,
+			[]int64{1},
+		},
+		{
+			"Phrase Across Punctuation",
+			`"quick brown foxes"`,
+			[]int64{3},
+		},
+		{
+			"Hyphenated Word Is A Phrase",
+			"brown-dog",
+			[]int64{2},
+		},
+		{
+			"Folded Case And Diacritics",
+			"unicode",
+			[]int64{4},
+		},
+		{
+			"Missing Word",
+			"quick zebra",
+			[]int64{},
+		},
+		{
+			"After Date",
+			"quick after:2018-05-02",
+			[]int64{4, 3},
+		},
+		{
+			"Before Date",
+			"quick before:2018-05-02",
+			[]int64{1},
+		},
+		{
+			"On Date",
+			"on:2018-05-02",
+			[]int64{2},
+		},
+	}
+
+	index := newTestIndex()
+	for _, c := range cases {
+		q, err := ParseQuery(c.query)
+		if err != nil {
+			t.Errorf("case %s: unexpected error parsing query: %v", c.name, err)
+			continue
+		}
+		if found := ids(index.Search(q, 10, keepAll)); !reflect.DeepEqual(found, c.expected) {
+			t.Errorf("case %s: expected %v but got %v", c.name, c.expected, found)
+		}
+	}
+}
+
+func TestIndexSearchLimitAndKeep(t *testing.T) {
+	index := newTestIndex()
+	q, _ := ParseQuery("quick")
+	if found := ids(index.Search(q, 2, keepAll)); !reflect.DeepEqual(found, []int64{4, 3}) {
+		t.Errorf("expected the newest 2 messages but got %v", found)
+	}
+	inChannel1 := func(m *Message) bool { return m.ChannelID == 1 }
+	if found := ids(index.Search(q, 10, inChannel1)); !reflect.DeepEqual(found, []int64{2, 1}) {
+		t.Errorf("expected only kept messages but got %v", found)
+	}
+}
+
+func TestIndexUpsertAndDelete(t *testing.T) {
+	index := newTestIndex()
+	q, _ := ParseQuery("fox")
+
+	index.Upsert(&Message{ID: 1, ChannelID: 1, CreatorID: 10, Body: "The quick brown cat"})
+	if found := ids(index.Search(q, 10, keepAll)); len(found) != 0 {
+		t.Errorf("expected the edited message not to be found by its old body but got %v", found)
+	}
+	if index.Len() != 4 {
+		t.Errorf("expected 4 messages but got %d", index.Len())
+	}
+
+	index.Delete(2)
+	index.Delete(99)
+	if index.Len() != 3 {
+		t.Errorf("expected 3 messages after deleting but got %d", index.Len())
+	}
+
+	index.DeleteChannel(2)
+	q, _ = ParseQuery("quick")
+	if found := ids(index.Search(q, 10, keepAll)); !reflect.DeepEqual(found, []int64{1}) {
+		t.Errorf("expected only message 1 to be left but got %v", found)
+	}
+	index.DeleteChannel(1)
+	if index.Len() != 0 || len(index.postings) != 0 || len(index.byChannel) != 0 {
+		t.Errorf("expected an empty index but got %d messages and %d terms", index.Len(), len(index.postings))
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		terms    []string
+		expected string
+	}{
+		{
+			"Matched Words",
+			"The quick brown fox",
+			[]string{"quick", "fox"},
+			"The <mark>quick</mark> brown <mark>fox</mark>",
+		},
+		{
+			"Original Text Kept",
+			"Ünïcode QUICK!",
+			[]string{"unicode", "quick"},
+			"<mark>Ünïcode</mark> <mark>QUICK</mark>!",
+		},
+		{
+			"Escaped",
+			"<b>bold</b> & quick",
+			[]string{"quick", "b"},
+			"&lt;<mark>b</mark>&gt;bold&lt;/<mark>b</mark>&gt; &amp; <mark>quick</mark>",
+		},
+		{
+			"Nothing Matched",
+			"slow",
+			[]string{"quick"},
+			"slow",
+		},
+	}
+
+	for _, c := range cases {
+		if result := highlight(c.body, c.terms, html.EscapeString, mark); result != c.expected {
+			t.Errorf("case %s: expected %q but got %q", c.name, c.expected, result)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/info344-s18/challenges-ask710/servers/gateway/handlers"
+)
+
+func main() {
+	addr := os.Getenv("ADDR")
+	if len(addr) == 0 {
+		addr = ":80"
+	}
+	dsn := reqEnv("DSN")
+	mqAddr := reqEnv("MQADDR")
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	store := NewMySQLStore(db)
+
+	//the supervisor reconnects to RabbitMQ whenever the connection is lost,
+	//and the index is reloaded every time since events may have been missed
+	index := NewIndex()
+	mqSupervisor := handlers.NewMQSupervisor(mqAddr)
+	mqSupervisor.Setup = SyncIndex(store, index)
+	go mqSupervisor.Run()
+
+	ctx := &SearchContext{Index: index, Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search/messages", ctx.MessageSearchHandler)
+
+	log.Printf("Server is listening at http://%s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+func reqEnv(name string) string {
+	val := os.Getenv(name)
+	if len(val) == 0 {
+		log.Fatalf("Please set %s variable", name)
+	}
+	return val
+}
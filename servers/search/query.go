@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/info344-s18/challenges-ask710/servers/gateway/indexes"
+)
+
+//DateLayout is the layout of the dates of the before:, after: and on: filters
+const DateLayout = "2006-01-02"
+
+//Query is a parsed message search query. Messages match when they have
+//every term, the terms of every phrase next to each other, and pass every
+//filter. Filters on the same field, like two from: filters, match either.
+type Query struct {
+	//Words are the analyzed terms that may be anywhere in the message
+	Words []string
+	//Phrases are the analyzed terms of each quoted phrase
+	Phrases [][]string
+	//From are the usernames of from: filters, where "me" is the searcher
+	From []string
+	//In are the channel names of in: filters
+	In []string
+	//After and Before are the bounds of the date filters, which are zero when not set
+	After  time.Time
+	Before time.Time
+
+	//creatorIDs and channelIDs are what From and In resolve to
+	creatorIDs map[int64]bool
+	channelIDs map[int64]bool
+}
+
+//ParseQuery parses the text of a search query. Words can be put in double
+//quotes to search for a phrase, and a word like "from:name" filters the
+//messages instead of being searched for. The filters are from:username, the
+//channel filter in:name, and before:, after: and on: followed by a date
+//written as 2006-01-02 in UTC. Filter values can be quoted if they contain spaces.
+func ParseQuery(text string) (*Query, error) {
+	q := &Query{}
+	for _, part := range splitQuery(text) {
+		key, value := part, ""
+		//a filter without a value, like "from:", is searched for as a word
+		if i := strings.Index(part, ":"); i > 0 && !strings.HasPrefix(part, `"`) {
+			if value = strings.Trim(part[i+1:], `"`); len(value) > 0 {
+				key = strings.ToLower(part[:i])
+			}
+		}
+		var err error
+		switch key {
+		case "from":
+			q.From = append(q.From, strings.TrimPrefix(value, "@"))
+		case "in":
+			q.In = append(q.In, strings.TrimPrefix(value, "#"))
+		case "before":
+			err = q.setBefore(value, 0)
+		case "after":
+			err = q.setAfter(value, 1)
+		case "on":
+			if err = q.setAfter(value, 0); err == nil {
+				err = q.setBefore(value, 1)
+			}
+		default:
+			q.addWords(part)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(q.Words) == 0 && len(q.Phrases) == 0 && len(q.From) == 0 && len(q.In) == 0 &&
+		q.After.IsZero() && q.Before.IsZero() {
+		return nil, fmt.Errorf("the query has nothing to search for")
+	}
+	return q, nil
+}
+
+//splitQuery splits the query at whitespace that isn't inside double quotes,
+//keeping the quotes
+func splitQuery(text string) []string {
+	parts := []string{}
+	quoted := false
+	start := -1
+	for i, r := range text + " " {
+		if r == '"' {
+			quoted = !quoted
+		}
+		switch {
+		case unicode.IsSpace(r) && !quoted && start >= 0:
+			parts = append(parts, text[start:i])
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
+		}
+	}
+	//an unclosed quote runs to the end of the query
+	if start >= 0 {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
+
+//addWords adds the terms of a part of the query that isn't a filter. Quoted
+//parts, and words that analyze to several terms like "e-mail", are phrases.
+func (q *Query) addWords(part string) {
+	terms := indexes.Analyze(part)
+	switch {
+	case len(terms) == 0:
+	case len(terms) == 1 && !strings.HasPrefix(part, `"`):
+		q.Words = append(q.Words, terms[0])
+	default:
+		q.Phrases = append(q.Phrases, terms)
+	}
+}
+
+//setAfter moves the lower bound of the query up to the start of the date plus `days`
+func (q *Query) setAfter(value string, days int) error {
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return fmt.Errorf("dates must be written as %s, not %q", DateLayout, value)
+	}
+	if after := date.AddDate(0, 0, days); after.After(q.After) {
+		q.After = after
+	}
+	return nil
+}
+
+//setBefore moves the upper bound of the query down to the start of the date plus `days`
+func (q *Query) setBefore(value string, days int) error {
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return fmt.Errorf("dates must be written as %s, not %q", DateLayout, value)
+	}
+	if before := date.AddDate(0, 0, days); q.Before.IsZero() || before.Before(q.Before) {
+		q.Before = before
+	}
+	return nil
+}
+
+//Terms returns every distinct term of the query's words and phrases
+func (q *Query) Terms() []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, term := range q.Words {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, phrase := range q.Phrases {
+		for _, term := range phrase {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+//Resolve looks up the users and channels of the from: and in: filters. It
+//returns false when none of the users or none of the channels exist, since
+//then nothing can match.
+func (q *Query) Resolve(store Store, searcherID int64) (bool, error) {
+	if len(q.From) > 0 {
+		names := []string{}
+		q.creatorIDs = map[int64]bool{}
+		for _, name := range q.From {
+			if strings.ToLower(name) == "me" {
+				q.creatorIDs[searcherID] = true
+			} else {
+				names = append(names, name)
+			}
+		}
+		ids, err := store.UserIDs(names)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range ids {
+			q.creatorIDs[id] = true
+		}
+		if len(q.creatorIDs) == 0 {
+			return false, nil
+		}
+	}
+	if len(q.In) > 0 {
+		ids, err := store.ChannelIDs(q.In)
+		if err != nil {
+			return false, err
+		}
+		if len(ids) == 0 {
+			return false, nil
+		}
+		q.channelIDs = map[int64]bool{}
+		for _, id := range ids {
+			q.channelIDs[id] = true
+		}
+	}
+	return true, nil
+}
+
+//matches returns true if the message passes the query's filters
+func (q *Query) matches(m *Message) bool {
+	switch {
+	case q.creatorIDs != nil && !q.creatorIDs[m.CreatorID]:
+		return false
+	case q.channelIDs != nil && !q.channelIDs[m.ChannelID]:
+		return false
+	case !q.After.IsZero() && m.CreatedAt.Before(q.After):
+		return false
+	case !q.Before.IsZero() && !m.CreatedAt.Before(q.Before):
+		return false
+	}
+	return true
+}
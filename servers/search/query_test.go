@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	cases := []struct {
+		name        string
+		query       string
+		expected    *Query
+		expectError bool
+	}{
+		{
+			"Words",
+			"Quick  BRÖWN fox",
+			&Query{Words: []string{"quick", "brown", "fox"}},
+			false,
+		},
+		{
+			"Phrases",
+			`"quick brown" fox "lazy" e-mail`,
+			&Query{Words: []string{"fox"}, Phrases: [][]string{{"quick", "brown"}, {"lazy"}, {"e", "mail"}}},
+			false,
+		},
+		{
+			"Unclosed Phrase",
+			`fox "quick brown`,
+			&Query{Words: []string{"fox"}, Phrases: [][]string{{"quick", "brown"}}},
+			false,
+		},
+		{
+			"Filters",
+			`from:@mary from:me in:#general in:"random chat" report`,
+			&Query{Words: []string{"report"}, From: []string{"mary", "me"}, In: []string{"general", "random chat"}},
+			false,
+		},
+		{
+			"Dates",
+			"before:2018-05-10 after:2018-05-01 BEFORE:2018-05-20",
+			&Query{
+				After:  time.Date(2018, 5, 2, 0, 0, 0, 0, time.UTC),
+				Before: time.Date(2018, 5, 10, 0, 0, 0, 0, time.UTC),
+			},
+			false,
+		},
+		{
+			"On Date",
+			"on:2018-05-01",
+			&Query{
+				After:  time.Date(2018, 5, 1, 0, 0, 0, 0, time.UTC),
+				Before: time.Date(2018, 5, 2, 0, 0, 0, 0, time.UTC),
+			},
+			false,
+		},
+		{
+			"Unknown Filters And Empty Values Are Words",
+			"http://example.com from:",
+			&Query{Words: []string{"from"}, Phrases: [][]string{{"http", "example", "com"}}},
+			false,
+		},
+		{
+			"Invalid Date",
+			"after:yesterday",
+			nil,
+			true,
+		},
+		{
+			"Nothing To Search For",
+			`  "" !!`,
+			nil,
+			true,
+		},
+	}
+
+	for _, c := range cases {
+		q, err := ParseQuery(c.query)
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: expected error %t but got %v", c.name, c.expectError, err)
+		}
+		if !reflect.DeepEqual(q, c.expected) {
+			t.Errorf("case %s: expected %+v but got %+v", c.name, c.expected, q)
+		}
+	}
+}
+
+//fakeStore is a Store with fixed messages, users and channels
+type fakeStore struct {
+	messages []*Message
+	users    map[string]int64
+	channels map[string]int64
+	visible  map[int64]bool
+	err      error
+}
+
+func (s *fakeStore) LoadMessages(index *Index) error {
+	for _, m := range s.messages {
+		index.Upsert(m)
+	}
+	return s.err
+}
+
+func (s *fakeStore) VisibleChannels(userID int64) (map[int64]bool, error) {
+	return s.visible, s.err
+}
+
+func (s *fakeStore) UserIDs(usernames []string) ([]int64, error) {
+	return lookup(s.users, usernames), s.err
+}
+
+func (s *fakeStore) ChannelIDs(names []string) ([]int64, error) {
+	return lookup(s.channels, names), s.err
+}
+
+//lookup returns the IDs of the names that are in the map
+func lookup(ids map[string]int64, names []string) []int64 {
+	found := []int64{}
+	for _, name := range names {
+		if id, ok := ids[name]; ok {
+			found = append(found, id)
+		}
+	}
+	return found
+}
+
+func TestQueryResolve(t *testing.T) {
+	store := &fakeStore{
+		users:    map[string]int64{"mary": 11},
+		channels: map[string]int64{"general": 1},
+	}
+	cases := []struct {
+		name            string
+		query           string
+		store           *fakeStore
+		expectedOK      bool
+		expectedFrom    map[int64]bool
+		expectedIn      map[int64]bool
+		expectedMatches []int64
+		expectError     bool
+	}{
+		{
+			"From Users",
+			"from:mary from:me from:nobody",
+			store,
+			true,
+			map[int64]bool{10: true, 11: true},
+			nil,
+			[]int64{4, 3, 2, 1},
+			false,
+		},
+		{
+			"In Channel",
+			"quick in:general in:nowhere",
+			store,
+			true,
+			nil,
+			map[int64]bool{1: true},
+			[]int64{2, 1},
+			false,
+		},
+		{
+			"Nobody",
+			"from:nobody",
+			store,
+			false,
+			map[int64]bool{},
+			nil,
+			nil,
+			false,
+		},
+		{
+			"No Channel",
+			"in:nowhere",
+			store,
+			false,
+			nil,
+			nil,
+			nil,
+			false,
+		},
+		{
+			"Store Error",
+			"in:general",
+			&fakeStore{err: errors.New("query failed")},
+			false,
+			nil,
+			nil,
+			nil,
+			true,
+		},
+	}
+
+	index := newTestIndex()
+	for _, c := range cases {
+		q, err := ParseQuery(c.query)
+		if err != nil {
+			t.Fatalf("case %s: unexpected error parsing query: %v", c.name, err)
+		}
+		ok, err := q.Resolve(c.store, 10)
+		if c.expectError != (err != nil) {
+			t.Errorf("case %s: expected error %t but got %v", c.name, c.expectError, err)
+		}
+		if ok != c.expectedOK {
+			t.Errorf("case %s: expected ok %t but got %t", c.name, c.expectedOK, ok)
+		}
+		if !reflect.DeepEqual(q.creatorIDs, c.expectedFrom) || !reflect.DeepEqual(q.channelIDs, c.expectedIn) {
+			t.Errorf("case %s: expected users %v and channels %v but got %v and %v",
+				c.name, c.expectedFrom, c.expectedIn, q.creatorIDs, q.channelIDs)
+		}
+		if ok {
+			if found := ids(index.Search(q, 10, keepAll)); !reflect.DeepEqual(found, c.expectedMatches) {
+				t.Errorf("case %s: expected matches %v but got %v", c.name, c.expectedMatches, found)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+//Store represents a read only store of the messages, users and channels
+//that message search needs
+type Store interface {
+	//LoadMessages upserts every message into the index
+	LoadMessages(index *Index) error
+
+	//VisibleChannels returns the IDs of the channels the user may read,
+	//which are every public channel and the private channels they are a member of
+	VisibleChannels(userID int64) (map[int64]bool, error)
+
+	//UserIDs returns the IDs of the users with the usernames
+	UserIDs(usernames []string) ([]int64, error)
+
+	//ChannelIDs returns the IDs of the channels with the names
+	ChannelIDs(names []string) ([]int64, error)
+}
+
+//sqlMessages selects every message to backfill the index
+const sqlMessages = "select id, channelid, creatorid, body, createdat, editedat from messages"
+
+//sqlVisibleChannels selects the channels a user may read, checking membership
+//in the database so it is current however members were changed
+const sqlVisibleChannels = "select c.id from channel c where c.channelprivate = false or exists " +
+	"(select 1 from channel_users cu where cu.channelid = c.id and cu.usersid = ?)"
+
+//MySQLStore represents a Store backed by the tables of the messaging service in MySQL
+type MySQLStore struct {
+	db *sql.DB
+}
+
+//NewMySQLStore constructs a new MySQLStore.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{
+		db: db,
+	}
+}
+
+//LoadMessages upserts every message into the index
+func (s *MySQLStore) LoadMessages(index *Index) error {
+	rows, err := s.db.Query(sqlMessages)
+	if err != nil {
+		return fmt.Errorf("Error loading messages for search: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &Message{}
+		if err := rows.Scan(&m.ID, &m.ChannelID, &m.CreatorID, &m.Body, &m.CreatedAt, &m.EditedAt); err != nil {
+			return fmt.Errorf("Error scanning messages: %v", err)
+		}
+		index.Upsert(m)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Error getting next row: %v", err)
+	}
+	return nil
+}
+
+//VisibleChannels returns the IDs of the channels the user may read
+func (s *MySQLStore) VisibleChannels(userID int64) (map[int64]bool, error) {
+	ids, err := s.ids(sqlVisibleChannels, userID)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		visible[id] = true
+	}
+	return visible, nil
+}
+
+//UserIDs returns the IDs of the users with the usernames
+func (s *MySQLStore) UserIDs(usernames []string) ([]int64, error) {
+	return s.idsIn("select id from users where username in ", usernames)
+}
+
+//ChannelIDs returns the IDs of the channels with the names
+func (s *MySQLStore) ChannelIDs(names []string) ([]int64, error) {
+	return s.idsIn("select id from channel where channelname in ", names)
+}
+
+//idsIn returns the IDs the query selects for the values, which are put in
+//a list after the query
+func (s *MySQLStore) idsIn(query string, values []string) ([]int64, error) {
+	if len(values) == 0 {
+		return []int64{}, nil
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return s.ids(query+"(?"+strings.Repeat(",?", len(values)-1)+")", args...)
+}
+
+//ids returns the IDs the query selects
+func (s *MySQLStore) ids(query string, args ...interface{}) ([]int64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying IDs: %v", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("Error scanning IDs: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Error getting next row: %v", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestLoadMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(sqlMessages)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "channelid", "creatorid", "body", "createdat", "editedat"}).
+			AddRow(1, 1, 10, "hello gophers", now, nil).
+			AddRow(2, 2, 11, "goodbye gophers", now, now))
+
+	index := NewIndex()
+	if err := NewMySQLStore(db).LoadMessages(index); err != nil {
+		t.Fatalf("unexpected error loading messages: %v", err)
+	}
+	q, _ := ParseQuery("gophers")
+	if found := ids(index.Search(q, 10, keepAll)); !reflect.DeepEqual(found, []int64{2, 1}) {
+		t.Errorf("expected both messages to be loaded but got %v", found)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlMessages)).WillReturnError(errors.New("query failed"))
+	if err := NewMySQLStore(db).LoadMessages(index); err == nil {
+		t.Error("expected an error when the query fails")
+	}
+}
+
+func TestStoreIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error creating sql mock: %v", err)
+	}
+	defer db.Close()
+	store := NewMySQLStore(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlVisibleChannels)).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(3))
+	if visible, err := store.VisibleChannels(10); err != nil || !reflect.DeepEqual(visible, map[int64]bool{1: true, 3: true}) {
+		t.Errorf("expected channels 1 and 3 to be visible but got %v, %v", visible, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("select id from users where username in (?,?)")).
+		WithArgs("mary", "nobody").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	if ids, err := store.UserIDs([]string{"mary", "nobody"}); err != nil || !reflect.DeepEqual(ids, []int64{11}) {
+		t.Errorf("expected user 11 but got %v, %v", ids, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("select id from channel where channelname in (?)")).
+		WithArgs("general").
+		WillReturnError(errors.New("query failed"))
+	if _, err := store.ChannelIDs([]string{"general"}); err == nil {
+		t.Error("expected an error when the query fails")
+	}
+
+	//no query is made without names
+	if ids, err := store.UserIDs(nil); err != nil || len(ids) != 0 {
+		t.Errorf("expected no users and no error but got %v, %v", ids, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+export SEARCHADDR=search:80
+export MYSQL_ROOT_PASSWORD=lkdsnalkfnadkjbdflajbslajbd
+export MYSQL_DATABASE=users
+export MYSQL_ADDR=usersdb:3306
+export MQADDR=messagequeue:5672
+
+export DSN="root:$MYSQL_ROOT_PASSWORD@tcp($MYSQL_ADDR)/$MYSQL_DATABASE?parseTime=true"
+
+docker rm -f search
+
+docker pull ask710/search
+
+docker run -d \
+--network authnet \
+--name search \
+-e ADDR=$SEARCHADDR \
+-e DSN=$DSN \
+-e MQADDR=$MQADDR \
+ask710/search
\ No newline at end of file
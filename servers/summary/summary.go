@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -78,13 +79,26 @@ func SummaryHandler(w http.ResponseWriter, r *http.Request) {
 	pageURL := r.URL.Query().Get("url")
 
 	if len(pageURL) == 0 {
-		http.Error(w, "Missing url query string parameter", http.StatusBadRequest)
+		handlers.WriteError(w, r, handlers.NewHTTPError(http.StatusBadRequest, handlers.CodeMissingQuery,
+			"Missing url query string parameter"))
+		return
+	}
+
+	if parsedURL, err := url.Parse(pageURL); err != nil ||
+		(parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || len(parsedURL.Host) == 0 {
+		handlers.WriteError(w, r, handlers.NewHTTPError(http.StatusBadRequest, handlers.CodeInvalidURL,
+			"url must be an absolute http or https URL"))
 		return
 	}
 
 	html, err := fetchHTML(pageURL)
 	if err != nil {
-		http.Error(w, "Error fetching html for URL: "+err.Error(), http.StatusInternalServerError)
+		handlers.WriteError(w, r, &handlers.HTTPError{
+			Status: http.StatusBadGateway,
+			Code:   handlers.CodeFetchFailed,
+			Detail: "Unable to fetch a web page from url",
+			Err:    err,
+		})
 		return
 	}
 	defer html.Close()
@@ -92,14 +106,14 @@ func SummaryHandler(w http.ResponseWriter, r *http.Request) {
 	summary, err := extractSummary(pageURL, html)
 
 	if err != nil {
-		http.Error(w, "Error extracting summary for URL: "+err.Error(), http.StatusInternalServerError)
+		handlers.WriteError(w, r, fmt.Errorf("extracting summary for %s: %v", pageURL, err))
 		return
 	}
 
 	w.Header().Add(handlers.HeaderContentType, handlers.ContentTypeJSON)
 
 	if err := json.NewEncoder(w).Encode(summary); err != nil {
-		http.Error(w, "Error encoding summary to JSON: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error encoding summary to JSON: %v", err)
 	}
 
 }
@@ -394,7 +394,7 @@ func TestSummaryHandler(t *testing.T) {
 	// - correct response status code
 	// - correct Content-Type header
 	query := "/v1/summary?url="
-	expectedTextContent := "text/plain; charset=utf-8"
+	expectedProblemContent := "application/problem+json"
 	expectedJSONContent := "application/json"
 	cases := []struct {
 		name                string
@@ -413,21 +413,28 @@ func TestSummaryHandler(t *testing.T) {
 			"Empty Query String",
 			"",
 			http.StatusBadRequest,
-			"text/plain; charset=utf-8",
+			expectedProblemContent,
 		},
 
 		{
 			"Invalid URL",
 			"trashURLwow",
-			http.StatusInternalServerError,
-			expectedTextContent,
+			http.StatusBadRequest,
+			expectedProblemContent,
 		},
 
 		{
 			"Invalid URL (Valid URL with bad spaces)",
 			"http://ogp%20.me",
-			http.StatusInternalServerError,
-			expectedTextContent,
+			http.StatusBadRequest,
+			expectedProblemContent,
+		},
+
+		{
+			"Unreachable URL",
+			"http://localhost:1",
+			http.StatusBadGateway,
+			expectedProblemContent,
 		},
 	}
 